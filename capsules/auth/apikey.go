@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// APIKey is a static API key and the Principal it authenticates
+type APIKey struct {
+	Key     string
+	Subject string
+	Scopes  []string
+	Roles   []string
+}
+
+// APIKeyConfig contains options for authenticating static API keys
+type APIKeyConfig struct {
+	// Header is the request header carrying the API key.
+	// Defaults to X-API-Key
+	Header string
+	// Keys are the valid API keys
+	Keys []APIKey
+}
+
+// APIKeyAuthenticator is an Authenticator of static API keys
+type APIKeyAuthenticator struct {
+	header string
+	keys   []hashedAPIKey
+}
+
+var _ Authenticator = (*APIKeyAuthenticator)(nil)
+
+type hashedAPIKey struct {
+	hash [sha256.Size]byte
+	key  APIKey
+}
+
+// NewAPIKeyAuthenticator instantiates an APIKeyAuthenticator. Keys are
+// compared in constant time.
+func NewAPIKeyAuthenticator(c APIKeyConfig) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{header: c.Header}
+	if a.header == "" {
+		a.header = "X-API-Key"
+	}
+	for _, k := range c.Keys {
+		if k.Key == "" {
+			continue
+		}
+		a.keys = append(a.keys, hashedAPIKey{hash: sha256.Sum256([]byte(k.Key)), key: k})
+	}
+	return a
+}
+
+// Authenticate authenticates the API key in the header of r
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	v := r.Header.Get(a.header)
+	if v == "" {
+		return nil, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(v))
+	var match *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], a.keys[i].hash[:]) == 1 {
+			match = &a.keys[i].key
+		}
+	}
+	if match == nil {
+		return nil, invalid("unknown api key")
+	}
+
+	return &Principal{
+		Subject: match.Subject,
+		Method:  "apikey",
+		Scopes:  match.Scopes,
+		Roles:   match.Roles,
+	}, nil
+}
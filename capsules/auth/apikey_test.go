@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   APIKeyConfig
+		header   string
+		value    string
+		expected *Principal
+		err      error
+	}{
+		{
+			name:     "Valid",
+			config:   APIKeyConfig{Keys: []APIKey{{Key: "a", Subject: "svc-a", Scopes: []string{"read"}}, {Key: "b", Subject: "svc-b", Roles: []string{"admin"}}}},
+			header:   "X-API-Key",
+			value:    "b",
+			expected: &Principal{Subject: "svc-b", Method: "apikey", Roles: []string{"admin"}},
+		},
+		{
+			name:     "Header",
+			config:   APIKeyConfig{Header: "X-Token", Keys: []APIKey{{Key: "a", Subject: "svc-a"}}},
+			header:   "X-Token",
+			value:    "a",
+			expected: &Principal{Subject: "svc-a", Method: "apikey"},
+		},
+		{
+			name:   "Missing",
+			config: APIKeyConfig{Keys: []APIKey{{Key: "a", Subject: "svc-a"}}},
+			err:    ErrNoCredentials,
+		},
+		{
+			name:   "Unknown",
+			config: APIKeyConfig{Keys: []APIKey{{Key: "a", Subject: "svc-a"}}},
+			header: "X-API-Key",
+			value:  "b",
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name:   "EmptyKey",
+			config: APIKeyConfig{Keys: []APIKey{{Subject: "svc-a"}}},
+			header: "X-API-Key",
+			value:  "x",
+			err:    ErrInvalidCredentials,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+
+			p, err := NewAPIKeyAuthenticator(test.config).Authenticate(req)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if !reflect.DeepEqual(p, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, p)
+			}
+		})
+	}
+}
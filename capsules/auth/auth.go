@@ -0,0 +1,130 @@
+// Package auth authenticates requests, placing the Principal making a request
+// in its context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/subject"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when a request carries
+	// no credentials it understands
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned by an Authenticator when the
+	// credentials of a request are not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+func init() {
+	httperr.RegisterStatus(ErrNoCredentials, http.StatusUnauthorized)
+	httperr.RegisterStatus(ErrInvalidCredentials, http.StatusUnauthorized)
+	httperr.RegisterStatus(ErrForbidden, http.StatusForbidden)
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject identifies the caller
+	Subject string
+	// Method is the authentication method, e.g. "jwt" or "apikey"
+	Method string
+	// Scopes are the scopes granted to the caller
+	Scopes []string
+	// Roles are the roles of the caller
+	Roles []string
+	// Claims are the claims of a token, if authenticated by one
+	Claims map[string]interface{}
+}
+
+// HasScope reports whether p was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+// HasRole reports whether p has role
+func (p *Principal) HasRole(role string) bool {
+	return contains(p.Roles, role)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext provides a copy of ctx carrying p, and its subject, see
+// subject.FromContext
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	if p != nil {
+		ctx = subject.NewContext(ctx, p.Subject)
+	}
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext provides the Principal carried by ctx
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Authenticator authenticates the caller of a request. ErrNoCredentials is
+// returned when the request carries no credentials for the Authenticator, any
+// other error rejects the request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc is an Authenticator function
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+// Authenticate calls f(r)
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// Challenger is implemented by Authenticators which provide a
+// WWW-Authenticate challenge for unauthenticated requests
+type Challenger interface {
+	Challenge() string
+}
+
+// Chain provides an Authenticator trying each of authenticators in order
+// until one finds credentials in the request.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+func (c chain) challenges() []string {
+	var challenges []string
+	for _, a := range c {
+		switch a := a.(type) {
+		case Challenger:
+			challenges = append(challenges, a.Challenge())
+		case chain:
+			challenges = append(challenges, a.challenges()...)
+		}
+	}
+	return challenges
+}
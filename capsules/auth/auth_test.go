@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/subject"
+)
+
+func TestContext(t *testing.T) {
+	if p, ok := FromContext(context.Background()); ok {
+		t.Errorf("expected no principal, got %v", p)
+	}
+
+	expected := &Principal{Subject: "foo"}
+	ctx := NewContext(context.Background(), expected)
+	if p, ok := FromContext(ctx); !ok || p != expected {
+		t.Errorf("expected %v, got %v", expected, p)
+	}
+	if s, ok := subject.FromContext(ctx); !ok || s != "foo" {
+		t.Errorf("expected subject %q, got %q", "foo", s)
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	p := &Principal{Scopes: []string{"read", "write"}, Roles: []string{"admin"}}
+
+	if !p.HasScope("write") || p.HasScope("delete") {
+		t.Error("unexpected scopes")
+	}
+	if !p.HasRole("admin") || p.HasRole("user") {
+		t.Error("unexpected roles")
+	}
+}
+
+type challengeAuthenticator struct {
+	AuthenticatorFunc
+	challenge string
+}
+
+func (a challengeAuthenticator) Challenge() string { return a.challenge }
+
+func TestChain(t *testing.T) {
+	none := AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, ErrNoCredentials })
+	foo := AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return &Principal{Subject: "foo"}, nil })
+	bar := AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return &Principal{Subject: "bar"}, nil })
+	fail := AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, ErrInvalidCredentials })
+
+	tests := []struct {
+		name     string
+		chain    Authenticator
+		expected string
+		err      error
+	}{
+		{name: "Empty", chain: Chain(), err: ErrNoCredentials},
+		{name: "None", chain: Chain(none, none), err: ErrNoCredentials},
+		{name: "First", chain: Chain(foo, bar), expected: "foo"},
+		{name: "Skip", chain: Chain(none, bar), expected: "bar"},
+		{name: "Invalid", chain: Chain(none, fail, foo), err: ErrInvalidCredentials},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := test.chain.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if err == nil && p.Subject != test.expected {
+				t.Errorf("expected subject %q, got %q", test.expected, p.Subject)
+			}
+		})
+	}
+
+	c := Chain(challengeAuthenticator{none, "Bearer"}, Chain(none, challengeAuthenticator{none, "ApiKey"}))
+	if challenges := (chain{c}).challenges(); !reflect.DeepEqual(challenges, []string{"Bearer", "ApiKey"}) {
+		t.Errorf("unexpected challenges %v", challenges)
+	}
+}
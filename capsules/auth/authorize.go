@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrForbidden is matched by the errors of an Authorizer denying a request
+var ErrForbidden = errors.New("forbidden")
+
+// Denial is the error of an Authorizer denying a request, its Reason is safe
+// to return to the caller.
+type Denial struct {
+	Reason string
+}
+
+// Deny provides a Denial for reason
+func Deny(reason string) error {
+	return &Denial{Reason: reason}
+}
+
+func (d *Denial) Error() string {
+	return "forbidden: " + d.Reason
+}
+
+// Is reports whether target is ErrForbidden
+func (d *Denial) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Authorizer decides whether the Principal p may make the request r to the
+// route pattern route. p is nil for anonymous requests. A nil error allows the
+// request, ErrNoCredentials requires the caller to authenticate, and any other
+// error denies the request.
+type Authorizer interface {
+	Authorize(r *http.Request, route string, p *Principal) error
+}
+
+// AuthorizerFunc is an Authorizer function
+type AuthorizerFunc func(r *http.Request, route string, p *Principal) error
+
+// Authorize calls f(r, route, p)
+func (f AuthorizerFunc) Authorize(r *http.Request, route string, p *Principal) error {
+	return f(r, route, p)
+}
+
+// RequireAuthenticated provides an Authorizer allowing any Principal
+func RequireAuthenticated() Authorizer {
+	return AuthorizerFunc(func(r *http.Request, route string, p *Principal) error {
+		if p == nil {
+			return ErrNoCredentials
+		}
+		return nil
+	})
+}
+
+// RequireScopes provides an Authorizer allowing Principals granted every one
+// of scopes
+func RequireScopes(scopes ...string) Authorizer {
+	return AuthorizerFunc(func(r *http.Request, route string, p *Principal) error {
+		if p == nil {
+			return ErrNoCredentials
+		}
+		var missing []string
+		for _, scope := range scopes {
+			if !p.HasScope(scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			return Deny(fmt.Sprintf("missing scope %s", strings.Join(missing, " ")))
+		}
+		return nil
+	})
+}
+
+// RequireRoles provides an Authorizer allowing Principals with any one of
+// roles
+func RequireRoles(roles ...string) Authorizer {
+	return AuthorizerFunc(func(r *http.Request, route string, p *Principal) error {
+		if p == nil {
+			return ErrNoCredentials
+		}
+		for _, role := range roles {
+			if p.HasRole(role) {
+				return nil
+			}
+		}
+		return Deny(fmt.Sprintf("requires role %s", strings.Join(roles, " or ")))
+	})
+}
+
+// AllOf provides an Authorizer allowing requests allowed by every one of
+// authorizers, evaluated in order.
+func AllOf(authorizers ...Authorizer) Authorizer {
+	return AuthorizerFunc(func(r *http.Request, route string, p *Principal) error {
+		for _, a := range authorizers {
+			if a == nil {
+				continue
+			}
+			if err := a.Authorize(r, route, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Challenges provides the WWW-Authenticate challenges of a, which is either a
+// Challenger or a Chain of Authenticators.
+func Challenges(a Authenticator) []string {
+	return chain{a}.challenges()
+}
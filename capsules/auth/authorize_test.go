@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizers(t *testing.T) {
+	p := &Principal{Subject: "foo", Scopes: []string{"read", "write"}, Roles: []string{"ops"}}
+	deny := AuthorizerFunc(func(r *http.Request, route string, p *Principal) error {
+		if route == "/admin" {
+			return Deny("admin only")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name       string
+		authorizer Authorizer
+		route      string
+		principal  *Principal
+		err        error
+		reason     string
+	}{
+		{name: "Authenticated", authorizer: RequireAuthenticated(), principal: p},
+		{name: "Anonymous", authorizer: RequireAuthenticated(), err: ErrNoCredentials},
+		{name: "Scopes", authorizer: RequireScopes("read", "write"), principal: p},
+		{name: "MissingScopes", authorizer: RequireScopes("read", "delete", "admin"), principal: p, err: ErrForbidden, reason: "missing scope delete admin"},
+		{name: "ScopesAnonymous", authorizer: RequireScopes("read"), err: ErrNoCredentials},
+		{name: "Roles", authorizer: RequireRoles("admin", "ops"), principal: p},
+		{name: "MissingRoles", authorizer: RequireRoles("admin", "dev"), principal: p, err: ErrForbidden, reason: "requires role admin or dev"},
+		{name: "AllOf", authorizer: AllOf(nil, RequireScopes("read"), deny), route: "/users", principal: p},
+		{name: "AllOfDenied", authorizer: AllOf(RequireScopes("read"), deny), route: "/admin", principal: p, err: ErrForbidden, reason: "admin only"},
+		{name: "AllOfOrder", authorizer: AllOf(RequireAuthenticated(), deny), route: "/admin", err: ErrNoCredentials},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.authorizer.Authorize(httptest.NewRequest(http.MethodGet, "/", nil), test.route, test.principal)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			var denial *Denial
+			if errors.As(err, &denial) && denial.Reason != test.reason {
+				t.Errorf("expected reason %q, got %q", test.reason, denial.Reason)
+			}
+		})
+	}
+}
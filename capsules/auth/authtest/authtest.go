@@ -0,0 +1,26 @@
+// Package authtest provides utilities for testing handlers which require an
+// authenticated auth.Principal.
+package authtest
+
+import (
+	"net/http"
+
+	"go.adenix.dev/adderall/capsules/auth"
+)
+
+// Authenticator provides an auth.Authenticator authenticating every request
+// as p. Requests are anonymous when p is nil.
+func Authenticator(p *auth.Principal) auth.Authenticator {
+	return auth.AuthenticatorFunc(func(r *http.Request) (*auth.Principal, error) {
+		if p == nil {
+			return nil, auth.ErrNoCredentials
+		}
+		return p, nil
+	})
+}
+
+// WithPrincipal provides a copy of r authenticated as p, for calling a handler
+// directly.
+func WithPrincipal(r *http.Request, p *auth.Principal) *http.Request {
+	return r.WithContext(auth.NewContext(r.Context(), p))
+}
@@ -0,0 +1,473 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	// register the hashes used by the supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// JWTConfig contains options for authenticating bearer JSON Web Tokens. The
+// verification keys are loaded from KeyFile, SecretFile, JWKSFile, or JWKSURL.
+type JWTConfig struct {
+	// KeyFile is a PEM encoded RSA or ECDSA public key or certificate
+	KeyFile string
+	// SecretFile is a file containing an HMAC secret. It is kept apart from
+	// KeyFile so public key material is never mistaken for a secret.
+	SecretFile string
+	// JWKSFile is a JSON Web Key Set document
+	JWKSFile string
+	// JWKSURL is the URL of a JSON Web Key Set document. It is fetched again
+	// every RefreshIntervalSec, and when a token is signed by an unknown key.
+	JWKSURL string
+	// RefreshIntervalSec is how often the JWKSURL is fetched.
+	// Defaults to 300
+	RefreshIntervalSec int
+	// Issuer is the required iss claim
+	Issuer string
+	// Audience is the required aud claim
+	Audience string
+	// Algorithms are the signing algorithms accepted.
+	// Defaults to every supported algorithm of the keys
+	Algorithms []string
+	// LeewaySec is the clock skew tolerated when validating exp and nbf
+	LeewaySec int
+	// ScopeClaim is the claim holding the scopes, either a space separated
+	// string or an array.
+	// Defaults to "scope", falling back to "scp"
+	ScopeClaim string
+	// RolesClaim is the claim holding the roles.
+	// Defaults to "roles"
+	RolesClaim string
+}
+
+// minRefreshInterval bounds how often the JWKSURL is fetched for tokens
+// signed by unknown keys
+const minRefreshInterval = 10 * time.Second
+
+// JWTAuthenticator is an Authenticator of bearer JSON Web Tokens signed with
+// HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, or ES512.
+type JWTAuthenticator struct {
+	config JWTConfig
+	logger Logger
+	client *http.Client
+	now    func() time.Time
+
+	mu         sync.RWMutex
+	keys       []jwk
+	fetchedAt  time.Time
+	refreshing chan struct{}
+}
+
+var (
+	_ Authenticator = (*JWTAuthenticator)(nil)
+	_ Challenger    = (*JWTAuthenticator)(nil)
+)
+
+// NewJWTAuthenticator instantiates a JWTAuthenticator, loading its keys.
+// Options can be passed to provide a logger.
+func NewJWTAuthenticator(c JWTConfig, opts ...Option) (*JWTAuthenticator, error) {
+	o := config{logger: NoopLogger{}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+
+	if c.RefreshIntervalSec <= 0 {
+		c.RefreshIntervalSec = 300
+	}
+	if c.RolesClaim == "" {
+		c.RolesClaim = "roles"
+	}
+
+	a := &JWTAuthenticator{
+		config: c,
+		logger: o.logger,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+
+	switch {
+	case c.KeyFile != "":
+		key, err := loadKeyFile(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = []jwk{{key: key}}
+	case c.SecretFile != "":
+		secret, err := loadSecretFile(c.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = []jwk{{key: secret}}
+	case c.JWKSFile != "":
+		b, err := os.ReadFile(c.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading jwks: %w", err)
+		}
+		if a.keys, err = parseJWKS(b); err != nil {
+			return nil, err
+		}
+	case c.JWKSURL != "":
+		if err := a.fetch(context.Background()); err != nil {
+			return nil, err
+		}
+		a.fetchedAt = a.now()
+	default:
+		return nil, errors.New("jwt authentication requires a key file, secret file, jwks file, or jwks url")
+	}
+
+	return a, nil
+}
+
+// Challenge provides the WWW-Authenticate challenge for bearer tokens
+func (a *JWTAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+// Authenticate authenticates the bearer token in the Authorization header of r
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return nil, ErrNoCredentials
+	}
+	return a.Verify(r.Context(), strings.TrimSpace(h[7:]))
+}
+
+// Verify validates the signature and claims of token, providing the Principal
+// it identifies.
+func (a *JWTAuthenticator) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalid("malformed header")
+	}
+	if len(a.config.Algorithms) > 0 && !contains(a.config.Algorithms, header.Alg) {
+		return nil, invalid("algorithm %q not accepted", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+	if err := a.verifySignature(ctx, header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalid("malformed claims")
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	p := &Principal{
+		Subject: sub,
+		Method:  "jwt",
+		Roles:   stringsClaim(claims[a.config.RolesClaim]),
+		Claims:  claims,
+	}
+	if a.config.ScopeClaim != "" {
+		p.Scopes = stringsClaim(claims[a.config.ScopeClaim])
+	} else if scope, ok := claims["scope"]; ok {
+		p.Scopes = stringsClaim(scope)
+	} else {
+		p.Scopes = stringsClaim(claims["scp"])
+	}
+	return p, nil
+}
+
+func (a *JWTAuthenticator) verifySignature(ctx context.Context, alg, kid string, signed, sig []byte) error {
+	if a.config.JWKSURL != "" {
+		a.refresh(ctx, time.Duration(a.config.RefreshIntervalSec)*time.Second)
+	}
+	keys := a.candidates(alg, kid)
+	if len(keys) == 0 && kid != "" && a.config.JWKSURL != "" && a.refresh(ctx, minRefreshInterval) {
+		keys = a.candidates(alg, kid)
+	}
+	if len(keys) == 0 {
+		return invalid("no key for algorithm %q and key id %q", alg, kid)
+	}
+
+	for _, key := range keys {
+		if verify(alg, key, signed, sig) {
+			return nil
+		}
+	}
+	return invalid("signature verification failed")
+}
+
+// candidates provides the keys which may have signed a token with alg and kid
+func (a *JWTAuthenticator) candidates(alg, kid string) []interface{} {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var keys []interface{}
+	for _, k := range a.keys {
+		if kid != "" && k.kid != "" && k.kid != kid {
+			continue
+		}
+		if k.alg != "" && k.alg != alg {
+			continue
+		}
+		if compatible(alg, k.key) {
+			keys = append(keys, k.key)
+		}
+	}
+	return keys
+}
+
+// refresh fetches the JWKSURL again when it was last fetched at least
+// interval ago, reporting whether the keys may have changed. Concurrent
+// callers share a single fetch, waiting for it until their ctx is done.
+func (a *JWTAuthenticator) refresh(ctx context.Context, interval time.Duration) bool {
+	a.mu.Lock()
+	if done := a.refreshing; done != nil {
+		a.mu.Unlock()
+		select {
+		case <-done:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	previous := a.fetchedAt
+	if a.now().Sub(previous) < interval {
+		a.mu.Unlock()
+		return false
+	}
+	done := make(chan struct{})
+	a.refreshing, a.fetchedAt = done, a.now()
+	a.mu.Unlock()
+
+	err := a.fetch(ctx)
+
+	a.mu.Lock()
+	if err != nil && ctx.Err() != nil {
+		// the caller went away, leaving the refresh to the next request
+		a.fetchedAt = previous
+	}
+	a.refreshing = nil
+	a.mu.Unlock()
+	close(done)
+
+	if err != nil {
+		a.logger.WarnCtx(ctx, "failed to refresh jwks", "url", a.config.JWKSURL, "error", err)
+		return false
+	}
+	return true
+}
+
+// fetch replaces the keys with those of the JWKSURL
+func (a *JWTAuthenticator) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.config.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching jwks: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching jwks: unexpected status %d", res.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(res.Body); err != nil {
+		return fmt.Errorf("fetching jwks: %w", err)
+	}
+	keys, err := parseJWKS(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys = keys
+	return nil
+}
+
+func (a *JWTAuthenticator) validateClaims(claims map[string]interface{}) error {
+	now := a.now()
+	leeway := time.Duration(a.config.LeewaySec) * time.Second
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return invalid("missing exp claim")
+	}
+	if now.Add(-leeway).After(time.Unix(int64(exp), 0)) {
+		return invalid("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return invalid("token not yet valid")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return invalid("missing sub claim")
+	}
+	if a.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.config.Issuer {
+			return invalid("unexpected issuer %q", iss)
+		}
+	}
+	if a.config.Audience != "" && !contains(stringsClaim(claims["aud"]), a.config.Audience) {
+		return invalid("unexpected audience")
+	}
+	return nil
+}
+
+// stringsClaim provides the values of a claim which is either a space
+// separated string or an array of strings
+func stringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCredentials, fmt.Sprintf(format, args...))
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+var algHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+var algCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+
+// compatible reports whether key can verify signatures of alg, preventing a
+// key from being used with an algorithm of another family
+func compatible(alg string, key interface{}) bool {
+	if _, ok := algHashes[alg]; !ok {
+		return false
+	}
+	switch key := key.(type) {
+	case []byte:
+		return strings.HasPrefix(alg, "HS")
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS")
+	case *ecdsa.PublicKey:
+		return algCurves[alg] == key.Curve.Params().Name
+	}
+	return false
+}
+
+func verify(alg string, key interface{}, signed, sig []byte) bool {
+	hash := algHashes[alg]
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, key)
+		mac.Write(signed)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// loadSecretFile loads an HMAC secret, ignoring surrounding whitespace
+func loadSecretFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt secret: %w", err)
+	}
+	secret := bytes.TrimSpace(b)
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("no secret found in %s", path)
+	}
+	return secret, nil
+}
+
+// loadKeyFile loads a PEM encoded public key or certificate
+func loadKeyFile(path string) (interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt key: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no pem encoded key found in %s", path)
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt key: %w", err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt key: %w", err)
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported pem block %q in %s", block.Type, path)
+}
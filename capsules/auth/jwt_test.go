@@ -0,0 +1,491 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testSecret    = []byte("0123456789abcdef0123456789abcdef")
+)
+
+// sign creates a token of claims signed by key with alg
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	hash := algHashes[alg]
+	d := hash.New()
+	d.Write([]byte(signed))
+	digest := d.Sum(nil)
+
+	var sig []byte
+	var err error
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeFile(t *testing.T, name string, b []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return path
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func jwks(keys ...map[string]string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return b
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": key.Curve.Params().Name,
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+}
+
+func claims(overrides map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"sub": "user-1",
+		"iss": "https://issuer.example.com",
+		"aud": "api",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaFile := writeFile(t, "rsa.pem", publicKeyPEM(t, &testRSAKey.PublicKey))
+	ecFile := writeFile(t, "ec.pem", publicKeyPEM(t, &testECKey.PublicKey))
+	secretFile := writeFile(t, "secret", append(testSecret, '\n'))
+	jwksFile := writeFile(t, "jwks.json", jwks(rsaJWK("rsa", &testRSAKey.PublicKey), ecJWK("ec", &testECKey.PublicKey)))
+
+	validator := JWTConfig{Issuer: "https://issuer.example.com", Audience: "api"}
+	with := func(c JWTConfig, f func(c *JWTConfig)) JWTConfig {
+		f(&c)
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		config JWTConfig
+		token  string
+		err    bool
+	}{
+		{
+			name:   "HS256",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(nil)),
+		},
+		{
+			name:   "HS512",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS512", "", testSecret, claims(nil)),
+		},
+		{
+			name:   "RS256",
+			config: with(validator, func(c *JWTConfig) { c.KeyFile = rsaFile }),
+			token:  sign(t, "RS256", "", testRSAKey, claims(nil)),
+		},
+		{
+			name:   "RS384",
+			config: with(validator, func(c *JWTConfig) { c.KeyFile = rsaFile }),
+			token:  sign(t, "RS384", "", testRSAKey, claims(nil)),
+		},
+		{
+			name:   "ES256",
+			config: with(validator, func(c *JWTConfig) { c.KeyFile = ecFile }),
+			token:  sign(t, "ES256", "", testECKey, claims(nil)),
+		},
+		{
+			name:   "JWKSFile",
+			config: with(validator, func(c *JWTConfig) { c.JWKSFile = jwksFile }),
+			token:  sign(t, "ES256", "ec", testECKey, claims(nil)),
+		},
+		{
+			name:   "JWKSFileWrongKid",
+			config: with(validator, func(c *JWTConfig) { c.JWKSFile = jwksFile }),
+			token:  sign(t, "RS256", "ec", testRSAKey, claims(nil)),
+			err:    true,
+		},
+		{
+			name:   "AlgorithmNotAccepted",
+			config: with(validator, func(c *JWTConfig) { c.KeyFile = rsaFile; c.Algorithms = []string{"RS512"} }),
+			token:  sign(t, "RS256", "", testRSAKey, claims(nil)),
+			err:    true,
+		},
+		{
+			name:   "AlgorithmConfusion",
+			config: with(validator, func(c *JWTConfig) { c.KeyFile = rsaFile }),
+			token:  sign(t, "HS256", "", publicKeyPEM(t, &testRSAKey.PublicKey), claims(nil)),
+			err:    true,
+		},
+		{
+			name:   "AlgorithmNone",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1"}`)) + ".",
+			err:    true,
+		},
+		{
+			name:   "BadSignature",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", []byte("other"), claims(nil)),
+			err:    true,
+		},
+		{
+			name:   "Expired",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()})),
+			err:    true,
+		},
+		{
+			name:   "ExpiredWithinLeeway",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile; c.LeewaySec = 120 }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()})),
+		},
+		{
+			name:   "MissingExp",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"exp": nil})),
+			err:    true,
+		},
+		{
+			name:   "NotYetValid",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"nbf": time.Now().Add(time.Minute).Unix()})),
+			err:    true,
+		},
+		{
+			name:   "MissingSubject",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"sub": nil})),
+			err:    true,
+		},
+		{
+			name:   "Issuer",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"iss": "https://other.example.com"})),
+			err:    true,
+		},
+		{
+			name:   "AudienceArray",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"aud": []string{"other", "api"}})),
+		},
+		{
+			name:   "Audience",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  sign(t, "HS256", "", testSecret, claims(map[string]interface{}{"aud": "other"})),
+			err:    true,
+		},
+		{
+			name:   "Malformed",
+			config: with(validator, func(c *JWTConfig) { c.SecretFile = secretFile }),
+			token:  "not.a-token",
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := NewJWTAuthenticator(test.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			p, err := a.Verify(context.Background(), test.token)
+			if test.err {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Errorf("expected invalid credentials, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if p.Subject != "user-1" || p.Method != "jwt" {
+				t.Errorf("unexpected principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorClaims(t *testing.T) {
+	path := writeFile(t, "secret", testSecret)
+
+	tests := []struct {
+		name   string
+		config JWTConfig
+		claims map[string]interface{}
+		scopes []string
+		roles  []string
+	}{
+		{
+			name:   "Scope",
+			claims: claims(map[string]interface{}{"scope": "read write", "roles": []string{"admin"}}),
+			scopes: []string{"read", "write"},
+			roles:  []string{"admin"},
+		},
+		{
+			name:   "Scp",
+			claims: claims(map[string]interface{}{"scp": []string{"read"}}),
+			scopes: []string{"read"},
+		},
+		{
+			name:   "Custom",
+			config: JWTConfig{ScopeClaim: "permissions", RolesClaim: "groups"},
+			claims: claims(map[string]interface{}{"permissions": "read", "groups": "ops dev"}),
+			scopes: []string{"read"},
+			roles:  []string{"ops", "dev"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.SecretFile = path
+			a, err := NewJWTAuthenticator(test.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+sign(t, "HS256", "", testSecret, test.claims))
+			p, err := a.Authenticate(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(p.Scopes, test.scopes) {
+				t.Errorf("expected scopes %v, got %v", test.scopes, p.Scopes)
+			}
+			if !reflect.DeepEqual(p.Roles, test.roles) {
+				t.Errorf("expected roles %v, got %v", test.roles, p.Roles)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorNoCredentials(t *testing.T) {
+	a, err := NewJWTAuthenticator(JWTConfig{SecretFile: writeFile(t, "secret", testSecret)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, h := range []string{"", "Basic Zm9vOmJhcg=="} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", h)
+		if _, err := a.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("expected no credentials for %q, got %v", h, err)
+		}
+	}
+}
+
+func TestJWTAuthenticatorJWKSURL(t *testing.T) {
+	rotated, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var fetches int32
+	var doc atomic.Value
+	doc.Store(jwks(rsaJWK("a", &testRSAKey.PublicKey)))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write(doc.Load().([]byte))
+	}))
+	defer ts.Close()
+
+	a, err := NewJWTAuthenticator(JWTConfig{JWKSURL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	if _, err := a.Verify(context.Background(), sign(t, "RS256", "a", testRSAKey, claims(nil))); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected 1 fetch, got %d", n)
+	}
+
+	// an unknown key id is fetched again once the minimum interval elapses
+	doc.Store(jwks(rsaJWK("a", &testRSAKey.PublicKey), ecJWK("b", &rotated.PublicKey)))
+	token := sign(t, "ES256", "b", rotated, claims(nil))
+	if _, err := a.Verify(context.Background(), token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected invalid credentials within the refresh interval, got %v", err)
+	}
+
+	now = now.Add(minRefreshInterval)
+	if _, err := a.Verify(context.Background(), token); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("expected 2 fetches, got %d", n)
+	}
+
+	// keys are refreshed periodically
+	doc.Store(jwks(ecJWK("b", &rotated.PublicKey)))
+	now = now.Add(5 * time.Minute)
+	if _, err := a.Verify(context.Background(), sign(t, "RS256", "a", testRSAKey, claims(nil))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected invalid credentials after rotation, got %v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 3 {
+		t.Errorf("expected 3 fetches, got %d", n)
+	}
+}
+
+func TestJWTAuthenticatorJWKSURLConcurrentRefresh(t *testing.T) {
+	rotated, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var fetches int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			_, _ = w.Write(jwks(rsaJWK("a", &testRSAKey.PublicKey)))
+			return
+		}
+		<-release
+		_, _ = w.Write(jwks(ecJWK("b", &rotated.PublicKey)))
+	}))
+	defer ts.Close()
+
+	a, err := NewJWTAuthenticator(JWTConfig{JWKSURL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Now().Add(minRefreshInterval)
+	a.now = func() time.Time { return now }
+	token := sign(t, "ES256", "b", rotated, claims(nil))
+
+	// concurrent requests for an unknown key id share a single fetch
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := a.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("expected 2 fetches, got %d", n)
+	}
+
+	// a canceled request leaves the refresh to the next one
+	now = now.Add(minRefreshInterval)
+	fetchedAt := a.fetchedAt
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.Verify(ctx, sign(t, "ES256", "c", rotated, claims(nil))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected invalid credentials for a canceled request, got %v", err)
+	}
+	if !a.fetchedAt.Equal(fetchedAt) {
+		t.Errorf("expected the fetch time %s to be kept, got %s", fetchedAt, a.fetchedAt)
+	}
+}
+
+func TestNewJWTAuthenticatorError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		config JWTConfig
+	}{
+		{name: "NoKeys"},
+		{name: "MissingKeyFile", config: JWTConfig{KeyFile: filepath.Join(t.TempDir(), "missing")}},
+		{name: "EmptyKeyFile", config: JWTConfig{KeyFile: writeFile(t, "empty", nil)}},
+		{name: "SecretInKeyFile", config: JWTConfig{KeyFile: writeFile(t, "secret", testSecret)}},
+		{name: "DERKeyFile", config: JWTConfig{KeyFile: writeFile(t, "rsa.der", x509.MarshalPKCS1PublicKey(&testRSAKey.PublicKey))}},
+		{name: "JWKSInKeyFile", config: JWTConfig{KeyFile: writeFile(t, "jwks.json", jwks(rsaJWK("rsa", &testRSAKey.PublicKey)))}},
+		{name: "EmptySecretFile", config: JWTConfig{SecretFile: writeFile(t, "empty-secret", []byte("\n"))}},
+		{name: "InvalidJWKS", config: JWTConfig{JWKSFile: writeFile(t, "jwks.json", []byte(`{"keys":[]}`))}},
+		{name: "JWKSURL", config: JWTConfig{JWKSURL: ts.URL}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewJWTAuthenticator(test.config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
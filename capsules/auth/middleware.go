@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+type config struct {
+	exempt   []string
+	optional bool
+	logger   Logger
+}
+
+// Option interface to identify functional options
+type Option func(c *config)
+
+// WithExemptPaths provides an Option to provide paths which are not
+// authenticated. A path ending with a slash exempts every path below it.
+func WithExemptPaths(paths ...string) Option {
+	return func(c *config) {
+		c.exempt = append(c.exempt, paths...)
+	}
+}
+
+// WithOptional provides an Option to let requests without credentials through
+// without a Principal. Requests with invalid credentials are still rejected.
+// Defaults to false
+func WithOptional(optional bool) Option {
+	return func(c *config) {
+		c.optional = optional
+	}
+}
+
+// WithLogger provides an Option to provide a logger implementation.
+// Defaults to Noop
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// Middleware authenticates every request with a, placing the Principal in the
+// request context. Requests failing authentication are rejected with a 401.
+func Middleware(a Authenticator, opts ...Option) func(http.Handler) http.Handler {
+	c := config{logger: NoopLogger{}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+
+	challenges := Challenges(a)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if exempt(c.exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p, err := a.Authenticate(r)
+			switch {
+			case err == nil && p != nil:
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+				return
+			case errors.Is(err, ErrNoCredentials) && c.optional:
+				next.ServeHTTP(w, r)
+				return
+			case err == nil:
+				err = ErrInvalidCredentials
+			}
+
+			c.logger.DebugCtx(r.Context(), "authentication failed",
+				"path", r.URL.EscapedPath(),
+				"error", err,
+			)
+			for _, challenge := range challenges {
+				w.Header().Add("WWW-Authenticate", challenge)
+			}
+			httperr.Write(w, r, httperr.Problem{Status: http.StatusUnauthorized})
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func exempt(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
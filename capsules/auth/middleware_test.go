@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	a := Chain(
+		NewAPIKeyAuthenticator(APIKeyConfig{Keys: []APIKey{{Key: "secret", Subject: "svc"}}}),
+		challengeAuthenticator{AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, ErrNoCredentials }), "Bearer"},
+	)
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		path      string
+		key       string
+		status    int
+		subject   string
+		challenge string
+	}{
+		{name: "Authenticated", path: "/users", key: "secret", status: http.StatusOK, subject: "svc"},
+		{name: "Missing", path: "/users", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{name: "Invalid", path: "/users", key: "wrong", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{name: "Exempt", opts: []Option{WithExemptPaths("/live")}, path: "/live", status: http.StatusOK},
+		{name: "ExemptPrefix", opts: []Option{WithExemptPaths("/swagger/")}, path: "/swagger/index.html", status: http.StatusOK},
+		{name: "ExemptExact", opts: []Option{WithExemptPaths("/live")}, path: "/live/foo", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{name: "Optional", opts: []Option{WithOptional(true)}, path: "/users", status: http.StatusOK},
+		{name: "OptionalInvalid", opts: []Option{WithOptional(true)}, path: "/users", key: "wrong", status: http.StatusUnauthorized, challenge: "Bearer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var subject string
+			h := Middleware(a, append(test.opts, WithLogger(NoopLogger{}))...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if p, ok := FromContext(r.Context()); ok {
+					subject = p.Subject
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.key != "" {
+				req.Header.Set("X-API-Key", test.key)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if subject != test.subject {
+				t.Errorf("expected subject %q, got %q", test.subject, subject)
+			}
+			if challenge := rr.Header().Get("WWW-Authenticate"); challenge != test.challenge {
+				t.Errorf("expected challenge %q, got %q", test.challenge, challenge)
+			}
+			if test.status == http.StatusUnauthorized && rr.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("expected a problem response, got %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -5,18 +5,23 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/requestid"
 	"go.adenix.dev/adderall/internal/pointer"
 )
 
-// Client represents a Doer. Client is instrumented with OpenTracing and logging
+// Client represents a Doer. Client is instrumented with OpenTracing, logging
+// and metrics
 type Client struct {
 	*http.Client
-	tracer opentracing.Tracer
-	logger Logger
-	config Config
+	tracer  opentracing.Tracer
+	logger  Logger
+	metrics *metrics.Registry
+	config  Config
 }
 
-// Do executes an OpenTracking instrumented HTTP request
+// Do executes an OpenTracking instrumented HTTP request. The request id carried
+// by the request context is forwarded in the X-Request-ID header.
 func (c *Client) Do(request *http.Request) (*http.Response, error) {
 	ctx := request.Context()
 
@@ -28,14 +33,25 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 
 	_ = c.tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(request.Header))
 
+	if id, ok := requestid.FromContext(ctx); ok && request.Header.Get(requestid.Header) == "" {
+		request.Header.Set(requestid.Header, id)
+	}
+
 	request = request.WithContext(ctx)
 
+	done := func(int) {}
+	if c.metrics != nil {
+		done = c.metrics.Client().Begin(request.Method, request.URL.Host)
+	}
+
 	resp, err := c.Client.Do(request)
 	if err != nil {
 		span.SetTag("error", true)
+		done(0)
 		return resp, err
 	}
 	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	done(resp.StatusCode)
 
 	return resp, err
 }
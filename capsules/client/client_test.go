@@ -6,12 +6,16 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/requestid"
 	mock "go.adenix.dev/adderall/mock/tracing"
 )
 
@@ -137,6 +141,88 @@ func TestDo(t *testing.T) {
 	}
 }
 
+func TestDoMetrics(t *testing.T) {
+	ts := httptest.NewServer(newHandlerFunc("", http.StatusAccepted))
+	defer ts.Close()
+
+	r := metrics.NewRegistry(metrics.WithRuntimeCollectors(false))
+	c := NewFactory(WithMetrics(r)).Create(WithRetryMax(0))
+
+	request, err := http.NewRequest(http.MethodPost, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	res, err := c.Do(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = res.Body.Close()
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	expected := fmt.Sprintf(`
+# HELP http_client_requests_total Total number of HTTP requests.
+# TYPE http_client_requests_total counter
+http_client_requests_total{host=%q,method="POST",status="2xx"} 1
+`, host)
+	if err := testutil.GatherAndCompare(r, strings.NewReader(expected), "http_client_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDoRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		header   string
+		expected string
+	}{
+		{
+			name:     "FromContext",
+			ctx:      requestid.NewContext(context.Background(), "abc"),
+			expected: "abc",
+		},
+		{
+			name:     "Explicit",
+			ctx:      requestid.NewContext(context.Background(), "abc"),
+			header:   "def",
+			expected: "def",
+		},
+		{
+			name: "None",
+			ctx:  context.Background(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received string
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				received = r.Header.Get(requestid.Header)
+			}))
+			defer ts.Close()
+
+			c := NewFactory(WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false)))).Create(WithRetryMax(0))
+
+			request, err := http.NewRequestWithContext(test.ctx, http.MethodGet, ts.URL, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.header != "" {
+				request.Header.Set(requestid.Header, test.header)
+			}
+			res, err := c.Do(request)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			_ = res.Body.Close()
+
+			if received != test.expected {
+				t.Errorf("expected request id %q, got %q", test.expected, received)
+			}
+		})
+	}
+}
+
 func mockTrackerWithExpect(ctx context.Context, t *testing.T, method, url string, status int, err bool) opentracing.Tracer {
 	controller := gomock.NewController(t)
 	tracer := mock.NewMockTracer(controller)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/metrics"
 )
 
 // Factory is the interface to create Clients
@@ -13,18 +14,20 @@ type Factory interface {
 }
 
 type factory struct {
-	tracer opentracing.Tracer
-	logger Logger
-	config Config
+	tracer  opentracing.Tracer
+	logger  Logger
+	metrics *metrics.Registry
+	config  Config
 }
 
 // NewFactory instantiates a Client Factory. FactoryOption can be passed to
 // overwrite default configurations.
 func NewFactory(options ...FactoryOption) Factory {
 	f := &factory{
-		tracer: opentracing.NoopTracer{},
-		logger: NoopLogger{},
-		config: defaultConfig(),
+		tracer:  opentracing.NoopTracer{},
+		logger:  NoopLogger{},
+		metrics: metrics.Default(),
+		config:  defaultConfig(),
 	}
 
 	for _, option := range options {
@@ -41,9 +44,10 @@ func NewFactory(options ...FactoryOption) Factory {
 func (f *factory) Create(options ...Option) *Client {
 
 	c := &Client{
-		tracer: f.tracer,
-		logger: f.logger,
-		config: f.config,
+		tracer:  f.tracer,
+		logger:  f.logger,
+		metrics: f.metrics,
+		config:  f.config,
 	}
 
 	for _, option := range options {
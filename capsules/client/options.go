@@ -2,6 +2,7 @@ package client
 
 import (
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/metrics"
 	"go.adenix.dev/adderall/internal/pointer"
 )
 
@@ -23,6 +24,14 @@ func WithClientTracer(t opentracing.Tracer) Option {
 	}
 }
 
+// WithClientMetrics provides an Option to provide a metrics registry to be used
+// by the Client. A nil registry disables metrics.
+func WithClientMetrics(m *metrics.Registry) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
 // WithTimeoutMs provides an Option to provide the maximum duration in
 // milliseconds to wait for a request to finish.
 // Defaults to 3 seconds
@@ -61,6 +70,10 @@ func WithLogger(l Logger) FactoryOption { return factoryOptionLogger{logger: l}
 // Defaults to Noop
 func WithTracer(t opentracing.Tracer) FactoryOption { return factoryOptionTracer{tracer: t} }
 
+// WithMetrics provides an Option to provide a metrics registry.
+// Defaults to metrics.Default()
+func WithMetrics(m *metrics.Registry) FactoryOption { return factoryOptionMetrics{metrics: m} }
+
 // WithConfig provides an Option to provide a server configuration.
 func WithConfig(c Config) FactoryOption { return factoryOptionConfig{c} }
 
@@ -80,6 +93,14 @@ func (t factoryOptionTracer) apply(f *factory) {
 	}
 }
 
+type factoryOptionMetrics struct{ metrics *metrics.Registry }
+
+func (m factoryOptionMetrics) apply(f *factory) {
+	if m.metrics != nil {
+		f.metrics = m.metrics
+	}
+}
+
 type factoryOptionConfig struct{ config Config }
 
 func (c factoryOptionConfig) apply(f *factory) {
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/metrics"
 	"go.adenix.dev/adderall/internal/pointer"
 )
 
@@ -12,6 +13,8 @@ type optionAssertion func(t *testing.T, c *Client)
 
 type factoryOptionAssertion func(t *testing.T, f *factory)
 
+var testRegistry = metrics.NewRegistry(metrics.WithRuntimeCollectors(false))
+
 func TestOption(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -29,6 +32,11 @@ func TestOption(t *testing.T) {
 			op:     WithClientTracer(opentracing.NoopTracer{}),
 			assert: assertOptionWithClientTracer(opentracing.NoopTracer{}),
 		},
+		{
+			name:   "WithClientMetrics",
+			op:     WithClientMetrics(testRegistry),
+			assert: assertOptionWithClientMetrics(testRegistry),
+		},
 		{
 			name:   "WithTimeoutMs",
 			op:     WithTimeoutMs(1000),
@@ -74,6 +82,17 @@ func TestFactoryOption(t *testing.T) {
 			op:     WithTracer(opentracing.NoopTracer{}),
 			assert: assertFactoryOptionWithTracer(opentracing.NoopTracer{}),
 		},
+		{
+			name:   "WithMetrics",
+			op:     WithMetrics(testRegistry),
+			assert: assertFactoryOptionWithMetrics(testRegistry),
+		},
+		{
+			name:    "WithMetrics-Nil",
+			factory: &factory{metrics: testRegistry},
+			op:      WithMetrics(nil),
+			assert:  assertFactoryOptionWithMetrics(testRegistry),
+		},
 		{
 			name:   "WithConfig-Blank",
 			op:     WithConfig(Config{}),
@@ -132,6 +151,14 @@ func assertOptionWithClientTracer(expected opentracing.Tracer) optionAssertion {
 	}
 }
 
+func assertOptionWithClientMetrics(expected *metrics.Registry) optionAssertion {
+	return func(t *testing.T, c *Client) {
+		if c.metrics != expected {
+			t.Errorf("expected %p, got %p", expected, c.metrics)
+		}
+	}
+}
+
 func assertOptionWithTimeoutMs(expected int) optionAssertion {
 	return func(t *testing.T, c *Client) {
 		if c.config.TimeoutMs == nil {
@@ -182,6 +209,14 @@ func assertFactoryOptionWithTracer(expected opentracing.Tracer) factoryOptionAss
 	}
 }
 
+func assertFactoryOptionWithMetrics(expected *metrics.Registry) factoryOptionAssertion {
+	return func(t *testing.T, f *factory) {
+		if f.metrics != expected {
+			t.Errorf("expected %p, got %p", expected, f.metrics)
+		}
+	}
+}
+
 func assertFactoryOptionWithConfig(expected Config) factoryOptionAssertion {
 	return func(t *testing.T, f *factory) {
 		if ok := reflect.DeepEqual(f.config, expected); !ok {
@@ -0,0 +1,166 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// DefaultMaxBytes is the default limit of the size of a request body
+const DefaultMaxBytes = 1 << 20
+
+var errTrailingData = errors.New("trailing data after the request body")
+
+type config struct {
+	maxBytes      int64
+	unknownFields bool
+}
+
+// Option interface to identify functional options
+type Option func(c *config)
+
+// WithMaxBytes provides an Option to provide the limit of the size of a
+// request body.
+// Defaults to DefaultMaxBytes
+func WithMaxBytes(n int64) Option {
+	return func(c *config) {
+		c.maxBytes = n
+	}
+}
+
+// WithUnknownFields provides an Option to provide whether fields unknown to
+// the bound type are allowed.
+// Defaults to false
+func WithUnknownFields(allow bool) Option {
+	return func(c *config) {
+		c.unknownFields = allow
+	}
+}
+
+// Validator is implemented by bound types which validate themselves beyond
+// their validate struct tags
+type Validator interface {
+	Validate() error
+}
+
+// Bind decodes the body of r into a T with the Codec of its Content-Type and
+// validates it, see Validate. A T implementing Validator is then validated by
+// its Validate method. The errors returned are httperr errors: 415 for an
+// unsupported Content-Type, 413 for a body exceeding the size limit, 400 for a
+// malformed body or unknown fields, and 422 for a body failing validation.
+func Bind[T any](r *http.Request, opts ...Option) (T, error) {
+	var v T
+	c := config{maxBytes: DefaultMaxBytes}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	codec, ok := lookup(contentType)
+	if !ok {
+		detail := "Content-Type is required"
+		if contentType != "" {
+			mediaType, _, _ := mime.ParseMediaType(contentType)
+			detail = fmt.Sprintf("unsupported Content-Type %q", mediaType)
+		}
+		return v, httperr.New(http.StatusUnsupportedMediaType, detail)
+	}
+
+	if r.Body == nil {
+		return v, httperr.BadRequest("request body is empty")
+	}
+	body := &limitedReader{r: r.Body, n: c.maxBytes}
+	if err := codec.Decode(body, &v, !c.unknownFields); err != nil {
+		return v, decodeError(err, body)
+	}
+
+	if err := Validate(v); err != nil {
+		return v, err
+	}
+	if validator, ok := interface{}(&v).(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var e *httperr.Error
+			if errors.As(err, &e) {
+				return v, err
+			}
+			return v, httperr.Wrap(err, http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	return v, nil
+}
+
+// decodeError provides the httperr error of a failure to decode body
+func decodeError(err error, body *limitedReader) error {
+	if body.exceeded {
+		return httperr.Wrap(err, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", body.n))
+	}
+
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return httperr.BadRequest("request body is empty")
+	case errors.As(err, &syntax):
+		return httperr.Wrap(err, http.StatusBadRequest, fmt.Sprintf("malformed request body at offset %d", syntax.Offset))
+	case errors.As(err, &typ) && typ.Field != "":
+		return httperr.Wrap(err, http.StatusBadRequest, "invalid request body").
+			With("invalid_params", []InvalidParam{{Name: typ.Field, Reason: "must be " + article(typ.Type.Kind().String())}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return httperr.Wrap(err, http.StatusBadRequest, "invalid request body").
+			With("invalid_params", []InvalidParam{{Name: field, Reason: "is not allowed"}})
+	case errors.Is(err, errTrailingData):
+		return httperr.Wrap(err, http.StatusBadRequest, "request body must contain a single value")
+	}
+	return httperr.Wrap(err, http.StatusBadRequest, "malformed request body")
+}
+
+// article prefixes a kind of value with its indefinite article
+func article(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice", kind == "array":
+		return "an array"
+	case kind == "map", kind == "struct", kind == "ptr":
+		return "an object"
+	case kind == "bool":
+		return "a boolean"
+	}
+	return "a " + kind
+}
+
+// limitedReader reads at most n bytes, reporting whether more were available
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	read     int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.n {
+		l.exceeded = true
+		return 0, errTooLarge
+	}
+	if remaining := l.n + 1 - l.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.n {
+		l.exceeded = true
+		return n, errTooLarge
+	}
+	return n, err
+}
+
+var errTooLarge = errors.New("request body too large")
@@ -0,0 +1,196 @@
+package codec
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+type createUser struct {
+	Name  string `json:"name" xml:"name" validate:"required"`
+	Email string `json:"email" xml:"email" validate:"omitempty,email"`
+}
+
+func (u createUser) Validate() error {
+	if u.Name == "root" {
+		return errors.New("name is reserved")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []Option
+		expected    createUser
+		status      int
+		detail      string
+		params      []InvalidParam
+	}{
+		{
+			name:        "Valid",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"alice","email":"alice@example.com"}`,
+			expected:    createUser{Name: "alice", Email: "alice@example.com"},
+		},
+		{
+			name:        "JSONSuffix",
+			contentType: "application/merge-patch+json",
+			body:        `{"name":"alice"}`,
+			expected:    createUser{Name: "alice"},
+		},
+		{
+			name:   "MissingContentType",
+			body:   `{"name":"alice"}`,
+			status: http.StatusUnsupportedMediaType,
+			detail: "Content-Type is required",
+		},
+		{
+			name:        "UnsupportedContentType",
+			contentType: "text/plain",
+			body:        "alice",
+			status:      http.StatusUnsupportedMediaType,
+			detail:      `unsupported Content-Type "text/plain"`,
+		},
+		{
+			name:        "TooLarge",
+			contentType: "application/json",
+			body:        `{"name":"` + strings.Repeat("a", 64) + `"}`,
+			opts:        []Option{WithMaxBytes(32)},
+			status:      http.StatusRequestEntityTooLarge,
+			detail:      "request body exceeds 32 bytes",
+		},
+		{
+			name:        "Empty",
+			contentType: "application/json",
+			status:      http.StatusBadRequest,
+			detail:      "request body is empty",
+		},
+		{
+			name:        "Syntax",
+			contentType: "application/json",
+			body:        `{"name":}`,
+			status:      http.StatusBadRequest,
+			detail:      "malformed request body at offset 9",
+		},
+		{
+			name:        "Type",
+			contentType: "application/json",
+			body:        `{"name":42}`,
+			status:      http.StatusBadRequest,
+			detail:      "invalid request body",
+			params:      []InvalidParam{{Name: "name", Reason: "must be a string"}},
+		},
+		{
+			name:        "UnknownField",
+			contentType: "application/json",
+			body:        `{"name":"alice","admin":true}`,
+			status:      http.StatusBadRequest,
+			detail:      "invalid request body",
+			params:      []InvalidParam{{Name: "admin", Reason: "is not allowed"}},
+		},
+		{
+			name:        "UnknownFieldAllowed",
+			contentType: "application/json",
+			body:        `{"name":"alice","admin":true}`,
+			opts:        []Option{WithUnknownFields(true)},
+			expected:    createUser{Name: "alice"},
+		},
+		{
+			name:        "Trailing",
+			contentType: "application/json",
+			body:        `{"name":"alice"}{"name":"bob"}`,
+			status:      http.StatusBadRequest,
+			detail:      "request body must contain a single value",
+		},
+		{
+			name:        "Invalid",
+			contentType: "application/json",
+			body:        `{"email":"alice"}`,
+			status:      http.StatusUnprocessableEntity,
+			detail:      "request body failed validation",
+			params: []InvalidParam{
+				{Name: "name", Reason: "is required"},
+				{Name: "email", Reason: "must be an email address"},
+			},
+		},
+		{
+			name:        "Validator",
+			contentType: "application/json",
+			body:        `{"name":"root"}`,
+			status:      http.StatusUnprocessableEntity,
+			detail:      "name is reserved",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+
+			u, err := Bind[createUser](r, test.opts...)
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if u != test.expected {
+					t.Errorf("expected %+v, got %+v", test.expected, u)
+				}
+				return
+			}
+
+			var e *httperr.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected a httperr.Error, got %v", err)
+			}
+			if e.Status != test.status || e.Detail != test.detail {
+				t.Errorf("expected %d %q, got %d %q", test.status, test.detail, e.Status, e.Detail)
+			}
+			if params, _ := e.Extensions["invalid_params"].([]InvalidParam); !reflect.DeepEqual(params, test.params) {
+				t.Errorf("expected %v, got %v", test.params, params)
+			}
+		})
+	}
+}
+
+func TestBindXML(t *testing.T) {
+	withCodec(t, "application/xml", XML)
+
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`<createUser><name>alice</name></createUser>`))
+	r.Header.Set("Content-Type", "application/xml")
+
+	u, err := Bind[createUser](r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u.Name != "alice" {
+		t.Errorf("expected alice, got %+v", u)
+	}
+}
+
+// withCodec registers c for the duration of the test
+func withCodec(t *testing.T, mediaType string, c Codec) {
+	mu.RLock()
+	saved := make(map[string]Codec, len(codecs))
+	for k, v := range codecs {
+		saved[k] = v
+	}
+	savedOrder := append([]string(nil), order...)
+	mu.RUnlock()
+
+	Register(mediaType, c)
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		codecs, order = saved, savedOrder
+	})
+}
@@ -0,0 +1,161 @@
+// Package codec binds request bodies to typed values, validating them, and
+// writes typed responses in the media type negotiated with the client. Errors
+// are httperr errors, to be returned from a server.HandlerE.
+package codec
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec decodes request bodies and encodes responses of a media type
+type Codec interface {
+	// Decode decodes r into v. Unknown fields are rejected when strict.
+	Decode(r io.Reader, v interface{}, strict bool) error
+	// Encode encodes v to w
+	Encode(w io.Writer, v interface{}) error
+}
+
+// JSON is the Codec of application/json
+var JSON Codec = jsonCodec{}
+
+// XML is the Codec of application/xml. It is not registered by default.
+var XML Codec = xmlCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}, strict bool) error {
+	d := json.NewDecoder(r)
+	if strict {
+		d.DisallowUnknownFields()
+	}
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if d.More() {
+		return errTrailingData
+	}
+	return nil
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}, strict bool) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = map[string]Codec{"application/json": JSON}
+	// order is the preference of the registered media types, used when the
+	// client accepts several equally
+	order = []string{"application/json"}
+)
+
+// Register registers c as the Codec of mediaType, e.g.
+// Register("application/xml", XML). Media types with a +json suffix use the
+// application/json Codec unless registered.
+func Register(mediaType string, c Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+	mediaType = strings.ToLower(mediaType)
+	if _, ok := codecs[mediaType]; !ok {
+		order = append(order, mediaType)
+	}
+	codecs[mediaType] = c
+}
+
+// lookup provides the Codec of the Content-Type contentType
+func lookup(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if c, ok := codecs[mediaType]; ok {
+		return c, true
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		c, ok := codecs["application/json"]
+		return c, ok
+	}
+	return nil, false
+}
+
+// negotiate provides the registered media type most preferred by the Accept
+// header accept, and whether the response varies with it
+func negotiate(accept string) (string, Codec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	vary := len(order) > 1
+
+	if strings.TrimSpace(accept) == "" {
+		return order[0], codecs[order[0]], vary
+	}
+
+	type ranged struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []ranged
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, ranged{mediaType: mediaType, q: q})
+	}
+	// more specific ranges take precedence over wildcards
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	best, bestQ := "", 0.0
+	for _, mediaType := range order {
+		for _, r := range ranges {
+			if !matches(r.mediaType, mediaType) {
+				continue
+			}
+			if r.q > bestQ {
+				best, bestQ = mediaType, r.q
+			}
+			break
+		}
+	}
+	if best == "" {
+		return "", nil, vary
+	}
+	return best, codecs[best], vary
+}
+
+func matches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// Respond writes v with status in the registered media type most preferred by
+// the Accept header of r, defaulting to application/json. A 406 httperr error
+// is returned when no registered media type is acceptable, and nothing is
+// written when v cannot be encoded.
+func Respond[T any](w http.ResponseWriter, r *http.Request, status int, v T) error {
+	mediaType, c, vary := negotiate(r.Header.Get("Accept"))
+	if vary {
+		w.Header().Add("Vary", "Accept")
+	}
+	if c == nil {
+		return httperr.New(http.StatusNotAcceptable, fmt.Sprintf("no acceptable media type for %q", r.Header.Get("Accept")))
+	}
+
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, v); err != nil {
+		return fmt.Errorf("encoding %s response: %w", mediaType, err)
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name        string
+		xml         bool
+		accept      string
+		status      int
+		contentType string
+		body        string
+		vary        string
+		err         int
+	}{
+		{
+			name:        "Default",
+			status:      http.StatusCreated,
+			contentType: "application/json",
+			body:        "{\"name\":\"alice\",\"email\":\"\"}\n",
+		},
+		{
+			name:        "Wildcard",
+			accept:      "text/html, */*;q=0.8",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        "{\"name\":\"alice\",\"email\":\"\"}\n",
+		},
+		{
+			name:   "NotAcceptable",
+			accept: "text/html",
+			err:    http.StatusNotAcceptable,
+		},
+		{
+			name:        "XML",
+			xml:         true,
+			accept:      "application/json;q=0.5, application/xml",
+			status:      http.StatusOK,
+			contentType: "application/xml",
+			body:        "<createUser><name>alice</name><email></email></createUser>",
+			vary:        "Accept",
+		},
+		{
+			name:        "PreferenceOrder",
+			xml:         true,
+			accept:      "application/*",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        "{\"name\":\"alice\",\"email\":\"\"}\n",
+			vary:        "Accept",
+		},
+		{
+			name:   "NoContent",
+			status: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.xml {
+				withCodec(t, "application/xml", XML)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			err := Respond(rr, r, test.status, createUser{Name: "alice"})
+			if test.err != 0 {
+				var e *httperr.Error
+				if !errors.As(err, &e) || e.Status != test.err {
+					t.Errorf("expected a %d error, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != test.contentType {
+				t.Errorf("expected content type %q, got %q", test.contentType, ct)
+			}
+			if rr.Body.String() != test.body {
+				t.Errorf("expected body %q, got %q", test.body, rr.Body.String())
+			}
+			if vary := rr.Header().Get("Vary"); vary != test.vary {
+				t.Errorf("expected vary %q, got %q", test.vary, vary)
+			}
+		})
+	}
+}
+
+func TestRespondEncodingError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	err := Respond(rr, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, func() {})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if rr.Body.Len() != 0 || rr.Header().Get("Content-Type") != "" {
+		t.Errorf("expected nothing written, got %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,212 @@
+package codec
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// InvalidParam is a field failing validation, rendered in the invalid_params
+// member of a problem response
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Validate validates the fields of the struct v, or the struct v points to,
+// by their validate tags, e.g. `validate:"required,max=64"`. The rules are:
+//
+//	required  the field is not its zero value
+//	min=n     strings have at least n characters, slices and maps at least n
+//	          elements, and numbers are at least n
+//	max=n     the upper bound counterpart of min
+//	len=n     strings have exactly n characters, slices and maps n elements
+//	oneof=a b the field is one of the space separated values
+//	email     the field is an email address
+//	omitempty the other rules are skipped when the field is its zero value
+//
+// Rules other than required are skipped for nil pointers. Nested structs,
+// and slices and maps of structs, are validated too. Fields are named by their
+// json tag. A 422 httperr error listing every InvalidParam is returned when
+// validation fails. Validate panics on unknown rules.
+func Validate(v interface{}) error {
+	var params []InvalidParam
+	validateValue(reflect.ValueOf(v), "", &params)
+	if len(params) == 0 {
+		return nil
+	}
+	return httperr.New(http.StatusUnprocessableEntity, "request body failed validation").
+		With("invalid_params", params)
+}
+
+func validateValue(v reflect.Value, path string, params *[]InvalidParam) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			name, ok := fieldName(f)
+			if !ok {
+				continue
+			}
+			fieldPath := join(path, name)
+			if f.Anonymous && f.Tag.Get("json") == "" {
+				fieldPath = path
+			}
+			fv := v.Field(i)
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if reason := validateField(fv, tag); reason != "" {
+					*params = append(*params, InvalidParam{Name: fieldPath, Reason: reason})
+					continue
+				}
+			}
+			validateValue(fv, fieldPath, params)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), params)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), params)
+		}
+	}
+}
+
+// fieldName provides the name of f in the json encoding, and whether it is
+// encoded at all
+func fieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validateField provides the reason v fails the rules of tag, or an empty
+// string when it passes them
+func validateField(v reflect.Value, tag string) string {
+	rules := strings.Split(tag, ",")
+	empty := v.IsZero()
+	for _, rule := range rules {
+		switch {
+		case rule == "required" && empty:
+			return "is required"
+		case rule == "omitempty" && empty:
+			return ""
+		}
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		var reason string
+		switch name {
+		case "required", "omitempty", "":
+		case "min":
+			reason = bound(v, rule, arg, func(n, limit float64) bool { return n >= limit }, "at least")
+		case "max":
+			reason = bound(v, rule, arg, func(n, limit float64) bool { return n <= limit }, "at most")
+		case "len":
+			reason = bound(v, rule, arg, func(n, limit float64) bool { return n == limit }, "exactly")
+		case "oneof":
+			options := strings.Fields(arg)
+			s := fmt.Sprint(v.Interface())
+			found := false
+			for _, o := range options {
+				if o == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				reason = "must be one of " + strings.Join(options, ", ")
+			}
+		case "email":
+			if s, ok := v.Interface().(string); !ok || !isEmail(s) {
+				reason = "must be an email address"
+			}
+		default:
+			panic(fmt.Sprintf("codec: unknown validation rule %q", rule))
+		}
+		if reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// bound checks the size of v against the limit arg of rule
+func bound(v reflect.Value, rule, arg string, ok func(n, limit float64) bool, relation string) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("codec: invalid validation rule %q", rule))
+	}
+
+	var n float64
+	var unit string
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		panic(fmt.Sprintf("codec: validation rule %q does not apply to %s", rule, v.Kind()))
+	}
+
+	if ok(n, limit) {
+		return ""
+	}
+	if unit == "" {
+		return fmt.Sprintf("must be %s %s", relation, arg)
+	}
+	return fmt.Sprintf("must have %s %s%s", relation, arg, unit)
+}
+
+func isEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s
+}
@@ -0,0 +1,141 @@
+package codec
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+type address struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"len=2"`
+}
+
+type user struct {
+	Name      string            `json:"name" validate:"required,min=2,max=8"`
+	Email     string            `json:"email" validate:"email"`
+	Age       int               `json:"age" validate:"min=18,max=130"`
+	Role      string            `json:"role" validate:"oneof=admin user"`
+	Level     int               `json:"level" validate:"oneof=1 2"`
+	Seats     int               `json:"seats" validate:"min=1"`
+	Bio       string            `json:"bio" validate:"omitempty,min=8"`
+	Tags      []string          `json:"tags" validate:"max=2"`
+	Nickname  *string           `json:"nickname" validate:"min=3"`
+	Address   *address          `json:"address" validate:"required"`
+	Previous  []address         `json:"previous"`
+	Labels    map[string]string `json:"labels" validate:"max=1"`
+	Untagged  string
+	Ignored   string `json:"-" validate:"required"`
+	unexposed string
+}
+
+func TestValidate(t *testing.T) {
+	short := "ab"
+	valid := func() user {
+		return user{Name: "alice", Email: "alice@example.com", Age: 30, Role: "admin", Level: 1, Seats: 1, Address: &address{City: "Berlin", Country: "DE"}}
+	}
+
+	tests := []struct {
+		name     string
+		user     func() user
+		expected []InvalidParam
+	}{
+		{name: "Valid", user: valid},
+		{
+			name: "Required",
+			user: func() user { u := valid(); u.Name = ""; u.Address = nil; return u },
+			expected: []InvalidParam{
+				{Name: "name", Reason: "is required"},
+				{Name: "address", Reason: "is required"},
+			},
+		},
+		{
+			name: "Bounds",
+			user: func() user {
+				u := valid()
+				u.Name, u.Age, u.Tags, u.Nickname = "a", 12, []string{"a", "b", "c"}, &short
+				u.Labels = map[string]string{"a": "", "b": ""}
+				return u
+			},
+			expected: []InvalidParam{
+				{Name: "name", Reason: "must have at least 2 characters"},
+				{Name: "age", Reason: "must be at least 18"},
+				{Name: "tags", Reason: "must have at most 2 items"},
+				{Name: "nickname", Reason: "must have at least 3 characters"},
+				{Name: "labels", Reason: "must have at most 1 items"},
+			},
+		},
+		{
+			name: "Zero",
+			user: func() user { u := valid(); u.Age, u.Level, u.Seats = 0, 0, 0; return u },
+			expected: []InvalidParam{
+				{Name: "age", Reason: "must be at least 18"},
+				{Name: "level", Reason: "must be one of 1, 2"},
+				{Name: "seats", Reason: "must be at least 1"},
+			},
+		},
+		{
+			name: "OmitEmpty",
+			user: func() user { u := valid(); u.Bio = "short"; return u },
+			expected: []InvalidParam{
+				{Name: "bio", Reason: "must have at least 8 characters"},
+			},
+		},
+		{
+			name: "Format",
+			user: func() user { u := valid(); u.Email, u.Role = "alice", "root"; return u },
+			expected: []InvalidParam{
+				{Name: "email", Reason: "must be an email address"},
+				{Name: "role", Reason: "must be one of admin, user"},
+			},
+		},
+		{
+			name: "Nested",
+			user: func() user {
+				u := valid()
+				u.Address.Country = "DEU"
+				u.Previous = []address{{City: "Paris", Country: "FR"}, {Country: "FR"}}
+				return u
+			},
+			expected: []InvalidParam{
+				{Name: "address.country", Reason: "must have exactly 2 characters"},
+				{Name: "previous[1].city", Reason: "is required"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := test.user()
+			err := Validate(&u)
+			if test.expected == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			var e *httperr.Error
+			if !errors.As(err, &e) || e.Status != http.StatusUnprocessableEntity {
+				t.Fatalf("expected a 422 error, got %v", err)
+			}
+			if params := e.Extensions["invalid_params"]; !reflect.DeepEqual(params, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, params)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	_ = Validate(struct {
+		Name string `validate:"uppercase"`
+	}{Name: "a"})
+}
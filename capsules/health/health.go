@@ -0,0 +1,260 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Checker is the interface to check the health of a dependency
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// NewChecker provides a Checker given a name and a check function.
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, check: check}
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string                    { return c.name }
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// Status represents the outcome of a check or set of checks
+type Status string
+
+const (
+	// StatusOK indicates every check passed
+	StatusOK Status = "ok"
+	// StatusDegraded indicates only non-critical checks failed
+	StatusDegraded Status = "degraded"
+	// StatusFail indicates a critical check failed
+	StatusFail Status = "fail"
+)
+
+// ErrTimeout is reported when a check does not complete within its timeout
+var ErrTimeout = errors.New("health check timed out")
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string        `json:"name"`
+	Status    Status        `json:"status"`
+	Critical  bool          `json:"critical"`
+	Latency   time.Duration `json:"-"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checkedAt"`
+}
+
+// MarshalJSON renders the latency as a human readable duration
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Latency string `json:"latency"`
+	}{result(r), r.Latency.String()})
+}
+
+// Report is the outcome of every check in a Registry
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// StatusCode provides the HTTP status code representing the Report. Failed
+// critical checks are reported as 503 while degraded reports remain 200.
+func (r Report) StatusCode() int {
+	if r.Status == StatusFail {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// Registry holds a set of Checkers which are run concurrently
+type Registry struct {
+	config config
+
+	mu     sync.RWMutex
+	checks []*check
+}
+
+// NewRegistry instantiates a Registry. Options can be passed to overwrite
+// default configurations.
+func NewRegistry(opts ...Option) *Registry {
+	c := defaultConfig()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+	return &Registry{config: c}
+}
+
+// Configure applies opts to the Registry, changing the defaults of every check
+// which does not overwrite them, including the checks already registered.
+func (r *Registry) Configure(opts ...Option) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&r.config)
+		}
+	}
+}
+
+// Register adds a Checker to the Registry. CheckOptions can be passed to
+// overwrite the Registry defaults for this check.
+func (r *Registry) Register(c Checker, opts ...CheckOption) {
+	chk := &check{
+		Checker:  c,
+		critical: true,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(chk)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, chk)
+}
+
+// Len provides the number of registered checks
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.checks)
+}
+
+// Run executes every registered check concurrently and provides a Report of
+// their results in registration order.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]*check, len(r.checks))
+	copy(checks, r.checks)
+	defaults := r.config
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, defaults)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// Handler provides a http.Handler which runs the registered checks and writes
+// the Report as JSON.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(report.StatusCode())
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+type check struct {
+	Checker
+	critical bool
+	// timeout and cacheTTL overwrite the defaults of the Registry when set
+	timeout  *time.Duration
+	cacheTTL *time.Duration
+
+	mu     sync.Mutex
+	cached *Result
+}
+
+// run runs the check, unless a cached result is still fresh. The lock is only
+// held to read and write the cache, so a hung check never blocks other probes.
+func (c *check) run(ctx context.Context, defaults config) Result {
+	timeout, cacheTTL := defaults.timeout, defaults.cacheTTL
+	if c.timeout != nil {
+		timeout = *c.timeout
+	}
+	if c.cacheTTL != nil {
+		cacheTTL = *c.cacheTTL
+	}
+
+	c.mu.Lock()
+	cached := c.cached
+	c.mu.Unlock()
+	if cached != nil && time.Since(cached.CheckedAt) < cacheTTL {
+		return *cached
+	}
+
+	start := time.Now()
+	err := c.checkWithTimeout(ctx, timeout)
+
+	result := Result{
+		Name:      c.Name(),
+		Status:    StatusOK,
+		Critical:  c.critical,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	if cacheTTL > 0 {
+		c.mu.Lock()
+		if c.cached == nil || c.cached.CheckedAt.Before(result.CheckedAt) {
+			c.cached = &result
+		}
+		c.mu.Unlock()
+	}
+
+	return result
+}
+
+func (c *check) checkWithTimeout(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Check(ctx)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,200 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	pass := NewChecker("pass", func(context.Context) error { return nil })
+	fail := NewChecker("fail", func(context.Context) error { return errors.New("boom") })
+	slow := NewChecker("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		register func(r *Registry)
+		status   Status
+		code     int
+		errors   []string
+	}{
+		{
+			name:     "Empty",
+			register: func(r *Registry) {},
+			status:   StatusOK,
+			code:     http.StatusOK,
+			errors:   []string{},
+		},
+		{
+			name: "Pass",
+			register: func(r *Registry) {
+				r.Register(pass)
+			},
+			status: StatusOK,
+			code:   http.StatusOK,
+			errors: []string{""},
+		},
+		{
+			name: "Critical",
+			register: func(r *Registry) {
+				r.Register(pass)
+				r.Register(fail)
+			},
+			status: StatusFail,
+			code:   http.StatusServiceUnavailable,
+			errors: []string{"", "boom"},
+		},
+		{
+			name: "NonCritical",
+			register: func(r *Registry) {
+				r.Register(pass)
+				r.Register(fail, WithCritical(false))
+			},
+			status: StatusDegraded,
+			code:   http.StatusOK,
+			errors: []string{"", "boom"},
+		},
+		{
+			name: "Timeout",
+			register: func(r *Registry) {
+				r.Register(slow, WithCheckTimeout(10*time.Millisecond))
+			},
+			status: StatusFail,
+			code:   http.StatusServiceUnavailable,
+			errors: []string{ErrTimeout.Error()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewRegistry()
+			test.register(r)
+
+			w := httptest.NewRecorder()
+			r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if w.Code != test.code {
+				t.Errorf("expected %d, got %d", test.code, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+
+			var report struct {
+				Status Status `json:"status"`
+				Checks []struct {
+					Name    string `json:"name"`
+					Latency string `json:"latency"`
+					Error   string `json:"error"`
+				} `json:"checks"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if report.Status != test.status {
+				t.Errorf("expected %q, got %q", test.status, report.Status)
+			}
+			if len(report.Checks) != len(test.errors) {
+				t.Fatalf("expected %d checks, got %d", len(test.errors), len(report.Checks))
+			}
+			for i, expected := range test.errors {
+				if report.Checks[i].Error != expected {
+					t.Errorf("expected error %q, got %q", expected, report.Checks[i].Error)
+				}
+				if _, err := time.ParseDuration(report.Checks[i].Latency); err != nil {
+					t.Errorf("unexpected latency %q: %s", report.Checks[i].Latency, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < 5; i++ {
+		r.Register(NewChecker("sleep", func(context.Context) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}))
+	}
+
+	start := time.Now()
+	r.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("expected checks to run concurrently, took %s", elapsed)
+	}
+}
+
+func TestRunCached(t *testing.T) {
+	var calls int32
+	c := NewChecker("counter", func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	r := NewRegistry(WithCacheTTL(time.Minute))
+	r.Register(c)
+	r.Register(c, WithCheckCacheTTL(0))
+
+	for i := 0; i < 3; i++ {
+		r.Run(context.Background())
+	}
+
+	if actual := atomic.LoadInt32(&calls); actual != 4 {
+		t.Errorf("expected 4 calls, got %d", actual)
+	}
+}
+
+func TestRunHungCheck(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	r := NewRegistry(WithTimeout(0))
+	r.Register(NewChecker("hung", func(context.Context) error {
+		<-release
+		return nil
+	}))
+
+	// a probe waiting on the hung check does not block later probes
+	pending, cancelPending := context.WithCancel(context.Background())
+	defer cancelPending()
+	go r.Run(pending)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if report := r.Run(ctx); report.Status != StatusFail {
+		t.Errorf("expected %q, got %q", StatusFail, report.Status)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the probe to return once its context is done, took %s", elapsed)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	var calls int32
+	r := NewRegistry()
+	r.Register(NewChecker("counter", func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}))
+
+	r.Configure(WithCacheTTL(time.Minute))
+	for i := 0; i < 3; i++ {
+		r.Run(context.Background())
+	}
+
+	if actual := atomic.LoadInt32(&calls); actual != 1 {
+		t.Errorf("expected 1 call, got %d", actual)
+	}
+}
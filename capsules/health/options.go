@@ -0,0 +1,68 @@
+package health
+
+import (
+	"time"
+)
+
+type config struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+}
+
+// defaultConfig provides a config initialized with default values
+func defaultConfig() config {
+	return config{
+		timeout: time.Second,
+	}
+}
+
+// Option interface to identify functional options
+type Option func(c *config)
+
+// WithTimeout provides an Option to provide the default duration a check may
+// run before it is reported as failed. A zero duration disables the timeout.
+// Defaults to 1 second
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithCacheTTL provides an Option to provide the default duration for which a
+// check result is reused before the check is run again.
+// Defaults to 0, checks are run on every probe
+func WithCacheTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.cacheTTL = d
+	}
+}
+
+// CheckOption interface to identify functional options for a single check
+type CheckOption func(c *check)
+
+// WithCritical provides a CheckOption to provide whether a failing check fails
+// the Report or only degrades it.
+// Defaults to true
+func WithCritical(critical bool) CheckOption {
+	return func(c *check) {
+		c.critical = critical
+	}
+}
+
+// WithCheckTimeout provides a CheckOption to provide the duration the check may
+// run before it is reported as failed.
+// Defaults to the Registry timeout
+func WithCheckTimeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = &d
+	}
+}
+
+// WithCheckCacheTTL provides a CheckOption to provide the duration for which
+// the check result is reused.
+// Defaults to the Registry cache TTL
+func WithCheckCacheTTL(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.cacheTTL = &d
+	}
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOption(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       Option
+		expected config
+	}{
+		{
+			name:     "WithTimeout",
+			op:       WithTimeout(time.Minute),
+			expected: config{timeout: time.Minute},
+		},
+		{
+			name:     "WithCacheTTL",
+			op:       WithCacheTTL(time.Minute),
+			expected: config{timeout: time.Second, cacheTTL: time.Minute},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultConfig()
+			test.op(&c)
+			if c != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, c)
+			}
+		})
+	}
+}
+
+func TestCheckOption(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     CheckOption
+		assert func(t *testing.T, c *check)
+	}{
+		{
+			name: "WithCritical",
+			op:   WithCritical(false),
+			assert: func(t *testing.T, c *check) {
+				if c.critical {
+					t.Error("expected check to be non-critical")
+				}
+			},
+		},
+		{
+			name: "WithCheckTimeout",
+			op:   WithCheckTimeout(time.Minute),
+			assert: func(t *testing.T, c *check) {
+				if c.timeout == nil || *c.timeout != time.Minute {
+					t.Errorf("expected %s, got %v", time.Minute, c.timeout)
+				}
+			},
+		},
+		{
+			name: "WithCheckCacheTTL",
+			op:   WithCheckCacheTTL(time.Minute),
+			assert: func(t *testing.T, c *check) {
+				if c.cacheTTL == nil || *c.cacheTTL != time.Minute {
+					t.Errorf("expected %s, got %v", time.Minute, c.cacheTTL)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &check{critical: true}
+			test.op(c)
+			test.assert(t, c)
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Package httperr provides errors carrying an HTTP status and renders them as
+// RFC 7807 problem details.
+package httperr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/requestid"
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Extensions are rendered
+// alongside the standard members.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// MarshalJSON renders the members of p, omitting those which are empty
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// Error is an error returned to the client as a Problem. The wrapped Err is
+// for logs and is never rendered.
+type Error struct {
+	Problem
+	Err error
+}
+
+var _ StatusCoder = (*Error)(nil)
+
+// New provides an Error with status and a detail safe to return to the client
+func New(status int, detail string) *Error {
+	return &Error{Problem: Problem{Status: status, Detail: detail}}
+}
+
+// Wrap provides an Error with status and detail, wrapping the cause err
+func Wrap(err error, status int, detail string) *Error {
+	return &Error{Problem: Problem{Status: status, Detail: detail}, Err: err}
+}
+
+// BadRequest provides a 400 Error
+func BadRequest(detail string) *Error { return New(http.StatusBadRequest, detail) }
+
+// Unauthorized provides a 401 Error
+func Unauthorized(detail string) *Error { return New(http.StatusUnauthorized, detail) }
+
+// Forbidden provides a 403 Error
+func Forbidden(detail string) *Error { return New(http.StatusForbidden, detail) }
+
+// NotFound provides a 404 Error
+func NotFound(detail string) *Error { return New(http.StatusNotFound, detail) }
+
+// Conflict provides a 409 Error
+func Conflict(detail string) *Error { return New(http.StatusConflict, detail) }
+
+// UnprocessableEntity provides a 422 Error
+func UnprocessableEntity(detail string) *Error { return New(http.StatusUnprocessableEntity, detail) }
+
+// With provides a copy of e with the extension member key
+func (e *Error) With(key string, value interface{}) *Error {
+	c := *e
+	c.Extensions = make(map[string]interface{}, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		c.Extensions[k] = v
+	}
+	c.Extensions[key] = value
+	return &c
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap provides the cause of e
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode provides the HTTP status of e
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// StatusCoder is implemented by errors which carry their HTTP status
+type StatusCoder interface {
+	StatusCode() int
+}
+
+var (
+	mu       sync.RWMutex
+	statuses = []registered{
+		{target: http.ErrHandlerTimeout, status: http.StatusServiceUnavailable},
+		{target: context.DeadlineExceeded, status: http.StatusGatewayTimeout},
+	}
+)
+
+type registered struct {
+	target error
+	status int
+}
+
+// RegisterStatus maps errors matching target, as reported by errors.Is, to
+// status. Errors registered later take precedence.
+func RegisterStatus(target error, status int) {
+	mu.Lock()
+	defer mu.Unlock()
+	statuses = append(statuses, registered{target: target, status: status})
+}
+
+// StatusOf provides the HTTP status of err: the status of the first
+// StatusCoder it wraps, the status registered for an error it matches, or
+// 500.
+func StatusOf(err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if errors.Is(err, statuses[i].target) {
+			return statuses[i].status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// ProblemOf provides the Problem returned to the client for err. Only the
+// Problem of an Error is rendered, other errors are reduced to their status.
+func ProblemOf(err error) Problem {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Problem
+	}
+	return Problem{Status: StatusOf(err)}
+}
+
+// WriteError writes the Problem of err, see ProblemOf and Write
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	Write(w, r, ProblemOf(err))
+}
+
+// Write writes p as application/problem+json, adding the trace_id and
+// request_id of r as extension members. The type defaults to "about:blank"
+// and the title to the text of the status.
+func Write(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	ext := make(map[string]interface{}, len(p.Extensions)+2)
+	for k, v := range p.Extensions {
+		ext[k] = v
+	}
+	if id := TraceID(r.Context()); id != "" {
+		ext["trace_id"] = id
+	}
+	if id, ok := requestid.FromContext(r.Context()); ok {
+		ext["request_id"] = id
+	}
+	p.Extensions = ext
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// TraceID provides the trace id of the span in ctx. OpenTracing does not
+// expose trace ids, so the TraceID method or field provided by most tracers is
+// used.
+func TraceID(ctx context.Context) string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+
+	v := reflect.ValueOf(span.Context())
+	if m := v.MethodByName("TraceID"); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+		return fmt.Sprint(m.Call(nil)[0].Interface())
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("TraceID"); f.IsValid() && f.CanInterface() {
+			return fmt.Sprint(f.Interface())
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package httperr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.adenix.dev/adderall/capsules/requestid"
+)
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "teapot" }
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+func TestStatusOf(t *testing.T) {
+	errGone := errors.New("gone")
+	RegisterStatus(errGone, http.StatusGone)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "Error", err: NotFound("no user"), expected: http.StatusNotFound},
+		{name: "Wrapped", err: fmt.Errorf("loading: %w", Conflict("exists")), expected: http.StatusConflict},
+		{name: "StatusCoder", err: fmt.Errorf("brewing: %w", teapotError{}), expected: http.StatusTeapot},
+		{name: "Registered", err: fmt.Errorf("loading: %w", errGone), expected: http.StatusGone},
+		{name: "Deadline", err: context.DeadlineExceeded, expected: http.StatusGatewayTimeout},
+		{name: "Unknown", err: errors.New("boom"), expected: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := StatusOf(test.err); status != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, status)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := Wrap(cause, http.StatusConflict, "user exists")
+
+	if !errors.Is(err, cause) {
+		t.Error("expected the cause to be wrapped")
+	}
+	if expected := "409 Conflict: user exists: duplicate key"; err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+
+	with := err.With("field", "email")
+	if with.Extensions["field"] != "email" || err.Extensions != nil {
+		t.Errorf("expected a copy with the extension, got %v and %v", with.Extensions, err.Extensions)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		expected map[string]interface{}
+	}{
+		{
+			name:   "Error",
+			err:    BadRequest("invalid name").With("field", "name"),
+			status: http.StatusBadRequest,
+			expected: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Bad Request",
+				"status":     float64(http.StatusBadRequest),
+				"detail":     "invalid name",
+				"field":      "name",
+				"request_id": "abc",
+			},
+		},
+		{
+			name:   "Hidden",
+			err:    Wrap(errors.New("connection refused"), http.StatusServiceUnavailable, ""),
+			status: http.StatusServiceUnavailable,
+			expected: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Service Unavailable",
+				"status":     float64(http.StatusServiceUnavailable),
+				"request_id": "abc",
+			},
+		},
+		{
+			name:   "Unknown",
+			err:    errors.New("secret"),
+			status: http.StatusInternalServerError,
+			expected: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Internal Server Error",
+				"status":     float64(http.StatusInternalServerError),
+				"request_id": "abc",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.WithContext(requestid.NewContext(r.Context(), "abc"))
+			rr := httptest.NewRecorder()
+			WriteError(rr, r, test.err)
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+				t.Errorf("expected %q, got %q", ContentType, ct)
+			}
+
+			var actual map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWriteTraceID(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+
+	rr := httptest.NewRecorder()
+	Write(rr, r, Problem{Status: http.StatusNotFound, Extensions: map[string]interface{}{"status": "ignored"}})
+
+	var actual map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := fmt.Sprint(span.Context().(mocktracer.MockSpanContext).TraceID)
+	if actual["trace_id"] != expected {
+		t.Errorf("expected trace id %q, got %v", expected, actual["trace_id"])
+	}
+	if actual["status"] != float64(http.StatusNotFound) {
+		t.Errorf("expected extensions not to override the status, got %v", actual["status"])
+	}
+}
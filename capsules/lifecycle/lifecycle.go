@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Component is the interface for long running parts of an App, e.g. servers
+// and background workers. Start blocks until the component fails or is
+// stopped, Stop asks a running component to finish.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// NewComponent provides a Component given start and stop functions. A nil stop
+// function is treated as a noop, leaving the component to finish once the
+// context passed to start is cancelled.
+func NewComponent(start, stop func(ctx context.Context) error) Component {
+	if stop == nil {
+		stop = func(context.Context) error { return nil }
+	}
+	return componentFunc{start: start, stop: stop}
+}
+
+type componentFunc struct {
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+func (c componentFunc) Start(ctx context.Context) error { return c.start(ctx) }
+func (c componentFunc) Stop(ctx context.Context) error  { return c.stop(ctx) }
+
+// App runs a set of Components together. App owns signal handling and the root
+// context passed to every Component.
+type App struct {
+	logger     Logger
+	config     config
+	components []Component
+	mu         sync.Mutex
+}
+
+// NewApp instantiates an App. Options can be passed to overwrite default
+// configurations.
+func NewApp(opts ...Option) *App {
+	a := &App{
+		logger: NoopLogger{},
+		config: defaultConfig(),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(a)
+		}
+	}
+
+	return a
+}
+
+// Add registers Components to be run by the App. Components are started
+// concurrently, as Start blocks for as long as a Component runs, so no
+// Component may rely on another having started first. They are stopped one at
+// a time in reverse registration order.
+func (a *App) Add(components ...Component) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, c := range components {
+		if c != nil {
+			a.components = append(a.components, c)
+		}
+	}
+}
+
+// Run starts every Component concurrently and blocks until a signal is
+// received, ctx is cancelled, or a Component fails. Every Component is then stopped and the
+// first error encountered is returned.
+func (a *App) Run(ctx context.Context) error {
+	a.mu.Lock()
+	components := make([]Component, len(a.components))
+	copy(components, a.components)
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	quit := make(chan os.Signal, 1)
+	if len(a.config.signals) > 0 {
+		signal.Notify(quit, a.config.signals...)
+		defer signal.Stop(quit)
+	}
+
+	type exit struct {
+		component Component
+		err       error
+	}
+
+	exits := make(chan exit, len(components))
+	for _, c := range components {
+		go func(c Component) {
+			exits <- exit{component: c, err: c.Start(ctx)}
+		}(c)
+	}
+	a.logger.InfoCtx(ctx, "app started", "components", len(components))
+
+	var err error
+	running := len(components)
+wait:
+	for running > 0 || len(components) == 0 {
+		select {
+		case sig := <-quit:
+			a.logger.InfoCtx(ctx, "signal received", "signal", sig)
+			break wait
+		case <-ctx.Done():
+			a.logger.InfoCtx(ctx, "context done", "error", ctx.Err())
+			break wait
+		case e := <-exits:
+			running--
+			if e.err != nil {
+				a.logger.ErrorCtx(ctx, "component failed", "component", name(e.component), "error", e.err)
+				err = e.err
+				break wait
+			}
+			a.logger.InfoCtx(ctx, "component finished", "component", name(e.component))
+		}
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), a.config.stopTimeout)
+	defer stopCancel()
+
+	for i := len(components) - 1; i >= 0; i-- {
+		if stopErr := components[i].Stop(stopCtx); stopErr != nil {
+			a.logger.ErrorCtx(ctx, "component failed to stop", "component", name(components[i]), "error", stopErr)
+			if err == nil {
+				err = stopErr
+			}
+		}
+	}
+	cancel()
+
+	for ; running > 0; running-- {
+		select {
+		case e := <-exits:
+			if e.err != nil && err == nil {
+				err = e.err
+			}
+		case <-stopCtx.Done():
+			a.logger.ErrorCtx(ctx, "components did not exit before stop timeout", "remaining", running)
+			if err == nil {
+				err = fmt.Errorf("%d components did not exit: %w", running, stopCtx.Err())
+			}
+			return err
+		}
+	}
+
+	a.logger.InfoCtx(ctx, "app stopped")
+	return err
+}
+
+func name(c Component) string {
+	if s, ok := c.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", c)
+}
@@ -0,0 +1,153 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testComponent records the order in which components are stopped
+type testComponent struct {
+	name    string
+	err     error
+	stopErr error
+	stopped chan struct{}
+	order   *[]string
+	mu      *sync.Mutex
+}
+
+func newTestComponent(name string, order *[]string, mu *sync.Mutex) *testComponent {
+	return &testComponent{name: name, stopped: make(chan struct{}), order: order, mu: mu}
+}
+
+func (c *testComponent) Start(ctx context.Context) error {
+	if c.err != nil {
+		return c.err
+	}
+	<-c.stopped
+	return nil
+}
+
+func (c *testComponent) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	*c.order = append(*c.order, c.name)
+	c.mu.Unlock()
+	close(c.stopped)
+	return c.stopErr
+}
+
+func (c *testComponent) String() string { return c.name }
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(a, b, c *testComponent)
+		trigger  func(cancel context.CancelFunc)
+		expected error
+	}{
+		{
+			name:    "Cancel",
+			trigger: func(cancel context.CancelFunc) { cancel() },
+		},
+		{
+			name: "Signal",
+			trigger: func(context.CancelFunc) {
+				_ = syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+			},
+		},
+		{
+			name: "FailFast",
+			setup: func(a, b, c *testComponent) {
+				b.err = errors.New("boom")
+			},
+			trigger:  func(context.CancelFunc) {},
+			expected: errors.New("boom"),
+		},
+		{
+			name: "StopError",
+			setup: func(a, b, c *testComponent) {
+				a.stopErr = errors.New("stop")
+			},
+			trigger:  func(cancel context.CancelFunc) { cancel() },
+			expected: errors.New("stop"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var order []string
+			var mu sync.Mutex
+			a := newTestComponent("a", &order, &mu)
+			b := newTestComponent("b", &order, &mu)
+			c := newTestComponent("c", &order, &mu)
+			if test.setup != nil {
+				test.setup(a, b, c)
+			}
+
+			app := NewApp(WithSignals(syscall.SIGUSR1), WithComponents(a, b), WithStopTimeout(time.Second))
+			app.Add(c)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errs := make(chan error, 1)
+			go func() {
+				errs <- app.Run(ctx)
+			}()
+
+			time.Sleep(10 * time.Millisecond)
+			test.trigger(cancel)
+
+			var err error
+			select {
+			case err = <-errs:
+			case <-time.After(time.Second):
+				t.Fatal("expected app to stop")
+			}
+
+			if (err == nil) != (test.expected == nil) || (err != nil && err.Error() != test.expected.Error()) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(order) != 3 || order[0] != "c" || order[1] != "b" || order[2] != "a" {
+				t.Errorf("expected components stopped in reverse order, got %v", order)
+			}
+		})
+	}
+}
+
+func TestRunStopTimeout(t *testing.T) {
+	stuck := NewComponent(func(context.Context) error {
+		select {}
+	}, nil)
+
+	app := NewApp(WithSignals(), WithComponents(stuck), WithStopTimeout(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := app.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestNewComponent(t *testing.T) {
+	c := NewComponent(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}, nil)
+
+	app := NewApp(WithSignals(), WithComponents(c))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := app.Run(ctx); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
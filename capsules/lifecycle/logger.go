@@ -0,0 +1,22 @@
+package lifecycle
+
+import (
+	"context"
+)
+
+// Logger is a local interface for logging functionality
+type Logger interface {
+	InfoCtx(ctx context.Context, msg string, keysAndValues ...interface{})
+	ErrorCtx(ctx context.Context, msg string, keysAndValues ...interface{})
+}
+
+// NoopLogger is a noop logger implementation.
+type NoopLogger struct{}
+
+var _ Logger = (*NoopLogger)(nil)
+
+// InfoCtx ...
+func (n NoopLogger) InfoCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {}
+
+// ErrorCtx ...
+func (n NoopLogger) ErrorCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {}
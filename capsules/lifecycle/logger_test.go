@@ -0,0 +1,28 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestNoopLogger(t *testing.T) {
+	logger := NoopLogger{}
+	tests := []struct {
+		level func(ctx context.Context, msg string, keysAndValues ...interface{})
+	}{
+		{level: logger.InfoCtx},
+		{level: logger.ErrorCtx},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("NoopLogger-%d", i), func(t *testing.T) {
+			test.level(context.TODO(), "", nil)
+		})
+	}
+}
+
+// testLogger is used in tests that use reflection to check the type
+type testLogger struct {
+	Logger
+}
@@ -0,0 +1,60 @@
+package lifecycle
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+type config struct {
+	signals     []os.Signal
+	stopTimeout time.Duration
+}
+
+// defaultConfig provides a config initialized with default values
+func defaultConfig() config {
+	return config{
+		signals:     []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		stopTimeout: 30 * time.Second,
+	}
+}
+
+// Option interface to identify functional options
+type Option func(a *App)
+
+// WithLogger provides an Option to provide a logger implementation.
+// Defaults to Noop
+func WithLogger(l Logger) Option {
+	return func(a *App) {
+		if l != nil {
+			a.logger = l
+		}
+	}
+}
+
+// WithComponents provides an Option to provide Components run by the App.
+func WithComponents(components ...Component) Option {
+	return func(a *App) {
+		a.Add(components...)
+	}
+}
+
+// WithSignals provides an Option to provide the os signals which stop the App.
+// Passing no signals disables signal handling.
+// Defaults to SIGINT and SIGTERM
+func WithSignals(signals ...os.Signal) Option {
+	return func(a *App) {
+		a.config.signals = signals
+	}
+}
+
+// WithStopTimeout provides an Option to provide the maximum duration given to
+// Components to stop.
+// Defaults to 30 seconds
+func WithStopTimeout(d time.Duration) Option {
+	return func(a *App) {
+		if d > 0 {
+			a.config.stopTimeout = d
+		}
+	}
+}
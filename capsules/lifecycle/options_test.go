@@ -0,0 +1,75 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type optionAssertion func(t *testing.T, a *App)
+
+func TestOption(t *testing.T) {
+	c := NewComponent(func(context.Context) error { return nil }, nil)
+
+	tests := []struct {
+		name   string
+		op     Option
+		assert optionAssertion
+	}{
+		{
+			name: "WithLogger",
+			op:   WithLogger(&testLogger{}),
+			assert: func(t *testing.T, a *App) {
+				if reflect.TypeOf(a.logger) != reflect.TypeOf(&testLogger{}) {
+					t.Errorf("expected type %T, got %T", &testLogger{}, a.logger)
+				}
+			},
+		},
+		{
+			name: "WithLogger-Nil",
+			op:   WithLogger(nil),
+			assert: func(t *testing.T, a *App) {
+				if reflect.TypeOf(a.logger) != reflect.TypeOf(NoopLogger{}) {
+					t.Errorf("expected type %T, got %T", NoopLogger{}, a.logger)
+				}
+			},
+		},
+		{
+			name: "WithComponents",
+			op:   WithComponents(c, nil, c),
+			assert: func(t *testing.T, a *App) {
+				if len(a.components) != 2 {
+					t.Errorf("expected 2 components, got %d", len(a.components))
+				}
+			},
+		},
+		{
+			name: "WithSignals",
+			op:   WithSignals(syscall.SIGHUP),
+			assert: func(t *testing.T, a *App) {
+				if !reflect.DeepEqual(a.config.signals, []os.Signal{syscall.SIGHUP}) {
+					t.Errorf("expected %v, got %v", syscall.SIGHUP, a.config.signals)
+				}
+			},
+		},
+		{
+			name: "WithStopTimeout",
+			op:   WithStopTimeout(time.Second),
+			assert: func(t *testing.T, a *App) {
+				if a.config.stopTimeout != time.Second {
+					t.Errorf("expected %s, got %s", time.Second, a.config.stopTimeout)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewApp(test.op)
+			test.assert(t, a)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/requestid"
+	"go.adenix.dev/adderall/capsules/subject"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -76,25 +78,25 @@ func (d *defaultLogger) Error(msg string, keysAndValues ...interface{}) {
 
 // DebugCtx writes a debug level log message with context
 func (d *defaultLogger) DebugCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l := d.getScopedLogger(ctx)
+	l := d.getScopedLogger(ctx, keysAndValues)
 	l.Debugw(msg, keysAndValues...)
 }
 
 // InfoCtx writes a info level log message with context
 func (d *defaultLogger) InfoCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l := d.getScopedLogger(ctx)
+	l := d.getScopedLogger(ctx, keysAndValues)
 	l.Infow(msg, keysAndValues...)
 }
 
 // WarnCtx writes a war level log message with context
 func (d *defaultLogger) WarnCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l := d.getScopedLogger(ctx)
+	l := d.getScopedLogger(ctx, keysAndValues)
 	l.Warnw(msg, keysAndValues...)
 }
 
 // ErrorCtx writes a error level log message with context
 func (d *defaultLogger) ErrorCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l := d.getScopedLogger(ctx)
+	l := d.getScopedLogger(ctx, keysAndValues)
 	l.Errorw(msg, keysAndValues...)
 }
 
@@ -103,7 +105,10 @@ func (d *defaultLogger) Sync() {
 	_ = d.l.Sync()
 }
 
-func (d *defaultLogger) getScopedLogger(ctx context.Context) *zap.SugaredLogger {
+// getScopedLogger provides a logger with the trace context, request id, and
+// authenticated subject carried by ctx. The request id and subject are omitted
+// when already in keysAndValues.
+func (d *defaultLogger) getScopedLogger(ctx context.Context, keysAndValues []interface{}) *zap.SugaredLogger {
 	c := newCarrier()
 
 	span := opentracing.SpanFromContext(ctx)
@@ -111,9 +116,32 @@ func (d *defaultLogger) getScopedLogger(ctx context.Context) *zap.SugaredLogger
 		_ = d.tracer.Inject(span.Context(), opentracing.TextMap, c)
 	}
 
+	if id, ok := requestid.FromContext(ctx); ok && !hasKey(keysAndValues, requestIDKey) {
+		c.Set(requestIDKey, id)
+	}
+	if s, ok := subject.FromContext(ctx); ok && !hasKey(keysAndValues, subjectKey) {
+		c.Set(subjectKey, s)
+	}
+
 	return d.l.With(c.fields...)
 }
 
+const (
+	// requestIDKey is the field the request id is logged as
+	requestIDKey = "request_id"
+	// subjectKey is the field the authenticated subject is logged as
+	subjectKey = "subject"
+)
+
+func hasKey(keysAndValues []interface{}, key string) bool {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
 type carrier struct {
 	fields []interface{}
 }
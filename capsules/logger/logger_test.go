@@ -6,6 +6,8 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"testing"
 
+	"go.adenix.dev/adderall/capsules/requestid"
+	"go.adenix.dev/adderall/capsules/subject"
 	mock "go.adenix.dev/adderall/mock/tracing"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -222,3 +224,70 @@ func assertLogWithNew(t *testing.T, expectedLevel zapcore.Level, expectedMessage
 		assert.DeepEqual(t, fields, ol.AllUntimed()[0].Context)
 	}
 }
+
+func TestLoggerRequestID(t *testing.T) {
+	tests := []struct {
+		name          string
+		keysAndValues []interface{}
+		expected      []zap.Field
+	}{
+		{
+			name:     "FromContext",
+			expected: []zap.Field{zap.String("request_id", "abc")},
+		},
+		{
+			name:          "Explicit",
+			keysAndValues: []interface{}{"request_id", "def"},
+			expected:      []zap.Field{zap.String("request_id", "def")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fac, ol := observer.New(zap.DebugLevel)
+			l := &defaultLogger{l: zap.New(fac).Sugar(), tracer: opentracing.NoopTracer{}}
+
+			ctx := requestid.NewContext(context.Background(), "abc")
+			l.InfoCtx(ctx, "foo", test.keysAndValues...)
+
+			assert.DeepEqual(t, test.expected, ol.AllUntimed()[0].Context)
+		})
+	}
+}
+
+func TestLoggerSubject(t *testing.T) {
+	tests := []struct {
+		name          string
+		subject       string
+		keysAndValues []interface{}
+		expected      []zap.Field
+	}{
+		{
+			name:     "FromContext",
+			subject:  "user-1",
+			expected: []zap.Field{zap.String("subject", "user-1")},
+		},
+		{
+			name:          "Explicit",
+			subject:       "user-1",
+			keysAndValues: []interface{}{"subject", "user-2"},
+			expected:      []zap.Field{zap.String("subject", "user-2")},
+		},
+		{
+			name:     "Anonymous",
+			expected: []zap.Field{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fac, ol := observer.New(zap.DebugLevel)
+			l := &defaultLogger{l: zap.New(fac).Sugar(), tracer: opentracing.NoopTracer{}}
+
+			ctx := subject.NewContext(context.Background(), test.subject)
+			l.InfoCtx(ctx, "foo", test.keysAndValues...)
+
+			assert.DeepEqual(t, test.expected, ol.AllUntimed()[0].Context)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is a Prometheus registry which lazily provides the RED metrics
+// recorded by servers and clients.
+type Registry struct {
+	*prometheus.Registry
+	config config
+
+	serverOnce sync.Once
+	server     *HTTPMetrics
+	clientOnce sync.Once
+	client     *HTTPMetrics
+
+	concurrencyOnce sync.Once
+	concurrency     *ConcurrencyMetrics
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// Default provides a process wide Registry shared by server and client
+// factories that are not given a Registry explicitly.
+func Default() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+	})
+	return defaultRegistry
+}
+
+// NewRegistry instantiates a Registry. Options can be passed to overwrite
+// default configurations.
+func NewRegistry(opts ...Option) *Registry {
+	c := defaultConfig()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+
+	r := &Registry{
+		Registry: prometheus.NewRegistry(),
+		config:   c,
+	}
+
+	if c.runtimeCollectors {
+		r.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: c.namespace}),
+		)
+	}
+
+	return r
+}
+
+// Handler provides a http.Handler exposing the metrics gathered by the
+// Registry in the Prometheus exposition format.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{Registry: r})
+}
+
+// Server provides the HTTPMetrics recording inbound requests. Requests are
+// labelled by method, route pattern and status class.
+func (r *Registry) Server() *HTTPMetrics {
+	r.serverOnce.Do(func() {
+		r.server = newHTTPMetrics(r, r.config, "http_server", "route")
+	})
+	return r.server
+}
+
+// Client provides the HTTPMetrics recording outbound requests. Requests are
+// labelled by method, host and status class.
+func (r *Registry) Client() *HTTPMetrics {
+	r.clientOnce.Do(func() {
+		r.client = newHTTPMetrics(r, r.config, "http_client", "host")
+	})
+	return r.client
+}
+
+// Concurrency provides the ConcurrencyMetrics recording the concurrency limit
+// of servers and the requests it rejected.
+func (r *Registry) Concurrency() *ConcurrencyMetrics {
+	r.concurrencyOnce.Do(func() {
+		r.concurrency = newConcurrencyMetrics(r, r.config)
+	})
+	return r.concurrency
+}
+
+// HTTPMetrics records the request count, duration, and in-flight requests of
+// HTTP traffic.
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newHTTPMetrics(reg prometheus.Registerer, c config, subsystem, target string) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests.",
+		}, []string{"method", target, "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests in seconds.",
+			Buckets:   c.buckets,
+		}, []string{"method", target, "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: c.namespace,
+			Subsystem: subsystem,
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests currently in flight.",
+		}, []string{"method", target}),
+	}
+
+	reg.MustRegister(m.requests, m.duration, m.inFlight)
+
+	return m
+}
+
+// Begin marks the start of a request and provides a function which must be
+// called with the response status code once the request completes. A status
+// code below 100 is recorded as an error.
+func (m *HTTPMetrics) Begin(method, target string) func(status int) {
+	start := time.Now()
+	inFlight := m.inFlight.WithLabelValues(method, target)
+	inFlight.Inc()
+
+	return func(status int) {
+		inFlight.Dec()
+		class := StatusClass(status)
+		m.requests.WithLabelValues(method, target, class).Inc()
+		m.duration.WithLabelValues(method, target, class).Observe(time.Since(start).Seconds())
+	}
+}
+
+// StatusClass provides the class of a status code, e.g. "2xx". Status codes
+// outside of the valid range are reported as "error".
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// ConcurrencyMetrics records the adaptive concurrency limit of a server and
+// the requests rejected for exceeding it.
+type ConcurrencyMetrics struct {
+	limit    prometheus.Gauge
+	rejected prometheus.Counter
+}
+
+func newConcurrencyMetrics(reg prometheus.Registerer, c config) *ConcurrencyMetrics {
+	m := &ConcurrencyMetrics{
+		limit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: c.namespace,
+			Subsystem: "http_server",
+			Name:      "concurrency_limit",
+			Help:      "Current limit of concurrent HTTP requests.",
+		}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Subsystem: "http_server",
+			Name:      "concurrency_rejected_total",
+			Help:      "Total number of HTTP requests rejected for exceeding the concurrency limit.",
+		}),
+	}
+
+	reg.MustRegister(m.limit, m.rejected)
+
+	return m
+}
+
+// SetLimit records the current concurrency limit
+func (m *ConcurrencyMetrics) SetLimit(limit int) {
+	m.limit.Set(float64(limit))
+}
+
+// Rejected records a request rejected for exceeding the concurrency limit
+func (m *ConcurrencyMetrics) Rejected() {
+	m.rejected.Inc()
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDefault(t *testing.T) {
+	if Default() != Default() {
+		t.Error("expected Default to provide the same Registry")
+	}
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  func(r *Registry) *HTTPMetrics
+		prefix   string
+		target   string
+		status   int
+		expected string
+	}{
+		{
+			name:     "Server",
+			metrics:  (*Registry).Server,
+			prefix:   "http_server",
+			target:   `route="/users/"`,
+			status:   http.StatusOK,
+			expected: "2xx",
+		},
+		{
+			name:     "Client",
+			metrics:  (*Registry).Client,
+			prefix:   "http_client",
+			target:   `host="/users/"`,
+			status:   http.StatusServiceUnavailable,
+			expected: "5xx",
+		},
+		{
+			name:     "Error",
+			metrics:  (*Registry).Server,
+			prefix:   "http_server",
+			target:   `route="/users/"`,
+			status:   0,
+			expected: "error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewRegistry(WithRuntimeCollectors(false))
+			m := test.metrics(r)
+			if m != test.metrics(r) {
+				t.Fatal("expected HTTPMetrics to be created once")
+			}
+
+			done := m.Begin(http.MethodGet, "/users/")
+			if v := testutil.ToFloat64(m.inFlight); v != 1 {
+				t.Errorf("expected 1 in flight, got %v", v)
+			}
+			done(test.status)
+			if v := testutil.ToFloat64(m.inFlight); v != 0 {
+				t.Errorf("expected 0 in flight, got %v", v)
+			}
+
+			body := scrape(t, r)
+			line := test.prefix + `_requests_total{`
+			if !strings.Contains(body, line) {
+				t.Errorf("expected %q in %q", line, body)
+			}
+			if !strings.Contains(body, test.target) || !strings.Contains(body, `status="`+test.expected+`"`) {
+				t.Errorf("expected labels %s and status %q in %q", test.target, test.expected, body)
+			}
+			if !strings.Contains(body, test.prefix+"_request_duration_seconds_bucket") {
+				t.Errorf("expected duration histogram in %q", body)
+			}
+		})
+	}
+}
+
+func TestConcurrencyMetrics(t *testing.T) {
+	r := NewRegistry(WithRuntimeCollectors(false), WithNamespace("foo"))
+	m := r.Concurrency()
+	if m != r.Concurrency() {
+		t.Fatal("expected ConcurrencyMetrics to be created once")
+	}
+
+	m.SetLimit(10)
+	m.Rejected()
+	m.Rejected()
+
+	expected := `
+# HELP foo_http_server_concurrency_limit Current limit of concurrent HTTP requests.
+# TYPE foo_http_server_concurrency_limit gauge
+foo_http_server_concurrency_limit 10
+# HELP foo_http_server_concurrency_rejected_total Total number of HTTP requests rejected for exceeding the concurrency limit.
+# TYPE foo_http_server_concurrency_rejected_total counter
+foo_http_server_concurrency_rejected_total 2
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := map[int]string{
+		0:   "error",
+		99:  "error",
+		101: "1xx",
+		204: "2xx",
+		302: "3xx",
+		404: "4xx",
+		599: "5xx",
+		600: "error",
+	}
+
+	for status, expected := range tests {
+		if actual := StatusClass(status); actual != expected {
+			t.Errorf("expected %q for %d, got %q", expected, status, actual)
+		}
+	}
+}
+
+func scrape(t *testing.T, r *Registry) string {
+	ts := httptest.NewServer(r.Handler())
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	bytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return string(bytes)
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type config struct {
+	namespace         string
+	buckets           []float64
+	runtimeCollectors bool
+}
+
+// defaultConfig provides a config initialized with default values
+func defaultConfig() config {
+	return config{
+		buckets:           prometheus.DefBuckets,
+		runtimeCollectors: true,
+	}
+}
+
+// Option interface to identify functional options
+type Option func(c *config)
+
+// WithNamespace provides an Option to provide a namespace prefixed to every
+// metric name.
+// Defaults to no namespace
+func WithNamespace(ns string) Option {
+	return func(c *config) {
+		c.namespace = ns
+	}
+}
+
+// WithBuckets provides an Option to provide the histogram buckets, in seconds,
+// used for request durations.
+// Defaults to prometheus.DefBuckets
+func WithBuckets(b []float64) Option {
+	return func(c *config) {
+		if len(b) > 0 {
+			c.buckets = b
+		}
+	}
+}
+
+// WithRuntimeCollectors provides an Option to enable or disable the Go runtime
+// and process collectors.
+// Defaults to true
+func WithRuntimeCollectors(enabled bool) Option {
+	return func(c *config) {
+		c.runtimeCollectors = enabled
+	}
+}
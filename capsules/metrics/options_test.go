@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+type optionAssertion func(t *testing.T, c *config)
+
+func TestOption(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     Option
+		assert optionAssertion
+	}{
+		{
+			name: "WithNamespace",
+			op:   WithNamespace("foo"),
+			assert: func(t *testing.T, c *config) {
+				if c.namespace != "foo" {
+					t.Errorf("expected %q, got %q", "foo", c.namespace)
+				}
+			},
+		},
+		{
+			name:   "WithBuckets",
+			op:     WithBuckets([]float64{0.1, 1}),
+			assert: assertWithBuckets([]float64{0.1, 1}),
+		},
+		{
+			name:   "WithBuckets-Empty",
+			op:     WithBuckets(nil),
+			assert: assertWithBuckets(defaultConfig().buckets),
+		},
+		{
+			name: "WithRuntimeCollectors",
+			op:   WithRuntimeCollectors(false),
+			assert: func(t *testing.T, c *config) {
+				if c.runtimeCollectors {
+					t.Error("expected runtime collectors to be disabled")
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultConfig()
+			test.op(&c)
+			test.assert(t, &c)
+		})
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	r := NewRegistry(WithNamespace("foo"), nil)
+	r.Server().Begin("GET", "/")(200)
+
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var runtime, server bool
+	for _, f := range families {
+		switch f.GetName() {
+		case "go_goroutines":
+			runtime = true
+		case "foo_http_server_requests_in_flight":
+			server = true
+		}
+	}
+	if !runtime {
+		t.Error("expected runtime collectors to be registered")
+	}
+	if !server {
+		t.Error("expected namespaced server metrics to be registered")
+	}
+}
+
+func assertWithBuckets(expected []float64) optionAssertion {
+	return func(t *testing.T, c *config) {
+		if !reflect.DeepEqual(c.buckets, expected) {
+			t.Errorf("expected %v, got %v", expected, c.buckets)
+		}
+	}
+}
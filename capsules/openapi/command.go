@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrOutdated is returned by Command when a checked document differs from the
+// generated one
+var ErrOutdated = errors.New("openapi document is out of date")
+
+// Command runs a command line dumping the Document of spec, intended to be run
+// from the main of a service once its routes are registered, e.g. when invoked
+// as "service openapi -o openapi.json". The flags are:
+//
+//	-o file  the file written, "-" writing to stdout (the default)
+//	-check   compare the file with the generated document instead of writing
+//	         it, failing with ErrOutdated when they differ
+//
+// Usage and flag errors are written to stdout.
+func Command(spec *Spec, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	out := fs.String("o", "-", "`file` the OpenAPI document is written to, - for stdout")
+	check := fs.Bool("check", false, "compare the file with the generated document instead of writing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+
+	b, err := spec.MarshalIndent()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *check && *out == "-":
+		return errors.New("-check requires -o")
+	case *check:
+		existing, err := os.ReadFile(*out)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(existing, b) {
+			return fmt.Errorf("%w: %s", ErrOutdated, *out)
+		}
+		return nil
+	case *out == "-":
+		_, err = stdout.Write(b)
+		return err
+	default:
+		return os.WriteFile(*out, b, 0o644)
+	}
+}
@@ -0,0 +1,93 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	spec := NewSpec(Info{Title: "users", Version: "1.0.0"})
+	spec.Add(Route{Method: http.MethodGet, Pattern: "/users"})
+	expected, err := spec.MarshalIndent()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.json")
+	if err := os.WriteFile(current, expected, 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	outdated := filepath.Join(dir, "outdated.json")
+	if err := os.WriteFile(outdated, []byte("{}\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdout []byte
+		file   string
+		err    error
+		fails  bool
+	}{
+		{
+			name:   "Stdout",
+			stdout: expected,
+		},
+		{
+			name: "File",
+			args: []string{"-o", filepath.Join(dir, "written.json")},
+			file: filepath.Join(dir, "written.json"),
+		},
+		{
+			name: "CheckCurrent",
+			args: []string{"-check", "-o", current},
+		},
+		{
+			name:  "CheckOutdated",
+			args:  []string{"-check", "-o", outdated},
+			err:   ErrOutdated,
+			fails: true,
+		},
+		{
+			name:  "CheckWithoutFile",
+			args:  []string{"-check"},
+			fails: true,
+		},
+		{
+			name:  "UnexpectedArgument",
+			args:  []string{"openapi.json"},
+			fails: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := Command(spec, test.args, &stdout)
+			if test.fails != (err != nil) {
+				t.Fatalf("expected failure %t, got %v", test.fails, err)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+			if test.stdout != nil && !bytes.Equal(stdout.Bytes(), test.stdout) {
+				t.Errorf("expected stdout %s, got %s", test.stdout, stdout.Bytes())
+			}
+			if test.file != "" {
+				b, err := os.ReadFile(test.file)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if !bytes.Equal(b, expected) {
+					t.Errorf("expected file %s, got %s", expected, b)
+				}
+			}
+		})
+	}
+}
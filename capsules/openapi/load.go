@@ -0,0 +1,377 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// methods are the keys of a path item naming operations
+var methods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// LoadFile loads the OpenAPI document in the file at path, see Load
+func LoadFile(path string) (*Document, error) {
+	return LoadFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
+
+// LoadFS loads the OpenAPI document in the file name of fsys, see Load
+func LoadFS(fsys fs.FS, name string) (*Document, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Load(b)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", name, err)
+	}
+	return doc, nil
+}
+
+// Load parses an OpenAPI 3.0 or Swagger 2.0 document in JSON or YAML. Swagger
+// documents are converted to OpenAPI 3.0, prefixing their paths with the base
+// path. Parameters declared on a path are copied to each of its operations.
+func Load(b []byte) (*Document, error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] != '{' {
+		converted, err := yaml.YAMLToJSON(b)
+		if err != nil {
+			return nil, err
+		}
+		b = converted
+	}
+
+	var version struct {
+		OpenAPI string `json:"openapi"`
+		Swagger string `json:"swagger"`
+	}
+	if err := json.Unmarshal(b, &version); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case version.Swagger == "2.0":
+		return loadSwagger(b)
+	case strings.HasPrefix(version.OpenAPI, "3.0."):
+		var doc Document
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		return &doc, nil
+	default:
+		return nil, fmt.Errorf("unsupported openapi version %q", version.OpenAPI+version.Swagger)
+	}
+}
+
+// UnmarshalJSON parses a schema, accepting a boolean for additionalProperties
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	type schema Schema
+	aux := struct {
+		*schema
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}{schema: (*schema)(s)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	switch string(bytes.TrimSpace(aux.AdditionalProperties)) {
+	case "", "null", "true":
+		s.AdditionalProperties = nil
+	case "false":
+		// no value is valid against a schema negating the empty schema
+		s.AdditionalProperties = &Schema{Not: &Schema{}}
+	default:
+		s.AdditionalProperties = new(Schema)
+		return json.Unmarshal(aux.AdditionalProperties, s.AdditionalProperties)
+	}
+	return nil
+}
+
+// UnmarshalJSON parses a path item, copying the parameters declared on the
+// path to each of its operations
+func (p *PathItem) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	var shared []Parameter
+	if params, ok := raw["parameters"]; ok {
+		if err := json.Unmarshal(params, &shared); err != nil {
+			return err
+		}
+	}
+
+	item := make(PathItem)
+	for key, value := range raw {
+		if !methods[key] {
+			continue
+		}
+		op := new(Operation)
+		if err := json.Unmarshal(value, op); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		op.Parameters = mergeParameters(shared, op.Parameters)
+		item[key] = op
+	}
+	*p = item
+	return nil
+}
+
+// mergeParameters provides the parameters of an operation and the shared
+// parameters of its path which it does not override
+func mergeParameters(shared, own []Parameter) []Parameter {
+	if len(shared) == 0 {
+		return own
+	}
+	merged := append([]Parameter(nil), own...)
+	for _, s := range shared {
+		overridden := false
+		for _, o := range own {
+			if (s.Ref != "" && s.Ref == o.Ref) || (s.Name != "" && s.Name == o.Name && s.In == o.In) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// swagger is the subset of a Swagger 2.0 document converted to OpenAPI 3.0
+type swagger struct {
+	Info        Info                                  `json:"info"`
+	BasePath    string                                `json:"basePath"`
+	Consumes    []string                              `json:"consumes"`
+	Produces    []string                              `json:"produces"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*Schema                    `json:"definitions"`
+	Parameters  map[string]json.RawMessage            `json:"parameters"`
+	Responses   map[string]*swaggerResponse           `json:"responses"`
+}
+
+type swaggerOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description"`
+	Tags        []string                    `json:"tags"`
+	Deprecated  bool                        `json:"deprecated"`
+	Consumes    []string                    `json:"consumes"`
+	Produces    []string                    `json:"produces"`
+	Parameters  []json.RawMessage           `json:"parameters"`
+	Responses   map[string]*swaggerResponse `json:"responses"`
+}
+
+type swaggerParameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type swaggerResponse struct {
+	Ref         string  `json:"$ref"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// swaggerRefs maps the reference prefixes of Swagger 2.0 to OpenAPI 3.0
+var swaggerRefs = strings.NewReplacer(
+	`"#/definitions/`, `"#/components/schemas/`,
+	`"#/parameters/`, `"#/components/parameters/`,
+	`"#/responses/`, `"#/components/responses/`,
+)
+
+// loadSwagger converts a Swagger 2.0 document to OpenAPI 3.0. Body parameters
+// become request bodies and form parameters are dropped.
+func loadSwagger(b []byte) (*Document, error) {
+	var s swagger
+	if err := json.Unmarshal([]byte(swaggerRefs.Replace(string(b))), &s); err != nil {
+		return nil, err
+	}
+
+	consumes := defaultMediaTypes(s.Consumes)
+	produces := defaultMediaTypes(s.Produces)
+	base := strings.TrimSuffix(s.BasePath, "/")
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    s.Info,
+		Paths:   make(map[string]PathItem),
+		Components: &Components{
+			Schemas:       s.Definitions,
+			Parameters:    make(map[string]*Parameter),
+			RequestBodies: make(map[string]*RequestBody),
+			Responses:     make(map[string]*Response),
+		},
+	}
+
+	for name, raw := range s.Parameters {
+		p, body, err := swaggerParam(raw, consumes)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		if body != nil {
+			doc.Components.RequestBodies[name] = body
+		} else if p != nil {
+			doc.Components.Parameters[name] = p
+		}
+	}
+	for name, r := range s.Responses {
+		doc.Components.Responses[name] = swaggerResp(r, produces)
+	}
+
+	for path, item := range s.Paths {
+		var shared []json.RawMessage
+		if params, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(params, &shared); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		converted := make(PathItem)
+		for key, raw := range item {
+			if !methods[key] {
+				continue
+			}
+			var so swaggerOperation
+			if err := json.Unmarshal(raw, &so); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", key, path, err)
+			}
+			op, err := swaggerOp(so, shared, doc.Components, consumes, produces)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", key, path, err)
+			}
+			converted[key] = op
+		}
+		doc.Paths[base+path] = converted
+	}
+	return doc, nil
+}
+
+func swaggerOp(so swaggerOperation, shared []json.RawMessage, c *Components, consumes, produces []string) (*Operation, error) {
+	if len(so.Consumes) > 0 {
+		consumes = so.Consumes
+	}
+	if len(so.Produces) > 0 {
+		produces = so.Produces
+	}
+
+	op := &Operation{
+		OperationID: so.OperationID,
+		Summary:     so.Summary,
+		Description: so.Description,
+		Tags:        so.Tags,
+		Deprecated:  so.Deprecated,
+		Responses:   make(map[string]*Response),
+	}
+
+	var own, inherited []Parameter
+	for i, params := range [][]json.RawMessage{so.Parameters, shared} {
+		for _, raw := range params {
+			p, body, err := swaggerParam(raw, consumes)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case body != nil:
+				if op.RequestBody == nil || i == 0 {
+					op.RequestBody = body
+				}
+			case p != nil && p.Ref != "" && c.RequestBodies[refName(p.Ref)] != nil:
+				// a reference to a body parameter
+				if op.RequestBody == nil || i == 0 {
+					op.RequestBody = &RequestBody{Ref: "#/components/requestBodies/" + refName(p.Ref)}
+				}
+			case p != nil && i == 0:
+				own = append(own, *p)
+			case p != nil:
+				inherited = append(inherited, *p)
+			}
+		}
+	}
+	op.Parameters = mergeParameters(inherited, own)
+
+	for status, r := range so.Responses {
+		op.Responses[status] = swaggerResp(r, produces)
+	}
+	return op, nil
+}
+
+// swaggerParam converts a Swagger 2.0 parameter, providing a request body for
+// body parameters and nothing for form parameters
+func swaggerParam(raw json.RawMessage, consumes []string) (*Parameter, *RequestBody, error) {
+	var sp swaggerParameter
+	if err := json.Unmarshal(raw, &sp); err != nil {
+		return nil, nil, err
+	}
+
+	switch sp.In {
+	case "body":
+		body := &RequestBody{Description: sp.Description, Required: sp.Required, Content: make(map[string]*MediaType)}
+		for _, mt := range consumes {
+			body.Content[mt] = &MediaType{Schema: sp.Schema}
+		}
+		return nil, body, nil
+	case "formData":
+		return nil, nil, nil
+	}
+
+	p := &Parameter{Ref: sp.Ref, Name: sp.Name, In: sp.In, Description: sp.Description, Required: sp.Required}
+	if sp.Ref == "" {
+		// the schema of other parameters is inlined in the parameter itself
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, nil, err
+		}
+		for _, key := range []string{"name", "in", "description", "required", "collectionFormat", "allowEmptyValue"} {
+			delete(fields, key)
+		}
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.Schema = new(Schema)
+		if err := json.Unmarshal(b, p.Schema); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p, nil, nil
+}
+
+func swaggerResp(r *swaggerResponse, produces []string) *Response {
+	if r == nil {
+		return &Response{}
+	}
+	res := &Response{Ref: r.Ref, Description: r.Description}
+	if r.Schema != nil {
+		res.Content = make(map[string]*MediaType)
+		for _, mt := range produces {
+			res.Content[mt] = &MediaType{Schema: r.Schema}
+		}
+	}
+	return res
+}
+
+func defaultMediaTypes(types []string) []string {
+	if len(types) == 0 {
+		return []string{"application/json"}
+	}
+	return types
+}
+
+// refName provides the name of the component a local reference points to
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
@@ -0,0 +1,213 @@
+package openapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testOpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {"title": "users", "version": "1.0.0"},
+  "paths": {
+    "/users/{id}": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "format": "uuid"}},
+        {"name": "verbose", "in": "query", "schema": {"type": "boolean"}}
+      ],
+      "summary": "a user",
+      "get": {
+        "parameters": [{"name": "verbose", "in": "query", "schema": {"type": "integer"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/user"}}}}}
+      },
+      "put": {
+        "requestBody": {"$ref": "#/components/requestBodies/user"},
+        "responses": {"204": {"description": "No Content"}, "4XX": {"$ref": "#/components/responses/problem"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "user": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1},
+          "age": {"type": "integer", "minimum": 0},
+          "email": {"type": "string", "format": "email"},
+          "tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "enum": ["a", "b"]}}
+        },
+        "additionalProperties": false
+      }
+    },
+    "requestBodies": {
+      "user": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/user"}}}}
+    },
+    "responses": {
+      "problem": {"description": "problem", "content": {"application/problem+json": {"schema": {"type": "object"}}}}
+    }
+  }
+}`
+
+const testSwagger = `{
+  "swagger": "2.0",
+  "info": {"title": "users", "version": "1.0.0"},
+  "basePath": "/api/",
+  "paths": {
+    "/users": {
+      "post": {
+        "parameters": [
+          {"name": "user", "in": "body", "required": true, "schema": {"$ref": "#/definitions/user"}},
+          {"name": "dry_run", "in": "query", "type": "boolean"},
+          {"name": "avatar", "in": "formData", "type": "file"},
+          {"$ref": "#/parameters/trace"}
+        ],
+        "responses": {"201": {"description": "Created", "schema": {"$ref": "#/definitions/user"}}}
+      }
+    }
+  },
+  "definitions": {
+    "user": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+  },
+  "parameters": {
+    "trace": {"name": "X-Trace", "in": "header", "type": "string", "maxLength": 8}
+  }
+}`
+
+func TestLoad(t *testing.T) {
+	doc, err := Load([]byte(testOpenAPI))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	get := doc.Paths["/users/{id}"]["get"]
+	if get == nil || len(doc.Paths["/users/{id}"]) != 2 {
+		t.Fatalf("expected the get and put operations, got %v", doc.Paths["/users/{id}"])
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0].Schema.Type != "integer" || get.Parameters[1].Name != "id" {
+		t.Errorf("expected the own verbose parameter and the shared id parameter, got %v", get.Parameters)
+	}
+	if ap := doc.Components.Schemas["user"].AdditionalProperties; !reflect.DeepEqual(ap, &Schema{Not: &Schema{}}) {
+		t.Errorf("expected additional properties to be rejected, got %v", ap)
+	}
+	if ref := doc.Paths["/users/{id}"]["put"].RequestBody.Ref; ref != "#/components/requestBodies/user" {
+		t.Errorf("unexpected request body reference %q", ref)
+	}
+}
+
+func TestLoadSwagger(t *testing.T) {
+	doc, err := Load([]byte(testSwagger))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	post := doc.Paths["/api/users"]["post"]
+	if post == nil {
+		t.Fatalf("expected POST /api/users, got %v", doc.Paths)
+	}
+	if s := post.RequestBody.Content["application/json"].Schema; !post.RequestBody.Required || s.Ref != "#/components/schemas/user" {
+		t.Errorf("unexpected request body %v", post.RequestBody)
+	}
+	expectedParams := []Parameter{
+		{Name: "dry_run", In: "query", Schema: &Schema{Type: "boolean"}},
+		{Ref: "#/components/parameters/trace"},
+	}
+	if !reflect.DeepEqual(post.Parameters, expectedParams) {
+		t.Errorf("expected parameters %v, got %v", expectedParams, post.Parameters)
+	}
+	if s := post.Responses["201"].Content["application/json"].Schema; s.Ref != "#/components/schemas/user" {
+		t.Errorf("unexpected response schema %v", s)
+	}
+	if p := doc.Components.Parameters["trace"]; p == nil || p.Schema.MaxLength == nil || *p.Schema.MaxLength != 8 {
+		t.Errorf("unexpected trace parameter %v", p)
+	}
+	if _, ok := doc.Components.Schemas["user"]; !ok {
+		t.Errorf("expected the user definition, got %v", doc.Components.Schemas)
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	doc, err := Load([]byte(`
+openapi: 3.0.3
+info:
+  title: users
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            maximum: 100
+      responses:
+        "200":
+          description: OK
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	get := doc.Paths["/users"]["get"]
+	if get == nil || len(get.Parameters) != 1 || *get.Parameters[0].Schema.Maximum != 100 {
+		t.Errorf("unexpected operation %v", get)
+	}
+	if doc.Info.Title != "users" {
+		t.Errorf("expected title %q, got %q", "users", doc.Info.Title)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{name: "InvalidJSON", doc: `{`},
+		{name: "InvalidYAML", doc: "openapi: [3.0.0"},
+		{name: "UnsupportedVersion", doc: `{"openapi": "3.1.0"}`},
+		{name: "NoVersion", doc: `{}`},
+		{name: "InvalidOperation", doc: `{"openapi": "3.0.0", "paths": {"/": {"get": []}}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Load([]byte(test.doc)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(path, []byte(testOpenAPI), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := LoadFile(path); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"api/openapi.json": {Data: []byte(testOpenAPI)},
+		"api/invalid.json": {Data: []byte(`{}`)},
+	}
+
+	if _, err := LoadFS(fsys, "api/openapi.json"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := LoadFS(fsys, "api/invalid.json"); err == nil || !strings.Contains(err.Error(), "api/invalid.json") {
+		t.Errorf("expected error naming the file, got %v", err)
+	}
+	if _, err := LoadFS(fsys, "missing.json"); err == nil {
+		t.Error("expected error")
+	}
+}
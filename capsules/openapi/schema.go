@@ -0,0 +1,289 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	problemType       = reflect.TypeOf(httperr.Problem{})
+	errorType         = reflect.TypeOf(httperr.Error{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// generator generates the schemas of Go types, collecting the schemas of named
+// structs as components referenced by the schemas using them
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+	owners  map[string]reflect.Type
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+		owners:  make(map[string]reflect.Type),
+	}
+}
+
+// isProblem reports whether values of t are rendered as problem details
+func isProblem(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == problemType || t == errorType
+}
+
+// schema provides the schema of t as encoded by encoding/json
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case isProblem(t):
+		return g.component(problemType, "Problem", problemSchema)
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: float64P(0)}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64", Minimum: float64P(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Array:
+		n := t.Len()
+		return &Schema{Type: "array", Items: g.schema(t.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.component(t, t.Name(), g.structSchema)
+	default:
+		return &Schema{}
+	}
+}
+
+// component provides a reference to the component schema of the named type t,
+// generating it with build on first use
+func (g *generator) component(t reflect.Type, name string, build func(t reflect.Type) *Schema) *Schema {
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if owner, ok := g.owners[name]; ok && owner != t {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	g.names[t] = name
+	g.owners[name] = t
+
+	// the reference is registered before building the schema so recursive
+	// types refer to themselves
+	g.schemas[name] = build(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// structSchema provides the object schema of the struct t, flattening embedded
+// structs the way encoding/json does
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.addFields(s, ft)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var fs *Schema
+		if hasOption(opts[1:], "string") && isScalar(ft) {
+			fs = &Schema{Type: "string"}
+		} else {
+			fs = g.schema(f.Type)
+		}
+		if applyRules(fs, ft, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+func hasOption(opts []string, option string) bool {
+	for _, o := range opts {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// applyRules adds the constraints of the validate tag, see codec.Validate, to
+// the schema s of a field of type t, and reports whether the field is
+// required. Rules not expressible in a schema are ignored.
+func applyRules(s *Schema, t reflect.Type, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			if s.Ref != "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			lower, upper := name != "max", name != "min"
+			switch t.Kind() {
+			case reflect.String:
+				if lower {
+					s.MinLength = intP(int(n))
+				}
+				if upper {
+					s.MaxLength = intP(int(n))
+				}
+			case reflect.Slice, reflect.Array:
+				if lower {
+					s.MinItems = intP(int(n))
+				}
+				if upper {
+					s.MaxItems = intP(int(n))
+				}
+			default:
+				if !isScalar(t) || t.Kind() == reflect.Bool || t.Kind() == reflect.String {
+					continue
+				}
+				if lower {
+					s.Minimum = float64P(n)
+				}
+				if upper {
+					s.Maximum = float64P(n)
+				}
+			}
+		case "oneof":
+			s.Enum = nil
+			for _, o := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, enumValue(t, o))
+			}
+		case "email":
+			s.Format = "email"
+		}
+	}
+	return required
+}
+
+// enumValue provides the option o of a oneof rule as a value of kind of t
+func enumValue(t reflect.Type, o string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(o, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(o, 64); err == nil {
+			return n
+		}
+	}
+	return o
+}
+
+// problemSchema provides the schema of RFC 7807 problem details
+func problemSchema(reflect.Type) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":     {Type: "string", Format: "uri-reference"},
+			"title":    {Type: "string"},
+			"status":   {Type: "integer", Format: "int32"},
+			"detail":   {Type: "string"},
+			"instance": {Type: "string", Format: "uri-reference"},
+		},
+		AdditionalProperties: &Schema{},
+	}
+}
+
+func intP(n int) *int { return &n }
+
+func float64P(n float64) *float64 { return &n }
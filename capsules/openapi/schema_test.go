@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type address struct {
+	Street string `json:"street" validate:"required,max=64"`
+}
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type user struct {
+	base
+	Name     string            `json:"name" validate:"required,min=1,max=32"`
+	Email    string            `json:"email,omitempty" validate:"email"`
+	Role     string            `json:"role" validate:"oneof=admin member"`
+	Age      int               `json:"age" validate:"min=0,max=150"`
+	Count    int64             `json:"count,string"`
+	Tags     []string          `json:"tags" validate:"max=5"`
+	Labels   map[string]string `json:"labels"`
+	Address  *address          `json:"address"`
+	Friends  []user            `json:"friends"`
+	Created  time.Time         `json:"created"`
+	Avatar   []byte            `json:"avatar"`
+	Extra    json.RawMessage   `json:"extra"`
+	Any      interface{}       `json:"any"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "String",
+			value:    "",
+			expected: `{"type":"string"}`,
+		},
+		{
+			name:     "Uint",
+			value:    uint8(0),
+			expected: `{"type":"integer","format":"int32","minimum":0}`,
+		},
+		{
+			name:     "Float",
+			value:    float32(0),
+			expected: `{"type":"number","format":"float"}`,
+		},
+		{
+			name:     "Array",
+			value:    [2]bool{},
+			expected: `{"type":"array","minItems":2,"maxItems":2,"items":{"type":"boolean"}}`,
+		},
+		{
+			name:     "Map",
+			value:    map[string]int{},
+			expected: `{"type":"object","additionalProperties":{"type":"integer","format":"int64"}}`,
+		},
+		{
+			name:     "Time",
+			value:    &time.Time{},
+			expected: `{"type":"string","format":"date-time"}`,
+		},
+		{
+			name: "AnonymousStruct",
+			value: struct {
+				A string `json:"a" validate:"required"`
+			}{},
+			expected: `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`,
+		},
+		{
+			name:     "NamedStruct",
+			value:    address{},
+			expected: `{"$ref":"#/components/schemas/address"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := json.Marshal(newGenerator().schema(reflect.TypeOf(test.value)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(b) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, b)
+			}
+		})
+	}
+}
+
+func TestSchemaStruct(t *testing.T) {
+	g := newGenerator()
+	g.schema(reflect.TypeOf(user{}))
+
+	if len(g.schemas) != 2 {
+		t.Fatalf("expected 2 components, got %d", len(g.schemas))
+	}
+
+	b, err := json.Marshal(g.schemas["user"])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":      map[string]interface{}{"type": "string"},
+			"name":    map[string]interface{}{"type": "string", "minLength": 1.0, "maxLength": 32.0},
+			"email":   map[string]interface{}{"type": "string", "format": "email"},
+			"role":    map[string]interface{}{"type": "string", "enum": []interface{}{"admin", "member"}},
+			"age":     map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0.0, "maximum": 150.0},
+			"count":   map[string]interface{}{"type": "string"},
+			"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": 5.0},
+			"labels":  map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+			"address": map[string]interface{}{"$ref": "#/components/schemas/address"},
+			"friends": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/user"}},
+			"created": map[string]interface{}{"type": "string", "format": "date-time"},
+			"avatar":  map[string]interface{}{"type": "string", "format": "byte"},
+			"extra":   map[string]interface{}{},
+			"any":     map[string]interface{}{},
+		},
+		"required": []interface{}{"name"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestSchemaNameCollision(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+
+	g := newGenerator()
+	outer := g.schema(reflect.TypeOf(user{}.Address))
+	inner := g.schema(reflect.TypeOf(address{}))
+
+	if outer.Ref != "#/components/schemas/address" {
+		t.Errorf("expected %q, got %q", "#/components/schemas/address", outer.Ref)
+	}
+	if inner.Ref != "#/components/schemas/openapi.address" {
+		t.Errorf("expected %q, got %q", "#/components/schemas/openapi.address", inner.Ref)
+	}
+	if again := g.schema(reflect.TypeOf(address{})); again.Ref != inner.Ref {
+		t.Errorf("expected %q, got %q", inner.Ref, again.Ref)
+	}
+}
@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// Route describes an operation of an API. The schemas of the request and
+// response bodies are generated from the types of the values given for them,
+// using their json struct tags for names and their validate struct tags, see
+// codec.Validate, for constraints.
+type Route struct {
+	Method      string
+	Pattern     string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Parameters are the parameters of the operation. Path parameters of the
+	// Pattern not described here are added as required strings.
+	Parameters []Parameter
+	// Request is a value of the request body type, nil for operations without
+	// a request body
+	Request interface{}
+	// ContentType is the media type of the request body.
+	// Defaults to application/json
+	ContentType string
+	// Responses are values of the response body types by status, nil for
+	// responses without a body. Problem details are described for
+	// httperr.Problem and httperr.Error values, and a status of zero describes
+	// the default response.
+	// Defaults to a 200 without a body
+	Responses map[int]interface{}
+}
+
+// Spec collects Routes and generates the Document describing them. A Spec is
+// safe for concurrent use.
+type Spec struct {
+	mu      sync.Mutex
+	info    Info
+	servers []Server
+	routes  []Route
+	keys    map[string]bool
+
+	// validator is the Validator of the current routes, see Validator
+	validator *Validator
+}
+
+// NewSpec instantiates a Spec of the API described by info, served from
+// servers.
+func NewSpec(info Info, servers ...Server) *Spec {
+	return &Spec{
+		info:    info,
+		servers: servers,
+		keys:    make(map[string]bool),
+	}
+}
+
+// Add adds routes to the Spec. Add panics when a route has no method or
+// pattern, or was already added.
+func (s *Spec) Add(routes ...Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range routes {
+		if r.Method == "" || !strings.HasPrefix(r.Pattern, "/") {
+			panic(fmt.Sprintf("openapi: invalid route %q %q", r.Method, r.Pattern))
+		}
+		key := strings.ToUpper(r.Method) + " " + Path(r.Pattern)
+		if s.keys[key] {
+			panic(fmt.Sprintf("openapi: route %s added twice", key))
+		}
+		s.keys[key] = true
+		s.routes = append(s.routes, r)
+	}
+	s.validator = nil
+}
+
+// Validator provides a Validator of the Document of the Spec, generated once
+// until more routes are added.
+func (s *Spec) Validator() *Validator {
+	s.mu.Lock()
+	v := s.validator
+	s.mu.Unlock()
+	if v != nil {
+		return v
+	}
+
+	v = NewValidator(s.Document())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.validator == nil {
+		s.validator = v
+	}
+	return s.validator
+}
+
+// Document generates the Document describing the Routes of the Spec
+func (s *Spec) Document() *Document {
+	s.mu.Lock()
+	routes := make([]Route, len(s.routes))
+	copy(routes, s.routes)
+	s.mu.Unlock()
+
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    s.info,
+		Servers: s.servers,
+		Paths:   make(map[string]PathItem),
+	}
+	for _, r := range routes {
+		p := Path(r.Pattern)
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = make(PathItem)
+		}
+		doc.Paths[p][strings.ToLower(r.Method)] = g.operation(r)
+	}
+	if len(g.schemas) > 0 {
+		doc.Components = &Components{Schemas: g.schemas}
+	}
+	return doc
+}
+
+// MarshalIndent provides the Document of the Spec as indented JSON ending with
+// a newline, stable across runs so it can be committed and diffed.
+func (s *Spec) MarshalIndent() ([]byte, error) {
+	b, err := json.MarshalIndent(s.Document(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// WriteFile writes the Document of the Spec to the file at path, see
+// MarshalIndent.
+func (s *Spec) WriteFile(path string) error {
+	b, err := s.MarshalIndent()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// Handler provides a http.Handler serving the Document of the Spec as JSON.
+// The Document is generated per request so routes added later are served.
+func (s *Spec) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(s.Document())
+		if err != nil {
+			httperr.WriteError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(b)
+		}
+	})
+}
+
+// Path converts a route pattern to an OpenAPI path, rendering wildcard
+// segments as parameters, e.g. "/files/{path...}" becomes "/files/{path}" and
+// "/static/*" becomes "/static/{wildcard}".
+func Path(pattern string) string {
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		switch {
+		case seg == "*":
+			segs[i] = "{wildcard}"
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}"):
+			segs[i] = strings.TrimSuffix(seg, "...}") + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// pathParams provides the names of the parameters of the OpenAPI path p
+func pathParams(p string) []string {
+	var names []string
+	for _, seg := range strings.Split(p, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			names = append(names, seg[1:len(seg)-1])
+		}
+	}
+	return names
+}
+
+func (g *generator) operation(r Route) *Operation {
+	op := &Operation{
+		OperationID: r.OperationID,
+		Summary:     r.Summary,
+		Description: r.Description,
+		Tags:        r.Tags,
+		Deprecated:  r.Deprecated,
+		Responses:   make(map[string]*Response),
+	}
+
+	declared := make(map[string]bool)
+	for _, p := range r.Parameters {
+		if p.In == "path" {
+			declared[p.Name] = true
+		}
+	}
+	for _, name := range pathParams(Path(r.Pattern)) {
+		if !declared[name] {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	op.Parameters = append(op.Parameters, r.Parameters...)
+
+	if r.Request != nil {
+		contentType := r.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{contentType: {Schema: g.schema(reflect.TypeOf(r.Request))}},
+		}
+	}
+
+	responses := r.Responses
+	if len(responses) == 0 {
+		responses = map[int]interface{}{http.StatusOK: nil}
+	}
+	statuses := make([]int, 0, len(responses))
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	// statuses are sorted so component names are assigned deterministically
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		body := responses[status]
+		key, description := "default", "Default response"
+		if status != 0 {
+			key, description = strconv.Itoa(status), http.StatusText(status)
+		}
+		res := &Response{Description: description}
+		if body != nil {
+			t := reflect.TypeOf(body)
+			contentType := "application/json"
+			if isProblem(t) {
+				contentType = httperr.ContentType
+			}
+			res.Content = map[string]*MediaType{contentType: {Schema: g.schema(t)}}
+		}
+		op.Responses[key] = res
+	}
+	return op
+}
@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+func TestSpecDocument(t *testing.T) {
+	spec := NewSpec(Info{Title: "users", Version: "1.0.0"}, Server{URL: "https://api.example.com"})
+	spec.Add(
+		Route{
+			Method:    http.MethodGet,
+			Pattern:   "/users/{id}",
+			Summary:   "get a user",
+			Tags:      []string{"users"},
+			Responses: map[int]interface{}{http.StatusOK: user{}, http.StatusNotFound: httperr.Problem{}},
+		},
+		Route{
+			Method:      http.MethodPost,
+			Pattern:     "/users",
+			OperationID: "createUser",
+			Request:     address{},
+			Responses:   map[int]interface{}{http.StatusCreated: &user{}, 0: &httperr.Error{}},
+		},
+		Route{
+			Method:  http.MethodGet,
+			Pattern: "/files/{path...}",
+			Parameters: []Parameter{
+				{Name: "path", In: "path", Required: true, Description: "file path", Schema: &Schema{Type: "string"}},
+				{Name: "download", In: "query", Schema: &Schema{Type: "boolean"}},
+			},
+		},
+	)
+
+	doc := spec.Document()
+
+	if doc.OpenAPI != Version {
+		t.Errorf("expected version %q, got %q", Version, doc.OpenAPI)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://api.example.com" {
+		t.Errorf("unexpected servers %v", doc.Servers)
+	}
+
+	get := doc.Paths["/users/{id}"]["get"]
+	if get == nil {
+		t.Fatalf("expected GET /users/{id}, got %v", doc.Paths)
+	}
+	expectedParams := []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}}
+	if !reflect.DeepEqual(get.Parameters, expectedParams) {
+		t.Errorf("expected parameters %v, got %v", expectedParams, get.Parameters)
+	}
+	if s := get.Responses["200"].Content["application/json"].Schema; s.Ref != "#/components/schemas/user" {
+		t.Errorf("unexpected 200 schema %v", s)
+	}
+	if s := get.Responses["404"].Content[httperr.ContentType].Schema; s.Ref != "#/components/schemas/Problem" {
+		t.Errorf("unexpected 404 schema %v", s)
+	}
+	if d := get.Responses["404"].Description; d != "Not Found" {
+		t.Errorf("expected description %q, got %q", "Not Found", d)
+	}
+
+	post := doc.Paths["/users"]["post"]
+	if post == nil {
+		t.Fatalf("expected POST /users, got %v", doc.Paths)
+	}
+	if s := post.RequestBody.Content["application/json"].Schema; !post.RequestBody.Required || s.Ref != "#/components/schemas/address" {
+		t.Errorf("unexpected request body %v", post.RequestBody)
+	}
+	if _, ok := post.Responses["default"].Content[httperr.ContentType]; !ok {
+		t.Errorf("expected problem default response, got %v", post.Responses["default"])
+	}
+
+	files := doc.Paths["/files/{path}"]["get"]
+	if files == nil {
+		t.Fatalf("expected GET /files/{path}, got %v", doc.Paths)
+	}
+	if len(files.Parameters) != 2 || files.Parameters[0].Description != "file path" {
+		t.Errorf("expected declared parameters only, got %v", files.Parameters)
+	}
+	if _, ok := files.Responses["200"]; !ok || files.RequestBody != nil {
+		t.Errorf("expected default 200 response without request body, got %v", files)
+	}
+
+	for _, name := range []string{"user", "address", "Problem"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("expected component %q", name)
+		}
+	}
+}
+
+func TestSpecAddPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []Route
+	}{
+		{
+			name:   "NoMethod",
+			routes: []Route{{Pattern: "/users"}},
+		},
+		{
+			name:   "InvalidPattern",
+			routes: []Route{{Method: http.MethodGet, Pattern: "users"}},
+		},
+		{
+			name: "Duplicate",
+			routes: []Route{
+				{Method: http.MethodGet, Pattern: "/files/{path...}"},
+				{Method: "get", Pattern: "/files/{path}"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			NewSpec(Info{}).Add(test.routes...)
+		})
+	}
+}
+
+func TestSpecHandler(t *testing.T) {
+	spec := NewSpec(Info{Title: "users", Version: "1.0.0"})
+	h := spec.Handler()
+	spec.Add(Route{Method: http.MethodDelete, Pattern: "/users/{id}"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected content type %q, got %q", "application/json", ct)
+	}
+	var doc Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := doc.Paths["/users/{id}"]["delete"]; !ok {
+		t.Errorf("expected route added after the handler was created, got %v", doc.Paths)
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{pattern: "/users", expected: "/users"},
+		{pattern: "/users/{id}", expected: "/users/{id}"},
+		{pattern: "/files/{path...}", expected: "/files/{path}"},
+		{pattern: "/static/*", expected: "/static/{wildcard}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			if actual := Path(test.pattern); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
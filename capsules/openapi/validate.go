@@ -0,0 +1,585 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.adenix.dev/adderall/capsules/codec"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validator validates requests and responses against the operations of a
+// Document. Requests matching no operation are not validated. A Validator is
+// safe for concurrent use.
+type Validator struct {
+	doc      *Document
+	routes   []operationRoute
+	maxBytes int64
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// operationRoute is an Operation and the segments of its path template
+type operationRoute struct {
+	method string
+	segs   []string
+	op     *Operation
+}
+
+// NewValidator instantiates a Validator of the operations of doc. Bodies
+// larger than codec.DefaultMaxBytes are not validated.
+func NewValidator(doc *Document) *Validator {
+	v := &Validator{
+		doc:      doc,
+		maxBytes: codec.DefaultMaxBytes,
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			v.routes = append(v.routes, operationRoute{
+				method: strings.ToUpper(method),
+				segs:   strings.Split(strings.TrimPrefix(path, "/"), "/"),
+				op:     op,
+			})
+		}
+	}
+	return v
+}
+
+// match provides the Operation r is made to and the values of its path
+// parameters. Templates with more static segments are preferred.
+func (v *Validator) match(r *http.Request) (*Operation, map[string]string, bool) {
+	segs := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+
+	var best *Operation
+	var params map[string]string
+	bestScore := -1
+	for _, route := range v.routes {
+		if route.method != r.Method || len(route.segs) != len(segs) {
+			continue
+		}
+		score, values := 0, make(map[string]string)
+		for i, seg := range route.segs {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") && segs[i] != "" {
+				value, err := url.PathUnescape(segs[i])
+				if err != nil {
+					score = -1
+					break
+				}
+				values[seg[1:len(seg)-1]] = value
+				continue
+			}
+			if seg != segs[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore {
+			best, params, bestScore = route.op, values, score
+		}
+	}
+	return best, params, best != nil
+}
+
+// ValidateRequest validates the parameters and body of r against the
+// operation it is made to. A 400 httperr error listing every
+// codec.InvalidParam is returned when validation fails, and a 415 for a body
+// of an undocumented media type. The body of r is restored for the handler.
+func (v *Validator) ValidateRequest(r *http.Request) error {
+	op, pathValues, ok := v.match(r)
+	if !ok {
+		return nil
+	}
+
+	var params []codec.InvalidParam
+	for _, p := range op.Parameters {
+		p := v.parameter(p)
+		if p == nil || p.Name == "" {
+			continue
+		}
+		values := parameterValues(r, p, pathValues)
+		if len(values) == 0 {
+			if p.Required {
+				params = append(params, codec.InvalidParam{Name: p.Name, Reason: "is required"})
+			}
+			continue
+		}
+		value, reason := v.coerce(p.Schema, values)
+		if reason != "" {
+			params = append(params, codec.InvalidParam{Name: p.Name, Reason: reason})
+			continue
+		}
+		v.validate(p.Schema, value, p.Name, &params)
+	}
+
+	if body := v.requestBody(op.RequestBody); body != nil {
+		b, complete, err := v.readBody(r)
+		if err != nil {
+			return httperr.Wrap(err, http.StatusBadRequest, "request body could not be read")
+		}
+		switch {
+		case len(b) == 0 && body.Required:
+			params = append(params, codec.InvalidParam{Name: "body", Reason: "is required"})
+		case len(b) > 0:
+			contentType := r.Header.Get("Content-Type")
+			mt, _, _ := mime.ParseMediaType(contentType)
+			media, ok := mediaType(body.Content, mt)
+			if !ok {
+				return httperr.New(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %q", contentType))
+			}
+			if complete {
+				v.validateBody(media, mt, b, &params)
+			}
+		}
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	return httperr.BadRequest("request does not match the API specification").With("invalid_params", params)
+}
+
+// ValidateResponse validates the status, Content-Type, and body of a response
+// to r against the operation r is made to. A 500 httperr error listing every
+// codec.InvalidParam is returned when validation fails.
+func (v *Validator) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) error {
+	op, _, ok := v.match(r)
+	if !ok {
+		return nil
+	}
+
+	var params []codec.InvalidParam
+	res := v.response(op, status)
+	switch {
+	case res == nil:
+		params = append(params, codec.InvalidParam{Name: "status", Reason: fmt.Sprintf("%d is not documented", status)})
+	case len(body) > 0 && len(res.Content) > 0:
+		mt, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		media, ok := mediaType(res.Content, mt)
+		if !ok {
+			params = append(params, codec.InvalidParam{Name: "Content-Type", Reason: fmt.Sprintf("%q is not documented", mt)})
+			break
+		}
+		v.validateBody(media, mt, body, &params)
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	return httperr.New(http.StatusInternalServerError, "response does not match the API specification").With("invalid_params", params)
+}
+
+// response provides the Response documented for status, falling back to its
+// class, e.g. 4XX, and then the default response
+func (v *Validator) response(op *Operation, status int) *Response {
+	for _, key := range []string{strconv.Itoa(status), fmt.Sprintf("%dXX", status/100), "default"} {
+		if res, ok := op.Responses[key]; ok {
+			return v.resolveResponse(res)
+		}
+	}
+	return nil
+}
+
+// readBody reads up to the size limit of the body of r, reporting whether it
+// was read completely, and restores it for the handler
+func (v *Validator) readBody(r *http.Request) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, v.maxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(b), r.Body), Closer: r.Body}
+	return b, int64(len(b)) <= v.maxBytes, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// validateBody validates a JSON body against the schema of media. Bodies of
+// other media types are not validated.
+func (v *Validator) validateBody(media *MediaType, mt string, b []byte, params *[]codec.InvalidParam) {
+	if media == nil || media.Schema == nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		*params = append(*params, codec.InvalidParam{Name: "body", Reason: "must be valid JSON"})
+		return
+	}
+	v.validate(media.Schema, value, "", params)
+}
+
+// mediaType provides the MediaType of content matching mt, falling back to
+// wildcards
+func mediaType(content map[string]*MediaType, mt string) (*MediaType, bool) {
+	if len(content) == 0 {
+		return nil, true
+	}
+	candidates := []string{mt, "*/*"}
+	if i := strings.Index(mt, "/"); i >= 0 {
+		candidates = []string{mt, mt[:i] + "/*", "*/*"}
+	}
+	for _, c := range candidates {
+		if media, ok := content[c]; ok {
+			return media, true
+		}
+	}
+	return nil, false
+}
+
+// parameterValues provides the values given for p
+func parameterValues(r *http.Request, p *Parameter, pathValues map[string]string) []string {
+	switch p.In {
+	case "path":
+		if value, ok := pathValues[p.Name]; ok {
+			return []string{value}
+		}
+	case "query":
+		return r.URL.Query()[p.Name]
+	case "header":
+		return r.Header.Values(p.Name)
+	case "cookie":
+		if c, err := r.Cookie(p.Name); err == nil {
+			return []string{c.Value}
+		}
+	}
+	return nil
+}
+
+// coerce converts the string values of a parameter to the type of s, arrays
+// being given as repeated or comma separated values
+func (v *Validator) coerce(s *Schema, values []string) (interface{}, string) {
+	s = v.resolve(s)
+	if s == nil {
+		return values[0], ""
+	}
+	if s.Type != "array" {
+		return coerceScalar(s.Type, values[0])
+	}
+
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+	items := v.resolve(s.Items)
+	array := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		typ := ""
+		if items != nil {
+			typ = items.Type
+		}
+		item, reason := coerceScalar(typ, value)
+		if reason != "" {
+			return nil, "items " + reason
+		}
+		array = append(array, item)
+	}
+	return array, ""
+}
+
+func coerceScalar(typ, value string) (interface{}, string) {
+	switch typ {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || (typ == "integer" && n != math.Trunc(n)) {
+			return nil, "must be " + article(typ)
+		}
+		return n, ""
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, "must be a boolean"
+		}
+		return b, ""
+	default:
+		return value, ""
+	}
+}
+
+func article(typ string) string {
+	if typ == "integer" || typ == "array" || typ == "object" {
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+// validate validates value, decoded from JSON, against s, appending every
+// failure to params under name
+func (v *Validator) validate(s *Schema, value interface{}, name string, params *[]codec.InvalidParam) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+	fail := func(reason string) {
+		label := name
+		if label == "" {
+			label = "body"
+		}
+		*params = append(*params, codec.InvalidParam{Name: label, Reason: reason})
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(sub, value, name, params)
+	}
+	if len(s.AnyOf) > 0 && v.matches(s.AnyOf, value) == 0 {
+		fail("must match at least one schema")
+	}
+	if len(s.OneOf) > 0 && v.matches(s.OneOf, value) != 1 {
+		fail("must match exactly one schema")
+	}
+	if s.Not != nil && v.matches([]*Schema{s.Not}, value) == 1 {
+		fail("is not allowed")
+		return
+	}
+
+	if !hasType(s.Type, value) {
+		fail("must be " + article(s.Type))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		options := make([]string, len(s.Enum))
+		for i, o := range s.Enum {
+			options[i] = fmt.Sprint(o)
+		}
+		fail("must be one of " + strings.Join(options, ", "))
+	}
+
+	switch value := value.(type) {
+	case string:
+		n := utf8.RuneCountInString(value)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail(fmt.Sprintf("must be at least %d characters", *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail(fmt.Sprintf("must be at most %d characters", *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			if re := v.pattern(s.Pattern); re != nil && !re.MatchString(value) {
+				fail("must match the pattern " + s.Pattern)
+			}
+		}
+		if reason := checkFormat(s.Format, value); reason != "" {
+			fail(reason)
+		}
+	case float64:
+		if s.Minimum != nil && (value < *s.Minimum || (s.ExclusiveMinimum && value == *s.Minimum)) {
+			relation := "at least"
+			if s.ExclusiveMinimum {
+				relation = "greater than"
+			}
+			fail(fmt.Sprintf("must be %s %v", relation, *s.Minimum))
+		}
+		if s.Maximum != nil && (value > *s.Maximum || (s.ExclusiveMaximum && value == *s.Maximum)) {
+			relation := "at most"
+			if s.ExclusiveMaximum {
+				relation = "less than"
+			}
+			fail(fmt.Sprintf("must be %s %v", relation, *s.Maximum))
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			fail(fmt.Sprintf("must have at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			fail(fmt.Sprintf("must have at most %d items", *s.MaxItems))
+		}
+		for i, item := range value {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", name, i), params)
+		}
+	case map[string]interface{}:
+		for _, required := range s.Required {
+			if _, ok := value[required]; !ok {
+				*params = append(*params, codec.InvalidParam{Name: joinName(name, required), Reason: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := s.Properties[key]; ok {
+				v.validate(prop, value[key], joinName(name, key), params)
+			} else if s.AdditionalProperties != nil {
+				v.validate(s.AdditionalProperties, value[key], joinName(name, key), params)
+			}
+		}
+	}
+}
+
+// matches provides the number of schemas value is valid against
+func (v *Validator) matches(schemas []*Schema, value interface{}) int {
+	n := 0
+	for _, s := range schemas {
+		var params []codec.InvalidParam
+		v.validate(s, value, "", &params)
+		if len(params) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func hasType(typ string, value interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// inEnum reports whether value is one of options, comparing numbers by value
+func inEnum(options []interface{}, value interface{}) bool {
+	for _, o := range options {
+		if reflect.DeepEqual(o, value) {
+			return true
+		}
+		if n, ok := value.(float64); ok {
+			if on, err := strconv.ParseFloat(fmt.Sprint(o), 64); err == nil && on == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkFormat provides the reason value is not of format, formats other than
+// email, date-time, date, uuid, and byte being ignored
+func checkFormat(format, value string) string {
+	switch format {
+	case "email":
+		if a, err := mail.ParseAddress(value); err != nil || a.Address != value {
+			return "must be an email address"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "must be an RFC 3339 date"
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(value) {
+			return "must be a UUID"
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return "must be base64 encoded"
+		}
+	}
+	return ""
+}
+
+// pattern provides the compiled regular expression of a pattern, nil for
+// patterns which cannot be compiled
+func (v *Validator) pattern(p string) *regexp.Regexp {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	re, ok := v.patterns[p]
+	if !ok {
+		re, _ = regexp.Compile(p)
+		v.patterns[p] = re
+	}
+	return re
+}
+
+func joinName(name, key string) string {
+	if name == "" {
+		return key
+	}
+	return name + "." + key
+}
+
+// resolve follows the reference of s to its component schema
+func (v *Validator) resolve(s *Schema) *Schema {
+	// references are followed a bounded number of times to break cycles
+	for i := 0; s != nil && s.Ref != "" && i < 32; i++ {
+		if v.doc.Components == nil {
+			return nil
+		}
+		s = v.doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func (v *Validator) parameter(p Parameter) *Parameter {
+	if p.Ref == "" {
+		return &p
+	}
+	if v.doc.Components == nil {
+		return nil
+	}
+	return v.doc.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+}
+
+func (v *Validator) requestBody(b *RequestBody) *RequestBody {
+	if b == nil || b.Ref == "" {
+		return b
+	}
+	if v.doc.Components == nil {
+		return nil
+	}
+	return v.doc.Components.RequestBodies[strings.TrimPrefix(b.Ref, "#/components/requestBodies/")]
+}
+
+func (v *Validator) resolveResponse(r *Response) *Response {
+	if r == nil || r.Ref == "" {
+		return r
+	}
+	if v.doc.Components == nil {
+		return &Response{}
+	}
+	if res, ok := v.doc.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]; ok {
+		return res
+	}
+	return &Response{}
+}
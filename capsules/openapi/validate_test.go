@@ -0,0 +1,319 @@
+package openapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/codec"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+func testValidator(t *testing.T) *Validator {
+	t.Helper()
+	doc, err := Load([]byte(testOpenAPI))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return NewValidator(doc)
+}
+
+func invalidParams(t *testing.T, err error) []codec.InvalidParam {
+	t.Helper()
+	var e *httperr.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected httperr error, got %v", err)
+	}
+	params, _ := e.Extensions["invalid_params"].([]codec.InvalidParam)
+	return params
+}
+
+func TestValidateRequest(t *testing.T) {
+	const id = "/users/0b6f9f3e-6c1c-4a6e-9a57-3c1f4a8e2b10"
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		params      []codec.InvalidParam
+	}{
+		{
+			name:   "Valid",
+			method: http.MethodGet,
+			target: id + "?verbose=1",
+		},
+		{
+			name:   "Undocumented",
+			method: http.MethodGet,
+			target: "/health",
+		},
+		{
+			name:   "PathParameter",
+			method: http.MethodGet,
+			target: "/users/42",
+			status: http.StatusBadRequest,
+			params: []codec.InvalidParam{{Name: "id", Reason: "must be a UUID"}},
+		},
+		{
+			name:   "QueryParameter",
+			method: http.MethodGet,
+			target: id + "?verbose=yes",
+			status: http.StatusBadRequest,
+			params: []codec.InvalidParam{{Name: "verbose", Reason: "must be an integer"}},
+		},
+		{
+			name:        "ValidBody",
+			method:      http.MethodPut,
+			target:      id,
+			contentType: "application/json",
+			body:        `{"name":"alice","age":30,"tags":["a"]}`,
+		},
+		{
+			name:        "InvalidBody",
+			method:      http.MethodPut,
+			target:      id,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"age":-1,"email":"alice","tags":["a","c","b"],"admin":true}`,
+			status:      http.StatusBadRequest,
+			params: []codec.InvalidParam{
+				{Name: "name", Reason: "is required"},
+				{Name: "admin", Reason: "is not allowed"},
+				{Name: "age", Reason: "must be at least 0"},
+				{Name: "email", Reason: "must be an email address"},
+				{Name: "tags", Reason: "must have at most 2 items"},
+				{Name: "tags[1]", Reason: "must be one of a, b"},
+			},
+		},
+		{
+			name:        "WrongType",
+			method:      http.MethodPut,
+			target:      id,
+			contentType: "application/json",
+			body:        `[]`,
+			status:      http.StatusBadRequest,
+			params:      []codec.InvalidParam{{Name: "body", Reason: "must be an object"}},
+		},
+		{
+			name:        "MalformedBody",
+			method:      http.MethodPut,
+			target:      id,
+			contentType: "application/json",
+			body:        `{`,
+			status:      http.StatusBadRequest,
+			params:      []codec.InvalidParam{{Name: "body", Reason: "must be valid JSON"}},
+		},
+		{
+			name:   "MissingBody",
+			method: http.MethodPut,
+			target: id,
+			status: http.StatusBadRequest,
+			params: []codec.InvalidParam{{Name: "body", Reason: "is required"}},
+		},
+		{
+			name:        "UnsupportedContentType",
+			method:      http.MethodPut,
+			target:      id,
+			contentType: "text/plain",
+			body:        "alice",
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+
+	v := testValidator(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+
+			err := v.ValidateRequest(r)
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				b, _ := io.ReadAll(r.Body)
+				if string(b) != test.body {
+					t.Errorf("expected the body %q to be restored, got %q", test.body, b)
+				}
+				return
+			}
+			if status := httperr.StatusOf(err); status != test.status {
+				t.Fatalf("expected status %d, got %d (%v)", test.status, status, err)
+			}
+			if test.params != nil {
+				if params := invalidParams(t, err); !reflect.DeepEqual(params, test.params) {
+					t.Errorf("expected %v, got %v", test.params, params)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	const id = "/users/0b6f9f3e-6c1c-4a6e-9a57-3c1f4a8e2b10"
+
+	tests := []struct {
+		name        string
+		method      string
+		status      int
+		contentType string
+		body        string
+		params      []codec.InvalidParam
+	}{
+		{
+			name:        "Valid",
+			method:      http.MethodGet,
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"name":"alice"}`,
+		},
+		{
+			name:        "InvalidBody",
+			method:      http.MethodGet,
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"name":""}`,
+			params:      []codec.InvalidParam{{Name: "name", Reason: "must be at least 1 characters"}},
+		},
+		{
+			name:        "UndocumentedContentType",
+			method:      http.MethodGet,
+			status:      http.StatusOK,
+			contentType: "text/html",
+			body:        "<p>alice</p>",
+			params:      []codec.InvalidParam{{Name: "Content-Type", Reason: `"text/html" is not documented`}},
+		},
+		{
+			name:   "UndocumentedStatus",
+			method: http.MethodGet,
+			status: http.StatusTeapot,
+			params: []codec.InvalidParam{{Name: "status", Reason: "418 is not documented"}},
+		},
+		{
+			name:        "StatusClass",
+			method:      http.MethodPut,
+			status:      http.StatusConflict,
+			contentType: httperr.ContentType,
+			body:        `{"status":409}`,
+		},
+		{
+			name:   "NoContent",
+			method: http.MethodPut,
+			status: http.StatusNoContent,
+		},
+	}
+
+	v := testValidator(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, id, nil)
+			header := http.Header{}
+			if test.contentType != "" {
+				header.Set("Content-Type", test.contentType)
+			}
+
+			err := v.ValidateResponse(r, test.status, header, []byte(test.body))
+			if test.params == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if params := invalidParams(t, err); !reflect.DeepEqual(params, test.params) {
+				t.Errorf("expected %v, got %v", test.params, params)
+			}
+		})
+	}
+}
+
+func TestValidateComposition(t *testing.T) {
+	min := 10.0
+	tests := []struct {
+		name   string
+		schema *Schema
+		value  interface{}
+		reason string
+	}{
+		{
+			name:   "AllOf",
+			schema: &Schema{AllOf: []*Schema{{Type: "number"}, {Minimum: &min}}},
+			value:  5.0,
+			reason: "must be at least 10",
+		},
+		{
+			name:   "AnyOf",
+			schema: &Schema{AnyOf: []*Schema{{Type: "string"}, {Type: "boolean"}}},
+			value:  5.0,
+			reason: "must match at least one schema",
+		},
+		{
+			name:   "OneOf",
+			schema: &Schema{OneOf: []*Schema{{Type: "number"}, {Type: "integer"}}},
+			value:  5.0,
+			reason: "must match exactly one schema",
+		},
+		{
+			name:   "Nullable",
+			schema: &Schema{Type: "string", Nullable: true},
+		},
+		{
+			name:   "Null",
+			schema: &Schema{Type: "string"},
+			reason: "must not be null",
+		},
+		{
+			name:   "Pattern",
+			schema: &Schema{Type: "string", Pattern: "^[a-z]+$"},
+			value:  "Alice",
+			reason: "must match the pattern ^[a-z]+$",
+		},
+	}
+
+	v := NewValidator(&Document{})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var params []codec.InvalidParam
+			v.validate(test.schema, test.value, "field", &params)
+			switch {
+			case test.reason == "" && len(params) > 0:
+				t.Errorf("unexpected failures %v", params)
+			case test.reason != "" && (len(params) != 1 || params[0].Reason != test.reason):
+				t.Errorf("expected %q, got %v", test.reason, params)
+			}
+		})
+	}
+}
+
+func TestSpecValidator(t *testing.T) {
+	spec := NewSpec(Info{Title: "users", Version: "1.0.0"})
+	spec.Add(Route{Method: http.MethodPost, Pattern: "/users", Request: user{}})
+
+	v := spec.Validator()
+	if spec.Validator() != v {
+		t.Error("expected the validator to be reused")
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"role":"root"}`))
+	r.Header.Set("Content-Type", "application/json")
+	params := invalidParams(t, v.ValidateRequest(r))
+	expected := []codec.InvalidParam{
+		{Name: "name", Reason: "is required"},
+		{Name: "role", Reason: "must be one of admin, member"},
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+
+	spec.Add(Route{Method: http.MethodGet, Pattern: "/users"})
+	if spec.Validator() == v {
+		t.Error("expected a new validator once routes are added")
+	}
+}
@@ -0,0 +1,138 @@
+// Package ratelimit provides rate limiting state for keys, such as clients,
+// shared by every request made with the same key.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit is the number of requests allowed per period. Burst requests can be
+// made at once, defaulting to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Valid reports whether l limits anything
+func (l Limit) Valid() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// interval provides the time it takes to replenish a single request
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Result is the outcome of taking a request from the limit of a key
+type Result struct {
+	// Allowed reports whether the request is within the limit
+	Allowed bool
+	// Limit is the number of requests which can be made at once
+	Limit int
+	// Remaining is the number of requests which can still be made at once
+	Remaining int
+	// RetryAfter is the time until the next request is allowed when the
+	// request was not allowed
+	RetryAfter time.Duration
+	// ResetAfter is the time until the limit is fully replenished
+	ResetAfter time.Duration
+}
+
+// Store takes requests from the limit of keys. Implementations backed by a
+// shared database allow limits to be enforced across instances.
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// MemoryStore is a Store keeping the state of keys in memory using the
+// generic cell rate algorithm, a token bucket tracking a single timestamp per
+// key.
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// sweepInterval is how often keys with a replenished limit are forgotten
+const sweepInterval = time.Minute
+
+// NewMemoryStore instantiates a MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tats: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// Allow takes a request from the limit of key
+func (m *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Valid() {
+		return Result{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	interval := limit.interval()
+	burst := limit.burst()
+
+	// tat is the theoretical arrival time, at which the limit of the key is
+	// fully replenished
+	tat := m.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-time.Duration(burst) * interval)
+
+	if now.Before(allowAt) {
+		return Result{
+			Limit:      burst,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	m.tats[key] = newTat
+	return Result{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// Len provides the number of keys tracked
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tats)
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, tat := range m.tats {
+		if !tat.After(now) {
+			delete(m.tats, key)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	type step struct {
+		advance  time.Duration
+		expected Result
+	}
+
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "Burst",
+			limit: Limit{Requests: 2, Period: time.Second},
+			steps: []step{
+				{expected: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 500 * time.Millisecond}},
+				{expected: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+				{expected: Result{Limit: 2, RetryAfter: 500 * time.Millisecond, ResetAfter: time.Second}},
+				{advance: 500 * time.Millisecond, expected: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+				{advance: 2 * time.Second, expected: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name:  "ExplicitBurst",
+			limit: Limit{Requests: 10, Period: time.Second, Burst: 1},
+			steps: []step{
+				{expected: Result{Allowed: true, Limit: 1, Remaining: 0, ResetAfter: 100 * time.Millisecond}},
+				{expected: Result{Limit: 1, RetryAfter: 100 * time.Millisecond, ResetAfter: 100 * time.Millisecond}},
+				{advance: 100 * time.Millisecond, expected: Result{Allowed: true, Limit: 1, Remaining: 0, ResetAfter: 100 * time.Millisecond}},
+			},
+		},
+		{
+			name:  "Unlimited",
+			limit: Limit{},
+			steps: []step{
+				{expected: Result{Allowed: true}},
+				{expected: Result{Allowed: true}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			m := NewMemoryStore()
+			m.now = func() time.Time { return now }
+
+			for i, s := range test.steps {
+				now = now.Add(s.advance)
+				res, err := m.Allow(context.Background(), "key", test.limit)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if res != s.expected {
+					t.Errorf("step %d: expected %+v, got %+v", i, s.expected, res)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+	limit := Limit{Requests: 1, Period: time.Second}
+
+	for _, key := range []string{"a", "b"} {
+		if res, _ := m.Allow(context.Background(), key, limit); !res.Allowed {
+			t.Errorf("expected key %q to be allowed", key)
+		}
+	}
+	if m.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", m.Len())
+	}
+
+	now = now.Add(sweepInterval)
+	_, _ = m.Allow(context.Background(), "c", limit)
+	if m.Len() != 1 {
+		t.Errorf("expected replenished keys to be swept, got %d keys", m.Len())
+	}
+}
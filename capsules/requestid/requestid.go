@@ -0,0 +1,54 @@
+// Package requestid correlates the requests handled and sent on behalf of a
+// single inbound request through the X-Request-ID header.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// Header is the HTTP header carrying the request id
+const Header = "X-Request-ID"
+
+// maxLength bounds the length of request ids accepted from clients
+const maxLength = 128
+
+type contextKey struct{}
+
+// NewContext provides a copy of ctx carrying the request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext provides the request id carried by ctx
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// New generates a random request id in the form of a version 4 UUID
+func New() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("requestid: reading random bytes: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Valid reports whether id is acceptable as a request id received from a
+// client. Ids must be at most 128 printable ASCII characters without spaces,
+// preventing log injection through the header.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
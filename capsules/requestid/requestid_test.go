@@ -0,0 +1,62 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if id, ok := FromContext(context.Background()); ok {
+		t.Errorf("expected no request id, got %q", id)
+	}
+
+	ctx := NewContext(context.Background(), "foo")
+	if id, ok := FromContext(ctx); !ok || id != "foo" {
+		t.Errorf("expected request id %q, got %q", "foo", id)
+	}
+
+	ctx = NewContext(context.Background(), "")
+	if id, ok := FromContext(ctx); ok {
+		t.Errorf("expected empty request id to be ignored, got %q", id)
+	}
+}
+
+func TestNew(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	a, b := New(), New()
+	if !uuid.MatchString(a) {
+		t.Errorf("expected a version 4 uuid, got %q", a)
+	}
+	if a == b {
+		t.Errorf("expected unique request ids, got %q twice", a)
+	}
+	if !Valid(a) {
+		t.Errorf("expected generated request id %q to be valid", a)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{id: "abc-123", expected: true},
+		{id: strings.Repeat("a", maxLength), expected: true},
+		{id: "", expected: false},
+		{id: strings.Repeat("a", maxLength+1), expected: false},
+		{id: "foo bar", expected: false},
+		{id: "foo\nbar", expected: false},
+		{id: "föo", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			if valid := Valid(test.id); valid != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, valid)
+			}
+		})
+	}
+}
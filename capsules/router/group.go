@@ -0,0 +1,86 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Group registers routes below a common prefix, wrapping their handlers with
+// the middleware of the Group. Middleware applies to the routes registered
+// after it is added.
+type Group struct {
+	routes
+}
+
+// routes registers routes of a Router or Group
+type routes struct {
+	router     *Router
+	prefix     string
+	middleware []func(http.Handler) http.Handler
+}
+
+// Use adds middleware wrapping the handlers of routes subsequently
+// registered, the first being outermost.
+func (g *routes) Use(middleware ...func(http.Handler) http.Handler) {
+	g.middleware = append(g.middleware, middleware...)
+}
+
+// Group provides a Group for routes below prefix, wrapped by the middleware
+// registered so far and then by middleware.
+func (g *routes) Group(prefix string, middleware ...func(http.Handler) http.Handler) *Group {
+	return &Group{routes{
+		router:     g.router,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: append(append([]func(http.Handler) http.Handler(nil), g.middleware...), middleware...),
+	}}
+}
+
+// Handle registers h for requests with method to pattern. An empty method
+// matches every method without a handler of its own. GET handlers also serve
+// HEAD requests. Handle panics when the pattern is invalid or already has a
+// handler for method.
+func (g *routes) Handle(method, pattern string, h http.Handler) {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		h = g.middleware[i](h)
+	}
+	if err := g.router.root.insert(g.prefix+pattern, strings.ToUpper(method), h); err != nil {
+		panic(fmt.Sprintf("router: %s %s: %s", method, g.prefix+pattern, err))
+	}
+}
+
+// HandleFunc registers handler for pattern, which may start with a method,
+// e.g. "GET /users/{id}". Without a method, every method is matched. It
+// satisfies server.Handler.
+func (g *routes) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	method := ""
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		method, pattern = pattern[:i], strings.TrimSpace(pattern[i:])
+	}
+	g.Handle(method, pattern, http.HandlerFunc(handler))
+}
+
+// Get registers handler for GET and HEAD requests to pattern
+func (g *routes) Get(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodGet, pattern, handler)
+}
+
+// Post registers handler for POST requests to pattern
+func (g *routes) Post(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPost, pattern, handler)
+}
+
+// Put registers handler for PUT requests to pattern
+func (g *routes) Put(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPut, pattern, handler)
+}
+
+// Patch registers handler for PATCH requests to pattern
+func (g *routes) Patch(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPatch, pattern, handler)
+}
+
+// Delete registers handler for DELETE requests to pattern
+func (g *routes) Delete(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodDelete, pattern, handler)
+}
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// node is a segment of the route tree
+type node struct {
+	static   map[string]*node
+	param    *node
+	wildcard *node
+	// name is the name of a parameter or wildcard segment
+	name string
+
+	// pattern and handlers are set on nodes ending a route
+	pattern  string
+	handlers map[string]http.Handler
+}
+
+// handler provides the handler of n for method
+func (n *node) handler(method string) (http.Handler, bool) {
+	h, ok := n.handlers[method]
+	if !ok && method == http.MethodHead {
+		h, ok = n.handlers[http.MethodGet]
+	}
+	if !ok {
+		h, ok = n.handlers[""]
+	}
+	return h, ok
+}
+
+// segments splits a path into its segments, a trailing slash yielding an
+// empty last segment
+func segments(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func (n *node) insert(pattern, method string, h http.Handler) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New("pattern must begin with /")
+	}
+
+	segs := segments(pattern)
+	for i, seg := range segs {
+		switch {
+		case seg == "*" || (strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}")):
+			if i != len(segs)-1 {
+				return errors.New("wildcard must be the last segment")
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "...}")
+			if seg == "*" {
+				name = "*"
+			}
+			if n.wildcard == nil {
+				n.wildcard = &node{name: name}
+			} else if n.wildcard.name != name {
+				return fmt.Errorf("wildcard %q conflicts with %q", name, n.wildcard.name)
+			}
+			n = n.wildcard
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			name := seg[1 : len(seg)-1]
+			if name == "" {
+				return errors.New("parameter must be named")
+			}
+			if n.param == nil {
+				n.param = &node{name: name}
+			} else if n.param.name != name {
+				return fmt.Errorf("parameter %q conflicts with %q", name, n.param.name)
+			}
+			n = n.param
+		default:
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			child, ok := n.static[seg]
+			if !ok {
+				child = &node{}
+				n.static[seg] = child
+			}
+			n = child
+		}
+	}
+
+	if n.handlers == nil {
+		n.handlers = make(map[string]http.Handler)
+	}
+	if _, ok := n.handlers[method]; ok {
+		return errors.New("route already registered")
+	}
+	n.pattern = pattern
+	n.handlers[method] = h
+	return nil
+}
+
+// lookup provides the node of the route matching segs which is accepted by
+// accept, recording the values of its parameters in params
+func (n *node) lookup(segs []string, params map[string]string, accept func(n *node) bool) *node {
+	if len(segs) == 0 {
+		if accept(n) {
+			return n
+		}
+		if n.wildcard != nil && accept(n.wildcard) {
+			// a wildcard also matches an empty rest of the path
+			params[n.wildcard.name] = ""
+			return n.wildcard
+		}
+		return nil
+	}
+
+	seg, rest := segs[0], segs[1:]
+	if child, ok := n.static[seg]; ok {
+		if found := child.lookup(rest, params, accept); found != nil {
+			return found
+		}
+	}
+	if n.param != nil && seg != "" {
+		if found := n.param.lookup(rest, params, accept); found != nil {
+			params[n.param.name] = unescape(seg)
+			return found
+		}
+	}
+	if n.wildcard != nil && accept(n.wildcard) {
+		params[n.wildcard.name] = unescape(strings.Join(segs, "/"))
+		return n.wildcard
+	}
+	return nil
+}
+
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+// Package router provides a Router matching requests by method and path
+// pattern, with path parameters and groups of routes sharing middleware. The
+// Router reports the pattern it matched, which the server uses for the route
+// of metrics, logs, and spans.
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router is a http.Handler dispatching requests to the handler registered for
+// their method and path. Patterns are paths whose segments are either static,
+// a parameter such as {id}, or, as the last segment, a wildcard {name...} or *
+// matching the rest of the path. Static segments take precedence over
+// parameters, and parameters over wildcards.
+type Router struct {
+	routes
+
+	root *node
+
+	// NotFound handles requests matching no route.
+	// Defaults to http.NotFound
+	NotFound http.Handler
+	// MethodNotAllowed handles requests matching a route but none of its
+	// methods. The Allow header is set before it is called.
+	// Defaults to a plain 405
+	MethodNotAllowed http.Handler
+}
+
+// New instantiates a Router
+func New() *Router {
+	rt := &Router{root: &node{}}
+	rt.routes = routes{router: rt}
+	return rt
+}
+
+// ServeHTTP dispatches r to the handler of its route
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern, params := rt.match(r)
+	if pattern != "" {
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, &match{pattern: pattern, params: params}))
+	}
+	h.ServeHTTP(w, r)
+}
+
+// Handler provides the handler for r and the pattern of the route it matches,
+// or an empty pattern when it matches no route.
+func (rt *Router) Handler(r *http.Request) (http.Handler, string) {
+	h, pattern, _ := rt.match(r)
+	return h, pattern
+}
+
+func (rt *Router) match(r *http.Request) (http.Handler, string, map[string]string) {
+	segs := segments(r.URL.EscapedPath())
+	params := make(map[string]string)
+	n := rt.root.lookup(segs, params, func(n *node) bool {
+		_, ok := n.handler(r.Method)
+		return ok
+	})
+	if n != nil {
+		h, _ := n.handler(r.Method)
+		return h, n.pattern, params
+	}
+
+	// a route for another method makes it a 405 rather than a 404
+	n = rt.root.lookup(segs, params, func(n *node) bool { return n.handlers != nil })
+	if n != nil {
+		return rt.methodNotAllowed(n), n.pattern, params
+	}
+	if rt.NotFound != nil {
+		return rt.NotFound, "", nil
+	}
+	return http.NotFoundHandler(), "", nil
+}
+
+func (rt *Router) methodNotAllowed(n *node) http.Handler {
+	allowed := make([]string, 0, len(n.handlers)+1)
+	for method := range n.handlers {
+		allowed = append(allowed, method)
+	}
+	if _, ok := n.handlers[http.MethodGet]; ok {
+		if _, ok := n.handlers[http.MethodHead]; !ok {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	sort.Strings(allowed)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if rt.MethodNotAllowed != nil {
+			rt.MethodNotAllowed.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
+}
+
+type contextKey struct{}
+
+// match is the route a request matched
+type match struct {
+	pattern string
+	params  map[string]string
+}
+
+// Param provides the value of the path parameter name of the route r matched
+func Param(r *http.Request, name string) string {
+	if m, ok := r.Context().Value(contextKey{}).(*match); ok {
+		return m.params[name]
+	}
+	return ""
+}
+
+// Pattern provides the pattern of the route r matched
+func Pattern(r *http.Request) string {
+	if m, ok := r.Context().Value(contextKey{}).(*match); ok {
+		return m.pattern
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rt := New()
+	respond := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + " " + Pattern(r) + " " + Param(r, "id") + Param(r, "path")))
+		}
+	}
+	rt.HandleFunc("/", respond("root"))
+	rt.Get("/users", respond("list"))
+	rt.Post("/users", respond("create"))
+	rt.Get("/users/{id}", respond("get"))
+	rt.Delete("/users/{id}", respond("delete"))
+	rt.Get("/users/me", respond("me"))
+	rt.HandleFunc("PUT /users/{id}", respond("put"))
+	rt.Get("/users/{id}/posts", respond("posts"))
+	rt.Get("/files/{path...}", respond("files"))
+	rt.HandleFunc("/any", respond("any"))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		expected string
+		allow    string
+	}{
+		{name: "Root", method: http.MethodGet, path: "/", status: http.StatusOK, expected: "root / "},
+		{name: "Static", method: http.MethodGet, path: "/users", status: http.StatusOK, expected: "list /users "},
+		{name: "Method", method: http.MethodPost, path: "/users", status: http.StatusOK, expected: "create /users "},
+		{name: "Param", method: http.MethodGet, path: "/users/42", status: http.StatusOK, expected: "get /users/{id} 42"},
+		{name: "ParamEscaped", method: http.MethodGet, path: "/users/a%2Fb", status: http.StatusOK, expected: "get /users/{id} a/b"},
+		{name: "StaticPrecedence", method: http.MethodGet, path: "/users/me", status: http.StatusOK, expected: "me /users/me "},
+		{name: "StaticFallback", method: http.MethodDelete, path: "/users/me", status: http.StatusOK, expected: "delete /users/{id} me"},
+		{name: "MethodPattern", method: http.MethodPut, path: "/users/42", status: http.StatusOK, expected: "put /users/{id} 42"},
+		{name: "Nested", method: http.MethodGet, path: "/users/42/posts", status: http.StatusOK, expected: "posts /users/{id}/posts 42"},
+		{name: "Head", method: http.MethodHead, path: "/users", status: http.StatusOK},
+		{name: "Wildcard", method: http.MethodGet, path: "/files/a/b.txt", status: http.StatusOK, expected: "files /files/{path...} a/b.txt"},
+		{name: "WildcardEmpty", method: http.MethodGet, path: "/files/", status: http.StatusOK, expected: "files /files/{path...} "},
+		{name: "AnyMethod", method: http.MethodPatch, path: "/any", status: http.StatusOK, expected: "any /any "},
+		{name: "NotFound", method: http.MethodGet, path: "/unknown", status: http.StatusNotFound},
+		{name: "TrailingSlash", method: http.MethodGet, path: "/users/", status: http.StatusNotFound},
+		{name: "MethodNotAllowed", method: http.MethodPatch, path: "/users/42", status: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD, PUT"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rt.ServeHTTP(rr, httptest.NewRequest(test.method, test.path, nil))
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if test.expected != "" && rr.Body.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, rr.Body.String())
+			}
+			if allow := rr.Header().Get("Allow"); allow != test.allow {
+				t.Errorf("expected Allow %q, got %q", test.allow, allow)
+			}
+		})
+	}
+}
+
+func TestRouterHandler(t *testing.T) {
+	rt := New()
+	rt.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		path     string
+		method   string
+		expected string
+	}{
+		{path: "/users/42", method: http.MethodGet, expected: "/users/{id}"},
+		{path: "/users/42", method: http.MethodPost, expected: "/users/{id}"},
+		{path: "/posts/42", method: http.MethodGet},
+	}
+
+	for _, test := range tests {
+		if _, pattern := rt.Handler(httptest.NewRequest(test.method, test.path, nil)); pattern != test.expected {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.path, test.expected, pattern)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	rt := New()
+	rt.Use(record("router"))
+	api := rt.Group("/api/", record("api"))
+	v1 := api.Group("/v1")
+	v1.Use(record("v1"))
+	v1.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, Pattern(r)+" "+Param(r, "id"))
+	})
+	api.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, Pattern(r))
+	})
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "/api/v1/users/42", expected: []string{"router", "api", "v1", "/api/v1/users/{id} 42"}},
+		{path: "/api/health", expected: []string{"router", "api", "/api/health"}},
+	}
+
+	for _, test := range tests {
+		calls = nil
+		rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.path, nil))
+		if len(calls) != len(test.expected) {
+			t.Fatalf("%s: expected %v, got %v", test.path, test.expected, calls)
+		}
+		for i := range calls {
+			if calls[i] != test.expected[i] {
+				t.Errorf("%s: expected %v, got %v", test.path, test.expected, calls)
+				break
+			}
+		}
+	}
+}
+
+func TestRouterCustomHandlers(t *testing.T) {
+	rt := New()
+	rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusConflict) })
+	rt.Get("/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	for path, status := range map[string]int{"/unknown": http.StatusTeapot, "/users": http.StatusConflict} {
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, nil))
+		if rr.Code != status {
+			t.Errorf("%s: expected %d, got %d", path, status, rr.Code)
+		}
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(rt *Router)
+	}{
+		{name: "Relative", register: func(rt *Router) { rt.Get("users", nil) }},
+		{name: "Duplicate", register: func(rt *Router) { rt.Get("/users", nil); rt.Get("/users", nil) }},
+		{name: "ParamConflict", register: func(rt *Router) { rt.Get("/users/{id}", nil); rt.Get("/users/{name}/posts", nil) }},
+		{name: "WildcardNotLast", register: func(rt *Router) { rt.Get("/files/{path...}/raw", nil) }},
+		{name: "UnnamedParam", register: func(rt *Router) { rt.Get("/users/{}", nil) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			test.register(New())
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/requestid"
+)
+
+// AccessLogConfig contains options for the access log
+type AccessLogConfig struct {
+	// Disabled turns the access log off
+	Disabled bool
+	// LogProbes logs requests to the liveness, readiness, and health probes,
+	// which are excluded by default
+	LogProbes bool
+	// ExcludePaths are additional paths which are not logged
+	ExcludePaths []string
+	// SampleEvery logs one in every SampleEvery successful requests. Zero or
+	// one logs every request.
+	SampleEvery int
+	// SlowThresholdMs marks requests taking at least this long as slow. Zero
+	// disables slow request detection.
+	SlowThresholdMs int
+}
+
+var probePaths = []string{"/live", "/ready", "/health"}
+
+// AccessLogMiddleware logs one line at info level for every request with its
+// status, size, duration, client, request id, route pattern, and trace id.
+// Failed and slow requests are always logged, regardless of exclusions and
+// sampling.
+func (s *Server) AccessLogMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		c := AccessLogConfig{}
+		if s.config.AccessLog != nil {
+			c = *s.config.AccessLog
+		}
+		if c.Disabled {
+			return next
+		}
+
+		excluded := make(map[string]bool)
+		if !c.LogProbes {
+			for _, p := range probePaths {
+				excluded[p] = true
+			}
+		}
+		for _, p := range c.ExcludePaths {
+			excluded[p] = true
+		}
+		slow := time.Duration(c.SlowThresholdMs) * time.Millisecond
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := newResponseWriter(w)
+			r, info := withRequestInfo(r)
+			next.ServeHTTP(rw, r)
+			duration := time.Since(start)
+
+			isSlow := slow > 0 && duration >= slow
+			failed := rw.Status() >= http.StatusBadRequest
+			if !isSlow && !failed {
+				if excluded[r.URL.Path] {
+					return
+				}
+				if c.SampleEvery > 1 && (atomic.AddUint32(&s.accessLogRequests, 1)-1)%uint32(c.SampleEvery) != 0 {
+					return
+				}
+			}
+
+			fields := []interface{}{
+				"method", r.Method,
+				"path", r.URL.EscapedPath(),
+				"route", s.routePattern(r),
+				"status", rw.Status(),
+				"bytes", rw.BytesWritten(),
+				"duration", duration,
+				"remote_addr", r.RemoteAddr,
+				"user_agent", r.UserAgent(),
+			}
+			if id, ok := requestid.FromContext(r.Context()); ok {
+				fields = append(fields, "request_id", id)
+			}
+			if id := httperr.TraceID(r.Context()); id != "" {
+				fields = append(fields, "trace_id", id)
+			}
+			if isSlow {
+				fields = append(fields, "slow", true)
+			}
+			if info.timedOut {
+				fields = append(fields, "timeout", true)
+			}
+			if info.subject != "" {
+				fields = append(fields, "subject", info.subject)
+			}
+			s.logger.InfoCtx(r.Context(), "http request", fields...)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
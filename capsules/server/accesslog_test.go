@@ -0,0 +1,154 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *AccessLogConfig
+		paths    []string
+		expected []int
+	}{
+		{
+			name:     "Default",
+			paths:    []string{"/test", "/fail", "/live", "/ready", "/health"},
+			expected: []int{http.StatusOK, http.StatusInternalServerError},
+		},
+		{
+			name:     "Disabled",
+			config:   &AccessLogConfig{Disabled: true},
+			paths:    []string{"/test", "/fail"},
+			expected: nil,
+		},
+		{
+			name:     "LogProbes",
+			config:   &AccessLogConfig{LogProbes: true},
+			paths:    []string{"/live"},
+			expected: []int{http.StatusNoContent},
+		},
+		{
+			name:     "ExcludePaths",
+			config:   &AccessLogConfig{ExcludePaths: []string{"/test", "/fail"}},
+			paths:    []string{"/test", "/fail"},
+			expected: []int{http.StatusInternalServerError},
+		},
+		{
+			name:     "SampleEvery",
+			config:   &AccessLogConfig{SampleEvery: 3},
+			paths:    []string{"/test", "/test", "/fail", "/test", "/test", "/test"},
+			expected: []int{http.StatusOK, http.StatusInternalServerError, http.StatusOK},
+		},
+		{
+			name:     "Slow",
+			config:   &AccessLogConfig{ExcludePaths: []string{"/slow"}, SlowThresholdMs: 1},
+			paths:    []string{"/slow"},
+			expected: []int{http.StatusOK},
+		},
+		{
+			name:     "Panic",
+			paths:    []string{"/panic"},
+			expected: []int{http.StatusInternalServerError},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			s := NewFactory(WithLogger(logger), WithTracer(mocktracer.New())).Create(
+				WithServerRouter(newAccessLogRouter()),
+				WithServerConfig(Config{AccessLog: test.config}),
+			)
+
+			for _, path := range test.paths {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.Header.Set("User-Agent", "test")
+				req.Header.Set("X-Request-ID", "abc")
+				s.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			entries := accessLogs(logger)
+			if len(entries) != len(test.expected) {
+				t.Fatalf("expected %d access logs, got %v", len(test.expected), entries)
+			}
+			for i, e := range entries {
+				if status := e.field("status"); status != test.expected[i] {
+					t.Errorf("expected status %d, got %v", test.expected[i], status)
+				}
+				for _, key := range []string{"user_agent", "request_id", "trace_id"} {
+					if v, _ := e.field(key).(string); v == "" {
+						t.Errorf("expected %s to be logged, got %v", key, e.fields)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAccessLogFields(t *testing.T) {
+	logger := &recordingLogger{}
+	s := NewFactory(WithLogger(logger)).Create(
+		WithServerRouter(newAccessLogRouter()),
+		WithServerAccessLogConfig(AccessLogConfig{SlowThresholdMs: 1}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := accessLogs(logger)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 access log, got %v", entries)
+	}
+	expected := map[string]interface{}{
+		"method":      http.MethodGet,
+		"path":        "/slow",
+		"route":       "/slow",
+		"bytes":       len("slow"),
+		"remote_addr": "10.0.0.1:1234",
+		"slow":        true,
+	}
+	for key, value := range expected {
+		if v := entries[0].field(key); v != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, v)
+		}
+	}
+	if d, _ := entries[0].field("duration").(time.Duration); d < time.Millisecond {
+		t.Errorf("expected duration of at least 1ms, got %v", d)
+	}
+}
+
+// accessLogs provides the access log entries recorded by l
+func accessLogs(l *recordingLogger) []logEntry {
+	var entries []logEntry
+	for _, e := range l.entries("info") {
+		if e.msg == "http request" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func newAccessLogRouter() Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("test"))
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		_, _ = w.Write([]byte("slow"))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	return mux
+}
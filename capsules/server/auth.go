@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// authOptions are the Authenticator of a Server and the options of its
+// middleware, and the Authorizer of its routes
+type authOptions struct {
+	authenticator auth.Authenticator
+	opts          []auth.Option
+	authorizer    auth.Authorizer
+}
+
+// AuthenticationMiddleware authenticates requests with the Authenticator of
+// the Server, placing the auth.Principal in the request context and rejecting
+// unauthenticated requests with a 401. Health probes, metrics, the OpenAPI
+// document, and the Swagger UI are exempt. It is a noop when the Server has no Authenticator.
+func (s *Server) AuthenticationMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.auth.authenticator == nil {
+			return next
+		}
+
+		exempt := append([]string{"/metrics"}, probePaths...)
+		exempt = append(exempt, s.swaggerPaths()...)
+		opts := append([]auth.Option{auth.WithLogger(s.logger), auth.WithExemptPaths(exempt...)}, s.auth.opts...)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := auth.FromContext(r.Context()); ok {
+				setSubject(r.Context(), p.Subject)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return auth.Middleware(s.auth.authenticator, opts...)(http.HandlerFunc(fn))
+	}
+}
+
+func setSubject(ctx context.Context, subject string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.subject = subject
+	}
+}
+
+// HandleFunc registers handler for pattern on the Router. Requests are only
+// served when the Authorizer of the Server, see WithServerAuthorizer, and
+// every one of authorizers allow the auth.Principal making them. Anonymous
+// requests which need to authenticate are rejected with a 401, and denied
+// requests with a 403. Authorizers get the route pattern the Router matched,
+// as reported in metrics, logs and spans, e.g. "/users/{id}" for a handler
+// registered for "GET /users/{id}".
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request), authorizers ...auth.Authorizer) {
+	s.Router.HandleFunc(pattern, s.authorize(auth.AllOf(append([]auth.Authorizer{s.auth.authorizer}, authorizers...)...), handler))
+}
+
+// authorize wraps handler, serving only requests allowed by a for their route
+// pattern
+func (s *Server) authorize(a auth.Authorizer, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pattern := s.routePattern(r)
+		p, _ := auth.FromContext(r.Context())
+		err := a.Authorize(r, pattern, p)
+		if err == nil {
+			handler(w, r)
+			return
+		}
+
+		if errors.Is(err, auth.ErrNoCredentials) {
+			s.logger.DebugCtx(r.Context(), "authentication required", "route", pattern)
+			if s.auth.authenticator != nil {
+				for _, challenge := range auth.Challenges(s.auth.authenticator) {
+					w.Header().Add("WWW-Authenticate", challenge)
+				}
+			}
+			httperr.Write(w, r, httperr.Problem{Status: http.StatusUnauthorized})
+			return
+		}
+
+		reason := "access denied"
+		var denial *auth.Denial
+		if errors.As(err, &denial) {
+			reason = denial.Reason
+			s.logger.DebugCtx(r.Context(), "authorization denied", "route", pattern, "reason", reason)
+		} else {
+			s.logger.WarnCtx(r.Context(), "authorization failed", "route", pattern, "error", err)
+		}
+
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			span.SetTag("auth.denied", true)
+			span.SetTag("auth.denial_reason", reason)
+			span.LogFields(
+				log.String("event", "authorization denied"),
+				log.String("reason", reason),
+			)
+		}
+
+		httperr.Write(w, r, httperr.Problem{Status: http.StatusForbidden, Detail: reason})
+	}
+}
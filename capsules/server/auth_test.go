@@ -0,0 +1,179 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/auth/authtest"
+)
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	authenticator := auth.NewAPIKeyAuthenticator(auth.APIKeyConfig{Keys: []auth.APIKey{{Key: "secret", Subject: "svc"}}})
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		path    string
+		key     string
+		status  int
+		subject string
+	}{
+		{name: "Disabled", path: "/users", status: http.StatusOK},
+		{
+			name:    "Authenticated",
+			opts:    []Option{WithServerAuthenticator(authenticator)},
+			path:    "/users",
+			key:     "secret",
+			status:  http.StatusOK,
+			subject: "svc",
+		},
+		{
+			name:   "Unauthenticated",
+			opts:   []Option{WithServerAuthenticator(authenticator)},
+			path:   "/users",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Probe",
+			opts:   []Option{WithServerAuthenticator(authenticator)},
+			path:   "/live",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "Exempt",
+			opts:   []Option{WithServerAuthenticator(authenticator, auth.WithExemptPaths("/users"))},
+			path:   "/users",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var subject string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+				if p, ok := auth.FromContext(r.Context()); ok {
+					subject = p.Subject
+				}
+			})
+			logger := &recordingLogger{}
+			s := NewFactory(WithLogger(logger)).Create(append([]Option{WithServerRouter(mux)}, test.opts...)...)
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.key != "" {
+				req.Header.Set("X-API-Key", test.key)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if subject != test.subject {
+				t.Errorf("expected subject %q, got %q", test.subject, subject)
+			}
+			if entries := accessLogs(logger); len(entries) == 1 {
+				if v, _ := entries[0].field("subject").(string); v != test.subject {
+					t.Errorf("expected subject %q to be logged, got %q", test.subject, v)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleFunc(t *testing.T) {
+	reader := &auth.Principal{Subject: "reader", Scopes: []string{"read"}}
+	admin := &auth.Principal{Subject: "admin", Scopes: []string{"read", "write"}, Roles: []string{"admin"}}
+	byRoute := auth.AuthorizerFunc(func(r *http.Request, route string, p *auth.Principal) error {
+		if route == "/admin" && (p == nil || !p.HasRole("admin")) {
+			return auth.Deny("admin only")
+		}
+		return nil
+	})
+	failing := auth.AuthorizerFunc(func(r *http.Request, route string, p *auth.Principal) error {
+		return errors.New("policy store unavailable")
+	})
+
+	tests := []struct {
+		name        string
+		principal   *auth.Principal
+		authorizer  auth.Authorizer
+		route       string
+		authorizers []auth.Authorizer
+		status      int
+		reason      string
+	}{
+		{name: "Public", route: "/users", status: http.StatusOK},
+		{name: "Scope", principal: admin, route: "/users", authorizers: []auth.Authorizer{auth.RequireScopes("write")}, status: http.StatusOK},
+		{
+			name:        "MissingScope",
+			principal:   reader,
+			route:       "/users",
+			authorizers: []auth.Authorizer{auth.RequireScopes("write")},
+			status:      http.StatusForbidden,
+			reason:      "missing scope write",
+		},
+		{name: "Anonymous", route: "/users", authorizers: []auth.Authorizer{auth.RequireScopes("write")}, status: http.StatusUnauthorized},
+		{name: "ServerAuthorizer", principal: admin, authorizer: byRoute, route: "/admin", status: http.StatusOK},
+		{name: "ServerAuthorizerDenied", principal: reader, authorizer: byRoute, route: "/admin", status: http.StatusForbidden, reason: "admin only"},
+		{name: "ServerAuthorizerRoute", principal: reader, authorizer: byRoute, route: "/users", status: http.StatusOK},
+		{name: "Failing", principal: admin, authorizer: failing, route: "/users", status: http.StatusForbidden, reason: "access denied"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracer := mocktracer.New()
+			s := NewFactory(WithTracer(tracer)).Create(
+				WithServerAuthenticator(authtest.Authenticator(test.principal), auth.WithOptional(true)),
+				WithServerAuthorizer(test.authorizer),
+			)
+			s.HandleFunc(test.route, func(w http.ResponseWriter, r *http.Request) {}, test.authorizers...)
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, test.route, nil))
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if test.status == http.StatusOK {
+				return
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("expected a problem response, got %q", ct)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(spans))
+			}
+			if reason, _ := spans[0].Tag("auth.denial_reason").(string); reason != test.reason {
+				t.Errorf("expected denial reason %q, got %q", test.reason, reason)
+			}
+			if test.reason != "" && !strings.Contains(rr.Body.String(), test.reason) {
+				t.Errorf("expected %q in %q", test.reason, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithPrincipal(t *testing.T) {
+	s := NewFactory().Create()
+	var subject string
+	h := s.authorize(auth.RequireRoles("admin"), func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.FromContext(r.Context())
+		subject = p.Subject
+	})
+
+	req := authtest.WithPrincipal(httptest.NewRequest(http.MethodGet, "/users", nil), &auth.Principal{Subject: "foo", Roles: []string{"admin"}})
+	rr := httptest.NewRecorder()
+	h(rr, req)
+
+	if rr.Code != http.StatusOK || subject != "foo" {
+		t.Errorf("expected foo to be allowed, got %d and %q", rr.Code, subject)
+	}
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CompressionConfig contains options for compressing responses
+type CompressionConfig struct {
+	// Encodings are the content codings offered, in order of preference when
+	// a client accepts several equally.
+	// Defaults to gzip and deflate
+	Encodings []string
+	// Level is the compression level, from 1 (fastest) to 9 (best). Levels
+	// outside that range are clamped to it.
+	// Defaults to the default level of each encoder
+	Level int
+	// MinSizeBytes is the smallest response compressed. Streamed responses
+	// are compressed regardless of size once flushed.
+	// Defaults to 1024
+	MinSizeBytes int
+	// ContentTypes are the media types compressed, a type may end with a
+	// wildcard subtype, e.g. "text/*".
+	// Defaults to text, JSON, JavaScript, XML, and SVG
+	ContentTypes []string
+}
+
+// Encoder provides a writer compressing to w at level, a level of zero using
+// the default level of the encoder
+type Encoder func(w io.Writer, level int) (io.WriteCloser, error)
+
+var (
+	defaultCompressionEncodings    = []string{"gzip", "deflate"}
+	defaultCompressionContentTypes = []string{
+		"text/*",
+		"application/json",
+		"application/problem+json",
+		"application/javascript",
+		"application/xml",
+		"image/svg+xml",
+	}
+)
+
+const defaultCompressionMinSize = 1024
+
+// GzipEncoder is the Encoder of the gzip content coding
+func GzipEncoder(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// DeflateEncoder is the Encoder of the deflate content coding
+func DeflateEncoder(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	return flate.NewWriter(w, level)
+}
+
+// compression is a CompressionConfig prepared for serving requests
+type compression struct {
+	encodings    []string
+	encoders     map[string]Encoder
+	level        int
+	minSize      int
+	contentTypes []string
+}
+
+func (s *Server) newCompression(c CompressionConfig) *compression {
+	p := &compression{
+		encodings:    c.Encodings,
+		encoders:     map[string]Encoder{"gzip": GzipEncoder, "deflate": DeflateEncoder},
+		level:        c.Level,
+		minSize:      c.MinSizeBytes,
+		contentTypes: c.ContentTypes,
+	}
+	for name, e := range s.compressionEncoders {
+		p.encoders[name] = e
+	}
+	if len(p.encodings) == 0 {
+		p.encodings = defaultCompressionEncodings
+	}
+	if p.level < 0 || p.level > 9 {
+		level := 1
+		if p.level > 9 {
+			level = 9
+		}
+		s.logger.WarnCtx(context.Background(), "compression level out of range", "level", p.level, "using", level)
+		p.level = level
+	}
+	if p.minSize <= 0 {
+		p.minSize = defaultCompressionMinSize
+	}
+	if len(p.contentTypes) == 0 {
+		p.contentTypes = defaultCompressionContentTypes
+	}
+	return p
+}
+
+// negotiate provides the preferred encoding accepted by the Accept-Encoding
+// header, or an empty string when no offered encoding is acceptable.
+func (p *compression) negotiate(acceptEncoding string) string {
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQuality(part)
+		if name == "" {
+			continue
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		accepted[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range p.encodings {
+		if p.encoders[name] == nil {
+			continue
+		}
+		q, ok := accepted[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// parseQuality parses an element of an Accept-Encoding header
+func parseQuality(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			v, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil {
+				return name, 0
+			}
+			q = v
+		}
+	}
+	return name, q
+}
+
+func (p *compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range p.contentTypes {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompressionMiddleware compresses responses with the content coding
+// negotiated through the Accept-Encoding header. Responses are buffered until
+// they reach the minimum size, are flushed, or complete. It is a noop when
+// compression is not configured.
+func (s *Server) CompressionMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.config.Compression == nil {
+			return next
+		}
+		p := s.newCompression(*s.config.Compression)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			encoding := p.negotiate(r.Header.Get("Accept-Encoding"))
+			if r.Method == http.MethodHead {
+				encoding = ""
+			}
+			cw := &compressWriter{ResponseWriter: w, p: p, encoding: encoding}
+			next.ServeHTTP(cw, r)
+			_ = cw.Close()
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// compressWriter buffers the beginning of a response to decide whether to
+// compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	p        *compression
+	encoding string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	encoder io.WriteCloser
+}
+
+var _ http.Flusher = (*compressWriter)(nil)
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	// informational responses are sent as is
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	n, _ := w.buf.Write(b)
+	if w.buf.Len() >= w.p.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Flush compresses the response if possible regardless of its size and sends
+// any buffered data to the client.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.decide(true)
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the remainder of the response, deciding whether to compress it
+// if the response never reached the minimum size.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 && w.buf.Len() == 0 {
+			// nothing was written, leave the response to net/http
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// decide writes the header, compressing the response when compress is set and
+// the response is eligible, followed by the buffered body. The response is sent
+// uncompressed when the encoder cannot be created.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" && w.buf.Len() > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+	}
+
+	// responses without a body and range responses are never compressed
+	eligible := w.status != http.StatusNoContent &&
+		w.status != http.StatusNotModified &&
+		w.status != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" &&
+		w.p.compressible(h.Get("Content-Type"))
+	if eligible {
+		addVary(h, "Accept-Encoding")
+	}
+
+	if eligible && compress && w.encoding != "" {
+		if encoder, err := w.p.encoders[w.encoding](w.ResponseWriter, w.p.level); err == nil {
+			w.encoder = encoder
+			h.Set("Content-Encoding", w.encoding)
+			h.Del("Content-Length")
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// Unwrap provides the wrapped http.ResponseWriter.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
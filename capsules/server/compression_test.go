@@ -0,0 +1,331 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompressionNegotiate(t *testing.T) {
+	s := NewFactory().Create(WithServerCompressionEncoder("br", GzipEncoder))
+
+	tests := []struct {
+		name      string
+		encodings []string
+		accept    string
+		expected  string
+	}{
+		{name: "None", accept: "", expected: ""},
+		{name: "Gzip", accept: "gzip", expected: "gzip"},
+		{name: "Preference", accept: "deflate, gzip", expected: "gzip"},
+		{name: "Quality", accept: "deflate, gzip;q=0.5", expected: "deflate"},
+		{name: "Case", accept: "GZIP", expected: "gzip"},
+		{name: "Wildcard", accept: "gzip;q=0, *", expected: "deflate"},
+		{name: "WildcardRejected", accept: "*;q=0", expected: ""},
+		{name: "Identity", accept: "identity", expected: ""},
+		{name: "Unknown", accept: "br", expected: ""},
+		{name: "Custom", encodings: []string{"br", "gzip"}, accept: "gzip, br", expected: "br"},
+		{name: "InvalidQuality", accept: "gzip;q=foo, deflate;q=0.1", expected: "deflate"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := s.newCompression(CompressionConfig{Encodings: test.encodings})
+			if actual := p.negotiate(test.accept); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"foo":"bar"}`, 100)
+
+	tests := []struct {
+		name        string
+		config      *CompressionConfig
+		method      string
+		accept      string
+		contentType string
+		etag        string
+		status      int
+		body        string
+		encoding    string
+		vary        bool
+		expectedTag string
+	}{
+		{
+			name:        "Disabled",
+			accept:      "gzip",
+			contentType: "application/json",
+			body:        large,
+		},
+		{
+			name:        "Gzip",
+			config:      &CompressionConfig{},
+			accept:      "gzip",
+			contentType: "application/json",
+			etag:        `"abc"`,
+			body:        large,
+			encoding:    "gzip",
+			vary:        true,
+			expectedTag: `W/"abc"`,
+		},
+		{
+			name:        "Deflate",
+			config:      &CompressionConfig{Level: flate.BestSpeed},
+			accept:      "deflate",
+			contentType: "text/plain; charset=utf-8",
+			etag:        `W/"abc"`,
+			body:        large,
+			encoding:    "deflate",
+			vary:        true,
+			expectedTag: `W/"abc"`,
+		},
+		{
+			name:        "LevelAboveRange",
+			config:      &CompressionConfig{Level: 42},
+			accept:      "gzip",
+			contentType: "application/json",
+			body:        large,
+			encoding:    "gzip",
+			vary:        true,
+		},
+		{
+			name:        "LevelBelowRange",
+			config:      &CompressionConfig{Level: -5},
+			accept:      "deflate",
+			contentType: "application/json",
+			body:        large,
+			encoding:    "deflate",
+			vary:        true,
+		},
+		{
+			name:     "Sniffed",
+			config:   &CompressionConfig{},
+			accept:   "gzip",
+			body:     "<html>" + large,
+			encoding: "gzip",
+			vary:     true,
+		},
+		{
+			name:        "NotAccepted",
+			config:      &CompressionConfig{},
+			contentType: "application/json",
+			etag:        `"abc"`,
+			body:        large,
+			vary:        true,
+			expectedTag: `"abc"`,
+		},
+		{
+			name:        "Small",
+			config:      &CompressionConfig{},
+			accept:      "gzip",
+			contentType: "application/json",
+			body:        `{"foo":"bar"}`,
+			vary:        true,
+		},
+		{
+			name:        "MinSize",
+			config:      &CompressionConfig{MinSizeBytes: 5},
+			accept:      "gzip",
+			contentType: "application/json",
+			body:        `{"foo":"bar"}`,
+			encoding:    "gzip",
+			vary:        true,
+		},
+		{
+			name:        "ContentType",
+			config:      &CompressionConfig{},
+			accept:      "gzip",
+			contentType: "image/png",
+			body:        large,
+		},
+		{
+			name:        "ContentTypes",
+			config:      &CompressionConfig{ContentTypes: []string{"image/*"}},
+			accept:      "gzip",
+			contentType: "image/png",
+			body:        large,
+			encoding:    "gzip",
+			vary:        true,
+		},
+		{
+			name:        "Head",
+			config:      &CompressionConfig{},
+			method:      http.MethodHead,
+			accept:      "gzip",
+			contentType: "application/json",
+			body:        large,
+			vary:        true,
+		},
+		{
+			name:        "NoContent",
+			config:      &CompressionConfig{},
+			accept:      "gzip",
+			contentType: "application/json",
+			status:      http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := test.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			mux := http.NewServeMux()
+			mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				if test.contentType != "" {
+					w.Header().Set("Content-Type", test.contentType)
+				}
+				if test.etag != "" {
+					w.Header().Set("ETag", test.etag)
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(test.body)))
+				w.WriteHeader(status)
+				_, _ = io.WriteString(w, test.body)
+			})
+			s := NewFactory().Create(WithServerRouter(mux), WithServerConfig(Config{Compression: test.config}))
+
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/test", nil)
+			if test.accept != "" {
+				req.Header.Set("Accept-Encoding", test.accept)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != status {
+				t.Errorf("expected status %d, got %d", status, rr.Code)
+			}
+			if enc := rr.Header().Get("Content-Encoding"); enc != test.encoding {
+				t.Errorf("expected Content-Encoding %q, got %q", test.encoding, enc)
+			}
+			if vary := rr.Header().Get("Vary") == "Accept-Encoding"; vary != test.vary {
+				t.Errorf("expected Vary to be set %t, got %v", test.vary, rr.Header().Values("Vary"))
+			}
+			if test.expectedTag != "" && rr.Header().Get("ETag") != test.expectedTag {
+				t.Errorf("expected ETag %q, got %q", test.expectedTag, rr.Header().Get("ETag"))
+			}
+
+			body := decompress(t, test.encoding, rr.Body)
+			if test.encoding != "" && rr.Header().Get("Content-Length") != "" {
+				t.Errorf("expected no Content-Length, got %q", rr.Header().Get("Content-Length"))
+			}
+			if method != http.MethodHead && body != test.body {
+				t.Errorf("expected body of %d bytes, got %d", len(test.body), len(body))
+			}
+		})
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	s := NewFactory().Create()
+
+	tests := []struct {
+		level    int
+		expected int
+	}{
+		{level: 0, expected: 0},
+		{level: 1, expected: 1},
+		{level: 9, expected: 9},
+		{level: 10, expected: 9},
+		{level: -1, expected: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.level), func(t *testing.T) {
+			if p := s.newCompression(CompressionConfig{Level: test.level}); p.level != test.expected {
+				t.Errorf("expected level %d, got %d", test.expected, p.level)
+			}
+		})
+	}
+}
+
+func TestCompressionEncoderError(t *testing.T) {
+	body := strings.Repeat("a", 2048)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, body)
+	})
+	failing := func(io.Writer, int) (io.WriteCloser, error) { return nil, errors.New("unavailable") }
+	s := NewFactory().Create(
+		WithServerRouter(mux),
+		WithServerCompression(CompressionConfig{Encodings: []string{"br"}}),
+		WithServerCompressionEncoder("br", failing),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	if enc := rr.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected no Content-Encoding, got %q", enc)
+	}
+	if rr.Body.String() != body {
+		t.Errorf("expected the uncompressed body of %d bytes, got %d", len(body), rr.Body.Len())
+	}
+}
+
+func TestCompressionFlush(t *testing.T) {
+	flushed := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		close(flushed)
+		_, _ = io.WriteString(w, "data: 2\n\n")
+	})
+	s := NewFactory().Create(
+		WithServerRouter(mux),
+		WithServerCompression(CompressionConfig{}),
+		WithServerRouteTimeout("/stream", 0),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	<-flushed
+
+	if !rr.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if enc := rr.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("expected streamed response to be compressed, got %q", enc)
+	}
+	if body := decompress(t, "gzip", rr.Body); body != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func decompress(t *testing.T, encoding string, r io.Reader) string {
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case "deflate":
+		r = flate.NewReader(r)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/metrics"
+)
+
+// ConcurrencyConfig contains options for the adaptive concurrency limit. The
+// limit grows by one for every request completing within the latency
+// threshold while the limit is in use, and shrinks by the backoff ratio for
+// every request exceeding it or failing with a 503.
+type ConcurrencyConfig struct {
+	// InitialLimit is the limit before any request completed.
+	// Defaults to 20
+	InitialLimit int
+	// MinLimit is the lowest the limit shrinks to.
+	// Defaults to 1
+	MinLimit int
+	// MaxLimit is the highest the limit grows to.
+	// Defaults to 1000
+	MaxLimit int
+	// LatencyThresholdMs is the latency above which requests shrink the limit.
+	// Defaults to half the request timeout
+	LatencyThresholdMs int
+	// BackoffRatio is the ratio the limit is multiplied by when shrinking,
+	// between 0 and 1.
+	// Defaults to 0.9
+	BackoffRatio float64
+	// ExcludePaths are additional paths which are not limited. The liveness
+	// and readiness probes are never limited.
+	ExcludePaths []string
+}
+
+// concurrencyLimiter is an additive increase, multiplicative decrease limit of
+// concurrent requests.
+type concurrencyLimiter struct {
+	config    ConcurrencyConfig
+	threshold time.Duration
+	logger    Logger
+	metrics   *metrics.ConcurrencyMetrics
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+
+	// rejected counts the requests rejected since lastLog, rejections are
+	// logged at most once per second
+	rejected int
+	lastLog  time.Time
+}
+
+func newConcurrencyLimiter(c ConcurrencyConfig, requestTimeout time.Duration, l Logger, m *metrics.ConcurrencyMetrics) *concurrencyLimiter {
+	if c.InitialLimit < 1 {
+		c.InitialLimit = 20
+	}
+	if c.MinLimit < 1 {
+		c.MinLimit = 1
+	}
+	if c.MaxLimit < 1 {
+		c.MaxLimit = 1000
+	}
+	if c.MaxLimit < c.MinLimit {
+		c.MaxLimit = c.MinLimit
+	}
+	if c.BackoffRatio <= 0 || c.BackoffRatio >= 1 {
+		c.BackoffRatio = 0.9
+	}
+
+	threshold := time.Duration(c.LatencyThresholdMs) * time.Millisecond
+	if threshold <= 0 {
+		threshold = requestTimeout / 2
+	}
+
+	limiter := &concurrencyLimiter{
+		config:    c,
+		threshold: threshold,
+		logger:    l,
+		metrics:   m,
+		limit:     math.Max(float64(c.MinLimit), math.Min(float64(c.InitialLimit), float64(c.MaxLimit))),
+	}
+	if m != nil {
+		m.SetLimit(limiter.Limit())
+	}
+	return limiter
+}
+
+// Limit provides the current limit
+func (l *concurrencyLimiter) Limit() int {
+	return int(l.limit)
+}
+
+// acquire reserves a slot for a request, reporting false when the limit is
+// reached.
+func (l *concurrencyLimiter) acquire(r *http.Request) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight < l.Limit() {
+		l.inFlight++
+		return true
+	}
+
+	l.rejected++
+	if l.metrics != nil {
+		l.metrics.Rejected()
+	}
+	if now := time.Now(); now.Sub(l.lastLog) >= time.Second {
+		l.logger.WarnCtx(r.Context(), "concurrency limit reached, rejecting requests",
+			"limit", l.Limit(),
+			"rejected", l.rejected,
+		)
+		l.rejected = 0
+		l.lastLog = now
+	}
+	return false
+}
+
+// release frees the slot of a request, adjusting the limit to its outcome.
+func (l *concurrencyLimiter) release(r *http.Request, latency time.Duration, status int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inFlight := l.inFlight
+	l.inFlight--
+
+	before := l.Limit()
+	switch {
+	case latency > l.threshold || status == http.StatusServiceUnavailable:
+		l.limit = math.Max(float64(l.config.MinLimit), l.limit*l.config.BackoffRatio)
+	case inFlight*2 >= before:
+		// only grow while the limit is being used
+		l.limit = math.Min(float64(l.config.MaxLimit), l.limit+1)
+	}
+
+	if after := l.Limit(); after != before {
+		if l.metrics != nil {
+			l.metrics.SetLimit(after)
+		}
+		l.logger.DebugCtx(r.Context(), "concurrency limit changed", "limit", after)
+	}
+}
+
+// ConcurrencyLimitMiddleware rejects requests beyond an adaptive limit of
+// concurrent requests with a 503, shedding load before latencies grow. The
+// liveness and readiness probes are never rejected. It is a noop when no
+// concurrency limit is configured.
+func (s *Server) ConcurrencyLimitMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.config.Concurrency == nil {
+			return next
+		}
+
+		excluded := map[string]bool{"/live": true, "/ready": true}
+		for _, p := range s.config.Concurrency.ExcludePaths {
+			excluded[p] = true
+		}
+		limiter := s.concurrencyLimiter()
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if excluded[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !limiter.acquire(r) {
+				w.Header().Set("Retry-After", "1")
+				httperr.Write(w, r, httperr.Problem{Status: http.StatusServiceUnavailable, Detail: "server overloaded"})
+				return
+			}
+
+			start := time.Now()
+			rw := newResponseWriter(w)
+			defer func() {
+				limiter.release(r, time.Since(start), rw.Status())
+			}()
+			next.ServeHTTP(rw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// concurrencyLimiter provides the concurrencyLimiter of the Server, shared by
+// every handler built for it.
+func (s *Server) concurrencyLimiter() *concurrencyLimiter {
+	s.concurrency.once.Do(func() {
+		var m *metrics.ConcurrencyMetrics
+		if s.metrics != nil {
+			m = s.metrics.Concurrency()
+		}
+		timeout := 10 * time.Second
+		if s.config.RequestTimeoutSec != nil && *s.config.RequestTimeoutSec > 0 {
+			timeout = time.Duration(*s.config.RequestTimeoutSec) * time.Second
+		}
+		s.concurrency.limiter = newConcurrencyLimiter(*s.config.Concurrency, timeout, s.logger, m)
+	})
+	return s.concurrency.limiter
+}
+
+// concurrencyState holds the concurrencyLimiter of a Server
+type concurrencyState struct {
+	once    sync.Once
+	limiter *concurrencyLimiter
+}
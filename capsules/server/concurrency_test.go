@@ -0,0 +1,162 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.adenix.dev/adderall/capsules/metrics"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	tests := []struct {
+		name     string
+		config   ConcurrencyConfig
+		action   func(l *concurrencyLimiter)
+		expected int
+	}{
+		{
+			name:     "Defaults",
+			action:   func(l *concurrencyLimiter) {},
+			expected: 20,
+		},
+		{
+			name:     "InitialAboveMax",
+			config:   ConcurrencyConfig{InitialLimit: 50, MaxLimit: 10},
+			action:   func(l *concurrencyLimiter) {},
+			expected: 10,
+		},
+		{
+			name:   "Increase",
+			config: ConcurrencyConfig{InitialLimit: 2},
+			action: func(l *concurrencyLimiter) {
+				l.acquire(req)
+				l.release(req, time.Millisecond, http.StatusOK)
+			},
+			expected: 3,
+		},
+		{
+			name:   "Unused",
+			config: ConcurrencyConfig{InitialLimit: 4},
+			action: func(l *concurrencyLimiter) {
+				l.acquire(req)
+				l.release(req, time.Millisecond, http.StatusOK)
+			},
+			expected: 4,
+		},
+		{
+			name:   "IncreaseMax",
+			config: ConcurrencyConfig{InitialLimit: 2, MaxLimit: 2},
+			action: func(l *concurrencyLimiter) {
+				l.acquire(req)
+				l.release(req, time.Millisecond, http.StatusOK)
+			},
+			expected: 2,
+		},
+		{
+			name:   "Slow",
+			config: ConcurrencyConfig{InitialLimit: 10, LatencyThresholdMs: 100, BackoffRatio: 0.5},
+			action: func(l *concurrencyLimiter) {
+				l.acquire(req)
+				l.release(req, time.Second, http.StatusOK)
+			},
+			expected: 5,
+		},
+		{
+			name:   "Unavailable",
+			config: ConcurrencyConfig{InitialLimit: 10},
+			action: func(l *concurrencyLimiter) {
+				l.acquire(req)
+				l.release(req, time.Millisecond, http.StatusServiceUnavailable)
+			},
+			expected: 9,
+		},
+		{
+			name:   "DecreaseMin",
+			config: ConcurrencyConfig{InitialLimit: 2, MinLimit: 2},
+			action: func(l *concurrencyLimiter) {
+				l.acquire(req)
+				l.release(req, time.Hour, http.StatusOK)
+			},
+			expected: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newConcurrencyLimiter(test.config, 10*time.Second, NoopLogger{}, nil)
+			test.action(l)
+			if l.Limit() != test.expected {
+				t.Errorf("expected limit %d, got %d", test.expected, l.Limit())
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	started, unblock := make(chan struct{}), make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+	})
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {})
+
+	logger := &recordingLogger{}
+	r := metrics.NewRegistry(metrics.WithRuntimeCollectors(false))
+	s := NewFactory(WithLogger(logger), WithMetrics(r)).Create(
+		WithServerRouter(mux),
+		WithServerConcurrencyLimit(ConcurrencyConfig{InitialLimit: 1, MaxLimit: 1}),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("expected request to be rejected, got %d %v", rr.Code, rr.Header())
+	}
+
+	for _, path := range []string{"/live", "/ready"} {
+		rr = httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code == http.StatusServiceUnavailable {
+			t.Errorf("expected %s to be exempt, got %d", path, rr.Code)
+		}
+	}
+
+	close(unblock)
+	<-done
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected request to be allowed, got %d", rr.Code)
+	}
+
+	if warns := logger.entries("warn"); len(warns) != 1 {
+		t.Errorf("expected rejections to be logged once, got %v", warns)
+	}
+
+	expected := `
+# HELP http_server_concurrency_limit Current limit of concurrent HTTP requests.
+# TYPE http_server_concurrency_limit gauge
+http_server_concurrency_limit 1
+# HELP http_server_concurrency_rejected_total Total number of HTTP requests rejected for exceeding the concurrency limit.
+# TYPE http_server_concurrency_rejected_total counter
+http_server_concurrency_rejected_total 1
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(expected), "http_server_concurrency_limit", "http_server_concurrency_rejected_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig contains options for cross-origin resource sharing
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// An origin may contain a wildcard for subdomains, e.g.
+	// "https://*.example.com", or be "*" to allow every origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	// Defaults to GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in cross-origin requests,
+	// "*" allows every header.
+	// Defaults to Accept, Accept-Language, Content-Language, Content-Type and
+	// X-Request-ID
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers exposed to the client
+	ExposedHeaders []string
+	// AllowCredentials allows requests with credentials, such as cookies. It
+	// cannot be combined with the "*" origin, such a configuration disables
+	// CORS altogether.
+	AllowCredentials bool
+	// MaxAgeSec is how long the result of a preflight request can be cached.
+	// Zero leaves the duration to the client.
+	MaxAgeSec int
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	defaultCORSHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type", "X-Request-ID"}
+)
+
+// cors is a CORSConfig prepared for matching requests
+type cors struct {
+	config         CORSConfig
+	anyOrigin      bool
+	origins        map[string]bool
+	wildcards      [][2]string
+	methods        map[string]bool
+	anyHeader      bool
+	headers        map[string]bool
+	allowedMethods string
+}
+
+// newCORS prepares c, rejecting credentials for every origin
+func newCORS(c CORSConfig) (*cors, error) {
+	p := &cors{
+		config:  c,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+
+	for _, o := range c.AllowedOrigins {
+		o = strings.ToLower(o)
+		switch i := strings.Index(o, "*"); {
+		case o == "*":
+			if c.AllowCredentials {
+				return nil, errors.New(`cors: credentials cannot be allowed for the "*" origin`)
+			}
+			p.anyOrigin = true
+		case i >= 0:
+			p.wildcards = append(p.wildcards, [2]string{o[:i], o[i+1:]})
+		default:
+			p.origins[o] = true
+		}
+	}
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowed := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		p.methods[m] = true
+		allowed = append(allowed, m)
+	}
+	p.allowedMethods = strings.Join(allowed, ", ")
+
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	for _, h := range headers {
+		if h == "*" {
+			p.anyHeader = true
+		}
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	return p, nil
+}
+
+func (p *cors) originAllowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// headersAllowed reports whether every header of a comma separated list of
+// headers requested by a preflight request is allowed.
+func (p *cors) headersAllowed(requested string) bool {
+	if p.anyHeader {
+		return true
+	}
+	for _, h := range strings.Split(requested, ",") {
+		if h = strings.TrimSpace(h); h != "" && !p.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin sets the headers common to preflight and actual requests
+func (p *cors) setOrigin(h http.Header, origin string) {
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.config.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	addVary(h, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if p.originAllowed(origin) && p.methods[method] && p.headersAllowed(requested) {
+		p.setOrigin(h, origin)
+		h.Set("Access-Control-Allow-Methods", p.allowedMethods)
+		if requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+		if p.config.MaxAgeSec > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(p.config.MaxAgeSec))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *cors) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	addVary(h, "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !p.originAllowed(origin) {
+		return
+	}
+	p.setOrigin(h, origin)
+	if len(p.config.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(p.config.ExposedHeaders, ", "))
+	}
+}
+
+// CORSMiddleware applies the CORS configuration of the Server. Preflight
+// requests are answered without reaching the Router or the request timeout.
+// It is a noop when CORS is not configured, or configured to allow credentials
+// for every origin.
+func (s *Server) CORSMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.config.CORS == nil {
+			return next
+		}
+		p, err := newCORS(*s.config.CORS)
+		if err != nil {
+			s.logger.ErrorCtx(context.Background(), "cors disabled", "error", err)
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				p.preflight(w, r)
+				return
+			}
+			p.actual(w, r)
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// addVary adds values to the Vary header unless already present
+func addVary(h http.Header, values ...string) {
+	present := make(map[string]bool)
+	for _, v := range h.Values("Vary") {
+		for _, token := range strings.Split(v, ",") {
+			present[strings.ToLower(strings.TrimSpace(token))] = true
+		}
+	}
+	for _, v := range values {
+		if !present[strings.ToLower(v)] {
+			h.Add("Vary", v)
+			present[strings.ToLower(v)] = true
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.adenix.dev/adderall/internal/pointer"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	config := CORSConfig{
+		AllowedOrigins: []string{"https://example.com", "https://*.example.org"},
+		AllowedMethods: []string{"get", "put"},
+		ExposedHeaders: []string{"X-Total", "X-Request-ID"},
+		MaxAgeSec:      600,
+	}
+
+	tests := []struct {
+		name     string
+		config   *CORSConfig
+		method   string
+		headers  map[string]string
+		status   int
+		routed   bool
+		expected map[string]string
+		vary     []string
+	}{
+		{
+			name:     "Disabled",
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://example.com"},
+			status:   http.StatusOK,
+			routed:   true,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "Actual",
+			config:  &config,
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusOK,
+			routed:  true,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Expose-Headers":    "X-Total, X-Request-ID",
+				"Access-Control-Allow-Credentials": "",
+			},
+			vary: []string{"Origin"},
+		},
+		{
+			name:     "ActualWildcard",
+			config:   &config,
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://api.example.org"},
+			status:   http.StatusOK,
+			routed:   true,
+			expected: map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"},
+			vary:     []string{"Origin"},
+		},
+		{
+			name:     "ActualWildcardApex",
+			config:   &config,
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://example.org"},
+			status:   http.StatusOK,
+			routed:   true,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+			vary:     []string{"Origin"},
+		},
+		{
+			name:     "ActualDisallowed",
+			config:   &config,
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://evil.com"},
+			status:   http.StatusOK,
+			routed:   true,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+			vary:     []string{"Origin"},
+		},
+		{
+			name:     "AnyOrigin",
+			config:   &CORSConfig{AllowedOrigins: []string{"*"}},
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://evil.com"},
+			status:   http.StatusOK,
+			routed:   true,
+			expected: map[string]string{"Access-Control-Allow-Origin": "*"},
+			vary:     []string{"Origin"},
+		},
+		{
+			name:    "AnyOriginCredentials",
+			config:  &CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://evil.com"},
+			status:  http.StatusOK,
+			routed:  true,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:   "Preflight",
+			config: &config,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "content-type, x-request-id",
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "content-type, x-request-id",
+				"Access-Control-Max-Age":       "600",
+			},
+			vary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:   "PreflightMethod",
+			config: &config,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			status:   http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+			vary:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:   "PreflightHeaders",
+			config: &config,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Secret",
+			},
+			status:   http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+			vary:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:   "PreflightAnyHeader",
+			config: &CORSConfig{AllowedOrigins: []string{"https://example.com"}, AllowedHeaders: []string{"*"}},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "X-Secret",
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST",
+				"Access-Control-Allow-Headers": "X-Secret",
+				"Access-Control-Max-Age":       "",
+			},
+			vary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:     "Options",
+			config:   &config,
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://example.com"},
+			status:   http.StatusOK,
+			routed:   true,
+			expected: map[string]string{"Access-Control-Allow-Origin": "https://example.com"},
+			vary:     []string{"Origin"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routed := false
+			mux := http.NewServeMux()
+			mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				routed = true
+				w.Header().Add("Vary", "Accept-Encoding")
+			})
+			s := NewFactory().Create(WithServerRouter(mux), WithServerConfig(Config{
+				CORS: test.config,
+				// a preflight reaching the timeout would be buffered and
+				// fail to short-circuit
+				RequestTimeoutSec: pointer.IntP(1),
+			}))
+
+			req := httptest.NewRequest(test.method, "/test", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if routed != test.routed {
+				t.Errorf("expected routed to be %t", test.routed)
+			}
+			for k, v := range test.expected {
+				if actual := rr.Header().Get(k); actual != v {
+					t.Errorf("expected %s %q, got %q", k, v, actual)
+				}
+			}
+			vary := test.vary
+			if test.routed {
+				vary = append(vary, "Accept-Encoding")
+			}
+			if actual := rr.Header().Values("Vary"); !reflect.DeepEqual(actual, vary) && len(actual)+len(vary) > 0 {
+				t.Errorf("expected Vary %v, got %v", vary, actual)
+			}
+		})
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{"Vary": {"origin, Accept-Encoding"}}
+	addVary(h, "Origin", "Accept", "Accept")
+
+	expected := []string{"origin, Accept-Encoding", "Accept"}
+	if !reflect.DeepEqual(h.Values("Vary"), expected) {
+		t.Errorf("expected %v, got %v", expected, h.Values("Vary"))
+	}
+}
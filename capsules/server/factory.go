@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/metrics"
 )
 
 // Factory is the interface to create Servers
@@ -14,8 +15,13 @@ type Factory interface {
 type factory struct {
 	tracer     opentracing.Tracer
 	logger     Logger
+	metrics    *metrics.Registry
 	config     Config
 	routerFunc func() Handler
+
+	preTracing      []Middleware
+	postTracing     []Middleware
+	middlewareChain MiddlewareChain
 }
 
 var _ Factory = (*factory)(nil)
@@ -26,6 +32,7 @@ func NewFactory(opts ...FactoryOption) Factory {
 	f := &factory{
 		tracer:     opentracing.NoopTracer{},
 		logger:     NoopLogger{},
+		metrics:    metrics.Default(),
 		config:     defaultConfig(),
 		routerFunc: func() Handler { return &http.ServeMux{} },
 	}
@@ -44,10 +51,15 @@ func NewFactory(opts ...FactoryOption) Factory {
 func (f *factory) Create(opts ...Option) *Server {
 
 	s := &Server{
-		tracer: f.tracer,
-		logger: f.logger,
-		config: f.config,
-		Router: f.routerFunc(),
+		tracer:  f.tracer,
+		logger:  f.logger,
+		metrics: f.metrics,
+		config:  f.config,
+		Router:  f.routerFunc(),
+
+		preTracing:      append([]Middleware(nil), f.preTracing...),
+		postTracing:     append([]Middleware(nil), f.postTracing...),
+		middlewareChain: f.middlewareChain,
 	}
 
 	for _, option := range opts {
@@ -57,6 +69,9 @@ func (f *factory) Create(opts ...Option) *Server {
 	s.Router.HandleFunc("/live", s.getLivenessHandler())
 	s.Router.HandleFunc("/ready", s.getReadinessHandler())
 	s.Router.HandleFunc("/health", s.getHealthCheckHandler())
+	if s.metrics != nil {
+		s.Router.HandleFunc("/metrics", s.metrics.Handler().ServeHTTP)
+	}
 
 	s.addSwagger(s.Router)
 
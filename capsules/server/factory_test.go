@@ -9,12 +9,14 @@ import (
 
 func TestNewFactory(t *testing.T) {
 	c := Config{
-		Port:                 pointer.IntP(8080),
-		ReadTimeoutMs:        pointer.IntP(10000),
-		WriteTimeoutMs:       pointer.IntP(10000),
-		RequestTimeoutSec:    pointer.IntP(10),
-		ShutdownDelaySeconds: pointer.IntP(5),
-		SwaggerFile:          pointer.StringP("/swagger.json"),
+		Port:                   pointer.IntP(8080),
+		ReadTimeoutMs:          pointer.IntP(10000),
+		WriteTimeoutMs:         pointer.IntP(10000),
+		RequestTimeoutSec:      pointer.IntP(10),
+		ShutdownDelaySeconds:   pointer.IntP(5),
+		ShutdownTimeoutSeconds: pointer.IntP(10),
+		SwaggerFile:            pointer.StringP("/swagger.json"),
+		SwaggerUIPath:          pointer.StringP("/swagger/"),
 	}
 
 	tests := []struct {
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// HandlerE is a handler which returns its error instead of writing it. The
+// error is logged and written by the ErrorHandler of the Server, see
+// Server.Adapt.
+type HandlerE func(w http.ResponseWriter, r *http.Request) error
+
+// ErrorHandler writes the response for a request whose handler returned err
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// ProblemErrorHandler writes the problem details of err, see
+// httperr.WriteError. It is the default ErrorHandler.
+func ProblemErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	httperr.WriteError(w, r, err)
+}
+
+// HandleFuncE registers the HandlerE h for pattern on the Router, see
+// Server.HandleFunc and Server.Adapt.
+func (s *Server) HandleFuncE(pattern string, h HandlerE, authorizers ...auth.Authorizer) {
+	s.HandleFunc(pattern, s.Adapt(h), authorizers...)
+}
+
+// Adapt provides a http.HandlerFunc calling h. An error returned by h is
+// mapped to its status with httperr.StatusOf, logged once, and recorded on the
+// request span before the ErrorHandler writes the response. Server errors are
+// logged at error level and mark the span as failed, client errors are logged
+// at debug level. Errors of requests cancelled by the client are not written.
+func (s *Server) Adapt(h HandlerE) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		err := h(rw, r)
+		if err == nil {
+			return
+		}
+
+		status := httperr.StatusOf(err)
+		fields := []interface{}{
+			"error", err.Error(),
+			"status", status,
+			"method", r.Method,
+			"path", r.URL.EscapedPath(),
+			"route", s.routePattern(r),
+		}
+
+		if errors.Is(err, context.Canceled) && r.Context().Err() == context.Canceled {
+			s.logger.DebugCtx(r.Context(), "request cancelled", fields...)
+			return
+		}
+
+		if status >= http.StatusInternalServerError {
+			s.logger.ErrorCtx(r.Context(), "request failed", fields...)
+		} else {
+			s.logger.DebugCtx(r.Context(), "request failed", fields...)
+		}
+
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			if status >= http.StatusInternalServerError {
+				ext.Error.Set(span, true)
+			}
+			span.LogFields(
+				log.String("event", "error"),
+				log.Int("status", status),
+				log.String("message", err.Error()),
+			)
+		}
+
+		if rw.status != 0 {
+			// the handler started the response before failing
+			return
+		}
+
+		handler := s.errorHandler
+		if handler == nil {
+			handler = ProblemErrorHandler
+		}
+		handler(rw, r, err)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+func TestHandleFuncE(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		handler   HandlerE
+		status    int
+		body      string
+		level     string
+		spanError bool
+	}{
+		{
+			name: "NoError",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusCreated)
+				return nil
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "ClientError",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.NotFound("no such user")
+			},
+			status: http.StatusNotFound,
+			body:   `"detail":"no such user"`,
+			level:  "debug",
+		},
+		{
+			name: "ServerError",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("connection refused")
+			},
+			status:    http.StatusInternalServerError,
+			body:      `"title":"Internal Server Error"`,
+			level:     "error",
+			spanError: true,
+		},
+		{
+			name: "Started",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusAccepted)
+				return errors.New("stream failed")
+			},
+			status:    http.StatusAccepted,
+			level:     "error",
+			spanError: true,
+		},
+		{
+			name: "ErrorHandler",
+			opts: []Option{WithServerErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(httperr.StatusOf(err))
+				_, _ = w.Write([]byte(err.Error()))
+			})},
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.Conflict("exists")
+			},
+			status: http.StatusConflict,
+			body:   "409 Conflict: exists",
+			level:  "debug",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			tracer := mocktracer.New()
+			s := NewFactory(WithLogger(logger), WithTracer(tracer)).Create(test.opts...)
+			s.HandleFuncE("/users", test.handler)
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), test.body) {
+				t.Errorf("expected %q in %q", test.body, rr.Body.String())
+			}
+
+			var logged []logEntry
+			for _, level := range []string{"debug", "error"} {
+				for _, e := range logger.entries(level) {
+					if e.field("route") == "/users" {
+						logged = append(logged, e)
+					}
+				}
+			}
+			if test.level == "" && len(logged) != 0 {
+				t.Errorf("expected no error logged, got %v", logged)
+			}
+			if test.level != "" && (len(logged) != 1 || logged[0].level != test.level) {
+				t.Errorf("expected error logged once at %s, got %v", test.level, logged)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(spans))
+			}
+			if spanError := spans[0].Tag("error") == true; spanError != test.spanError {
+				t.Errorf("expected span error to be %t, got %v", test.spanError, spans[0].Tag("error"))
+			}
+		})
+	}
+}
+
+func TestAdaptCancelled(t *testing.T) {
+	logger := &recordingLogger{}
+	s := NewFactory(WithLogger(logger)).Create()
+	h := s.Adapt(func(w http.ResponseWriter, r *http.Request) error {
+		return r.Context().Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx))
+
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected no response, got %q", rr.Body.String())
+	}
+	if len(logger.entries("error")) != 0 || len(logger.entries("debug")) != 1 {
+		t.Errorf("expected cancellation logged at debug, got %v", logger.logs)
+	}
+}
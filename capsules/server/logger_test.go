@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -31,3 +32,60 @@ type testLogger struct {
 }
 
 func (l *testLogger) InfoCtx(_ context.Context, _ string, _ ...interface{}) {}
+
+// recordingLogger records every log entry
+type recordingLogger struct {
+	mu   sync.Mutex
+	logs []logEntry
+}
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields []interface{}
+}
+
+// field provides the value logged for key
+func (e logEntry) field(key string) interface{} {
+	for i := 0; i+1 < len(e.fields); i += 2 {
+		if e.fields[i] == key {
+			return e.fields[i+1]
+		}
+	}
+	return nil
+}
+
+func (l *recordingLogger) record(level, msg string, keysAndValues []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, logEntry{level: level, msg: msg, fields: keysAndValues})
+}
+
+// entries provides the entries logged at level
+func (l *recordingLogger) entries(level string) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []logEntry
+	for _, e := range l.logs {
+		if e.level == level {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (l *recordingLogger) DebugCtx(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.record("debug", msg, keysAndValues)
+}
+
+func (l *recordingLogger) InfoCtx(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.record("info", msg, keysAndValues)
+}
+
+func (l *recordingLogger) WarnCtx(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.record("warn", msg, keysAndValues)
+}
+
+func (l *recordingLogger) ErrorCtx(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.record("error", msg, keysAndValues)
+}
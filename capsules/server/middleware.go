@@ -0,0 +1,158 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+)
+
+// Middleware wraps a http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// MiddlewareStage identifies where in the handler chain a Middleware is
+// installed
+type MiddlewareStage int
+
+const (
+	// PreTracing middleware runs before the request span is started
+	PreTracing MiddlewareStage = iota
+	// PostTracing middleware runs within the request span, before the request
+	// timeout is applied
+	PostTracing
+)
+
+// MiddlewareChain provides the middleware wrapping the Router of s, outermost
+// first, given the middleware registered for each MiddlewareStage. Provide a
+// custom MiddlewareChain to reorder or disable the built-in middleware.
+type MiddlewareChain func(s *Server, preTracing, postTracing []Middleware) []Middleware
+
+// DefaultMiddlewareChain is the MiddlewareChain used when none is provided.
+// Panics in the Router and post-tracing middleware are recovered within the
+// request span.
+func DefaultMiddlewareChain(s *Server, preTracing, postTracing []Middleware) []Middleware {
+	chain := []Middleware{
+		s.MetricsMiddleware(),
+		s.ConcurrencyLimitMiddleware(),
+		s.RequestIDMiddleware(),
+	}
+	chain = append(chain, preTracing...)
+	chain = append(chain,
+		s.TracingMiddleware(),
+		s.AccessLogMiddleware(),
+		s.RecoveryMiddleware(),
+		s.CORSMiddleware(),
+		s.AuthenticationMiddleware(),
+		s.RateLimitMiddleware(),
+		s.CompressionMiddleware(),
+		s.ValidationMiddleware(),
+	)
+	chain = append(chain, postTracing...)
+	return append(chain,
+		s.TimeoutMiddleware(),
+		s.ClientCertificateMiddleware(),
+	)
+}
+
+// ProfilingMiddleware logs the response time of every request at debug level.
+//
+// Deprecated: AccessLogMiddleware, installed by DefaultMiddlewareChain, logs
+// the response time along with the response status and size.
+func (s *Server) ProfilingMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			s.logger.DebugCtx(r.Context(), "http path response time",
+				"path", r.URL.EscapedPath(),
+				"method", r.Method,
+				"time", time.Since(start),
+			)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// TracingMiddleware starts an OpenTracing span for every request. Spans are
+// named after the method and the route matched by the Router, e.g.
+// "HTTP GET /users/{id}", which is also tagged as http.route.
+func (s *Server) TracingMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return nethttp.Middleware(s.tracer, next,
+			nethttp.OperationNameFunc(s.operationName),
+			nethttp.MWSpanObserver(func(span opentracing.Span, r *http.Request) {
+				span.SetTag("http.route", s.routePattern(r))
+			}),
+		)
+	}
+}
+
+// operationName provides the name of the span of a request
+func (s *Server) operationName(r *http.Request) string {
+	if route := s.routePattern(r); route != "unmatched" {
+		return "HTTP " + r.Method + " " + route
+	}
+	return "HTTP " + r.Method
+}
+
+// MetricsMiddleware records RED metrics for every request. It is a noop when
+// the Server has no metrics registry.
+func (s *Server) MetricsMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.metrics == nil {
+			return next
+		}
+		m := s.metrics.Server()
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			done := m.Begin(r.Method, s.routePattern(r))
+			rw := newResponseWriter(w)
+			defer func() {
+				done(rw.Status())
+			}()
+			next.ServeHTTP(rw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func (s *Server) addMiddleware(stage MiddlewareStage, m ...Middleware) {
+	switch stage {
+	case PreTracing:
+		s.preTracing = append(s.preTracing, m...)
+	case PostTracing:
+		s.postTracing = append(s.postTracing, m...)
+	}
+}
+
+// getHandler provides the Router wrapped in the middleware of the
+// MiddlewareChain, built on first use and shared by every request
+func (s *Server) getHandler() http.Handler {
+	s.handlerOnce.Do(func() {
+		s.handler = s.buildHandler()
+	})
+	return s.handler
+}
+
+// buildHandler wraps the Router in the middleware of the MiddlewareChain
+func (s *Server) buildHandler() http.Handler {
+	chain := s.middlewareChain
+	if chain == nil {
+		chain = DefaultMiddlewareChain
+	}
+
+	middleware := chain(s, s.preTracing, s.postTracing)
+
+	var h http.Handler = s.Router
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if middleware[i] != nil {
+			h = middleware[i](h)
+		}
+	}
+
+	// the route pattern is resolved once for every middleware reporting it
+	inner := h
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner.ServeHTTP(w, s.withRoutePattern(r))
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				traced := opentracing.SpanFromContext(r.Context()) != nil
+				calls = append(calls, name+map[bool]string{true: "+span", false: ""}[traced])
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	tests := []struct {
+		name     string
+		fopts    []FactoryOption
+		opts     []Option
+		expected []string
+	}{
+		{
+			name: "Stages",
+			fopts: []FactoryOption{
+				WithMiddleware(PostTracing, record("factory-post")),
+				WithMiddleware(PreTracing, record("factory-pre")),
+			},
+			opts: []Option{
+				WithServerMiddleware(PreTracing, record("pre-1"), record("pre-2")),
+				WithServerMiddleware(PostTracing, record("post")),
+			},
+			expected: []string{"factory-pre", "pre-1", "pre-2", "factory-post+span", "post+span"},
+		},
+		{
+			name: "Chain",
+			fopts: []FactoryOption{
+				WithMiddleware(PreTracing, record("pre")),
+				WithMiddlewareChain(func(s *Server, pre, post []Middleware) []Middleware {
+					return append([]Middleware{record("custom"), nil}, pre...)
+				}),
+			},
+			expected: []string{"custom", "pre"},
+		},
+		{
+			name: "ServerChain",
+			opts: []Option{
+				WithServerMiddleware(PostTracing, record("post")),
+				WithServerMiddlewareChain(func(s *Server, pre, post []Middleware) []Middleware {
+					return append(post, s.TracingMiddleware())
+				}),
+			},
+			expected: []string{"post"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls = nil
+			fopts := append([]FactoryOption{WithTracer(mocktracer.New())}, test.fopts...)
+			s := NewFactory(fopts...).Create(test.opts...)
+
+			s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/live", nil))
+
+			if !reflect.DeepEqual(calls, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, calls)
+			}
+		})
+	}
+}
+
+func TestFactoryMiddlewareIsolation(t *testing.T) {
+	noop := func(next http.Handler) http.Handler { return next }
+	f := NewFactory(WithMiddleware(PreTracing, noop))
+
+	a := f.Create(WithServerMiddleware(PreTracing, noop))
+	b := f.Create()
+
+	if len(a.preTracing) != 2 || len(b.preTracing) != 1 {
+		t.Errorf("expected servers not to share middleware, got %d and %d", len(a.preTracing), len(b.preTracing))
+	}
+}
+
+func TestMiddlewareBuiltOnce(t *testing.T) {
+	built := 0
+	s := NewFactory().Create(WithServerMiddlewareChain(func(s *Server, pre, post []Middleware) []Middleware {
+		built++
+		return DefaultMiddlewareChain(s, pre, post)
+	}))
+
+	for i := 0; i < 3; i++ {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/live", nil))
+	}
+	if built != 1 {
+		t.Errorf("expected the middleware to be built once, got %d", built)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/openapi"
+)
+
+// OpenAPIConfig contains options for the OpenAPI document generated from the
+// routes registered with Server.HandleRoute
+type OpenAPIConfig struct {
+	// Path is the path the document is served at.
+	// Defaults to /openapi.json
+	Path string
+	// Title is the title of the API.
+	// Defaults to API
+	Title string
+	// Version is the version of the API.
+	// Defaults to 0.0.0
+	Version string
+	// Description is the description of the API
+	Description string
+	// Servers are the servers the API is served from
+	Servers []openapi.Server
+}
+
+const (
+	defaultOpenAPIPath    = "/openapi.json"
+	defaultOpenAPITitle   = "API"
+	defaultOpenAPIVersion = "0.0.0"
+)
+
+// OpenAPI provides the openapi.Spec collecting the routes registered with
+// HandleRoute, e.g. to dump the generated document with openapi.Command.
+func (s *Server) OpenAPI() *openapi.Spec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.openapi == nil {
+		var c OpenAPIConfig
+		if s.config.OpenAPI != nil {
+			c = *s.config.OpenAPI
+		}
+		info := openapi.Info{Title: c.Title, Version: c.Version, Description: c.Description}
+		if info.Title == "" {
+			info.Title = defaultOpenAPITitle
+		}
+		if info.Version == "" {
+			info.Version = defaultOpenAPIVersion
+		}
+		s.openapi = openapi.NewSpec(info, c.Servers...)
+	}
+	return s.openapi
+}
+
+// HandleRoute registers the HandlerE h for the method and pattern of route on
+// the Router, see Server.HandleFuncE, and adds route to the OpenAPI document
+// of the Server. The Router must match methods, e.g. a router.Router. The
+// document is only served when configured, see WithServerOpenAPI.
+func (s *Server) HandleRoute(route openapi.Route, h HandlerE, authorizers ...auth.Authorizer) {
+	s.OpenAPI().Add(route)
+	s.HandleFuncE(route.Method+" "+route.Pattern, h, authorizers...)
+}
+
+// openAPIPath provides the path the generated OpenAPI document is served at,
+// and whether it is served at all
+func (s *Server) openAPIPath() (string, bool) {
+	if s.config.OpenAPI == nil {
+		return "", false
+	}
+	if s.config.OpenAPI.Path == "" {
+		return defaultOpenAPIPath, true
+	}
+	return s.config.OpenAPI.Path, true
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/auth/authtest"
+	"go.adenix.dev/adderall/capsules/codec"
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/openapi"
+	"go.adenix.dev/adderall/capsules/router"
+)
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+func TestHandleRoute(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		path   string
+		status int
+	}{
+		{
+			name:   "Default",
+			opts:   []Option{WithServerOpenAPI(OpenAPIConfig{})},
+			path:   "/openapi.json",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Path",
+			opts:   []Option{WithServerOpenAPI(OpenAPIConfig{Path: "/api.json"})},
+			path:   "/api.json",
+			status: http.StatusOK,
+		},
+		{
+			name: "Authenticated",
+			opts: []Option{
+				WithServerOpenAPI(OpenAPIConfig{}),
+				WithServerAuthenticator(authtest.Authenticator(nil)),
+			},
+			path:   "/openapi.json",
+			status: http.StatusOK,
+		},
+		{
+			name:   "NotConfigured",
+			path:   "/openapi.json",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewFactory(
+				WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+				WithRouter(func() Handler { return router.New() }),
+			).Create(test.opts...)
+
+			s.HandleRoute(openapi.Route{
+				Method:    http.MethodGet,
+				Pattern:   "/users/{id}",
+				Responses: map[int]interface{}{http.StatusOK: testUser{}, http.StatusNotFound: httperr.Problem{}},
+			}, func(w http.ResponseWriter, r *http.Request) error {
+				return codec.Respond(w, r, http.StatusOK, testUser{ID: router.Param(r, "id")})
+			})
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.status {
+				t.Fatalf("expected %d, got %d", test.status, w.Code)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+
+			var doc openapi.Document
+			if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if doc.Info.Title != defaultOpenAPITitle || doc.Info.Version != defaultOpenAPIVersion {
+				t.Errorf("expected default info, got %v", doc.Info)
+			}
+			if _, ok := doc.Paths["/users/{id}"]["get"]; !ok {
+				t.Errorf("expected GET /users/{id}, got %v", doc.Paths)
+			}
+			if _, ok := doc.Components.Schemas["testUser"]; !ok {
+				t.Errorf("expected testUser schema, got %v", doc.Components.Schemas)
+			}
+
+			w = httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("expected the Swagger UI, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestOpenAPIInfo(t *testing.T) {
+	s := NewFactory(
+		WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+		WithConfig(Config{OpenAPI: &OpenAPIConfig{
+			Title:   "users",
+			Version: "1.2.0",
+			Servers: []openapi.Server{{URL: "https://api.example.com"}},
+		}}),
+	).Create()
+
+	doc := s.OpenAPI().Document()
+	if doc.Info.Title != "users" || doc.Info.Version != "1.2.0" {
+		t.Errorf("unexpected info %v", doc.Info)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://api.example.com" {
+		t.Errorf("unexpected servers %v", doc.Servers)
+	}
+}
@@ -1,9 +1,14 @@
 package server
 
 import (
-	"net/http"
+	"context"
+	"io/fs"
 
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/health"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/ratelimit"
 	"go.adenix.dev/adderall/internal/pointer"
 )
 
@@ -25,12 +30,72 @@ func WithServerTracer(t opentracing.Tracer) Option {
 	}
 }
 
+// WithServerMetrics provides an Option to provide a metrics registry to be used
+// by the Server. A nil registry disables metrics and the '/metrics' endpoint.
+func WithServerMetrics(m *metrics.Registry) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// WithServerMiddleware provides an Option to provide middleware installed at
+// the given stage of the handler chain. Middleware is applied in the order
+// provided, the first being outermost.
+func WithServerMiddleware(stage MiddlewareStage, m ...Middleware) Option {
+	return func(s *Server) {
+		s.addMiddleware(stage, m...)
+	}
+}
+
+// WithServerMiddlewareChain provides an Option to provide the MiddlewareChain
+// used to assemble the handler chain.
+// Defaults to DefaultMiddlewareChain
+func WithServerMiddlewareChain(c MiddlewareChain) Option {
+	return func(s *Server) {
+		s.middlewareChain = c
+	}
+}
+
+// WithServerPanicHandler provides an Option to provide the PanicHandler writing
+// the response of requests whose handler panicked.
+// Defaults to ProblemPanicHandler
+func WithServerPanicHandler(h PanicHandler) Option {
+	return func(s *Server) {
+		s.recovery.handler = h
+	}
+}
+
+// WithServerErrorHandler provides an Option to provide the ErrorHandler
+// writing the response of requests whose HandlerE returned an error.
+// Defaults to ProblemErrorHandler
+func WithServerErrorHandler(h ErrorHandler) Option {
+	return func(s *Server) {
+		s.errorHandler = h
+	}
+}
+
+// WithServerRepanicAbort provides an Option to provide whether panics with
+// http.ErrAbortHandler are re-panicked to abort the response, or recovered
+// like any other panic.
+// Defaults to true
+func WithServerRepanicAbort(repanic bool) Option {
+	return func(s *Server) {
+		s.recovery.disableRepanicAbort = !repanic
+	}
+}
+
 // WithServerConfig provides an Option to provide a server configuration.
 func WithServerConfig(c Config) Option {
 	return func(s *Server) {
+		if c.Host != nil {
+			s.config.Host = c.Host
+		}
 		if c.Port != nil {
 			s.config.Port = c.Port
 		}
+		if c.UnixSocket != nil {
+			s.config.UnixSocket = c.UnixSocket
+		}
 		if c.ReadTimeoutMs != nil {
 			s.config.ReadTimeoutMs = c.ReadTimeoutMs
 		}
@@ -40,12 +105,48 @@ func WithServerConfig(c Config) Option {
 		if c.ShutdownDelaySeconds != nil {
 			s.config.ShutdownDelaySeconds = c.ShutdownDelaySeconds
 		}
+		if c.ShutdownTimeoutSeconds != nil {
+			s.config.ShutdownTimeoutSeconds = c.ShutdownTimeoutSeconds
+		}
 		if c.WriteTimeoutMs != nil {
 			s.config.WriteTimeoutMs = c.WriteTimeoutMs
 		}
 		if c.SwaggerFile != nil {
 			s.config.SwaggerFile = c.SwaggerFile
 		}
+		if c.SwaggerUIPath != nil {
+			s.config.SwaggerUIPath = c.SwaggerUIPath
+		}
+		if c.TLS != nil {
+			s.config.TLS = c.TLS
+		}
+		if c.Protocol != nil {
+			s.config.Protocol = c.Protocol
+		}
+		if c.AccessLog != nil {
+			s.config.AccessLog = c.AccessLog
+		}
+		if c.Timeout != nil {
+			s.config.Timeout = c.Timeout
+		}
+		if c.RateLimit != nil {
+			s.config.RateLimit = c.RateLimit
+		}
+		if c.Concurrency != nil {
+			s.config.Concurrency = c.Concurrency
+		}
+		if c.CORS != nil {
+			s.config.CORS = c.CORS
+		}
+		if c.Compression != nil {
+			s.config.Compression = c.Compression
+		}
+		if c.OpenAPI != nil {
+			s.config.OpenAPI = c.OpenAPI
+		}
+		if c.Validation != nil {
+			s.config.Validation = c.Validation
+		}
 	}
 }
 
@@ -57,6 +158,210 @@ func WithServerPort(p int) Option {
 	}
 }
 
+// WithServerHost provides an Option to provide the host or interface address on
+// which the Server listens.
+// Defaults to all interfaces
+func WithServerHost(h string) Option {
+	return func(s *Server) {
+		s.config.Host = pointer.StringP(h)
+	}
+}
+
+// WithServerUnixSocket provides an Option to provide the path of a unix domain
+// socket on which the Server listens instead of the host and port.
+func WithServerUnixSocket(path string) Option {
+	return func(s *Server) {
+		s.config.UnixSocket = pointer.StringP(path)
+	}
+}
+
+// WithServerTLS provides an Option to provide the certificate and key files
+// used to serve TLS. Rotated files are reloaded without a restart.
+func WithServerTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		c := s.tlsConfig()
+		c.CertFile = certFile
+		c.KeyFile = keyFile
+	}
+}
+
+// WithServerClientCA provides an Option to provide the CA bundle used to
+// verify client certificates for mutual TLS.
+// Defaults to ClientAuthRequired when a CA bundle is provided
+func WithServerClientCA(caFile string, auth ClientAuth) Option {
+	return func(s *Server) {
+		c := s.tlsConfig()
+		c.ClientCAFile = caFile
+		c.ClientAuth = auth
+	}
+}
+
+// WithServerTLSConfig provides an Option to provide the complete TLS
+// configuration, including the minimum version and cipher suites.
+func WithServerTLSConfig(c TLSConfig) Option {
+	return func(s *Server) {
+		s.config.TLS = &c
+	}
+}
+
+// WithServerProtocols provides an Option to provide the protocols served by the
+// Server, e.g. ProtocolH2C to accept cleartext HTTP/2 from a service mesh.
+// Defaults to ProtocolHTTP1 and ProtocolHTTP2
+func WithServerProtocols(protocols ...Protocol) Option {
+	return func(s *Server) {
+		c := s.protocolConfig()
+		c.Protocols = protocols
+	}
+}
+
+// WithServerProtocolConfig provides an Option to provide the protocol
+// configuration, including HTTP/2 stream and idle limits.
+func WithServerProtocolConfig(c ProtocolConfig) Option {
+	return func(s *Server) {
+		s.config.Protocol = &c
+	}
+}
+
+// WithServerAccessLogConfig provides an Option to provide the access log
+// configuration, including excluded paths, sampling, and the slow request
+// threshold.
+// Defaults to logging every request except health probes
+func WithServerAccessLogConfig(c AccessLogConfig) Option {
+	return func(s *Server) {
+		s.config.AccessLog = &c
+	}
+}
+
+// WithServerRouteTimeout provides an Option to provide the request timeout in
+// seconds of a route pattern, overriding the request timeout of the Server. A
+// timeout of zero or less disables the timeout of the route.
+func WithServerRouteTimeout(pattern string, sec int) Option {
+	return func(s *Server) {
+		s.timeoutConfig().Routes[pattern] = sec
+	}
+}
+
+// WithServerTimeoutResponse provides an Option to provide the content type and
+// body of the 503 response written when a request times out.
+// Defaults to an application/problem+json body
+func WithServerTimeoutResponse(contentType, body string) Option {
+	return func(s *Server) {
+		c := s.timeoutConfig()
+		c.ContentType = contentType
+		c.Body = body
+	}
+}
+
+// WithServerTimeoutConfig provides an Option to provide the timeout
+// configuration, including route timeouts and the timeout response.
+func WithServerTimeoutConfig(c TimeoutConfig) Option {
+	return func(s *Server) {
+		s.config.Timeout = &c
+	}
+}
+
+// WithServerRateLimit provides an Option to provide the rate limit of every
+// route without a route rate limit.
+// Defaults to no rate limit
+func WithServerRateLimit(l RateLimit) Option {
+	return func(s *Server) {
+		s.rateLimitConfig().Default = &l
+	}
+}
+
+// WithServerRouteRateLimit provides an Option to provide the rate limit of a
+// route pattern.
+func WithServerRouteRateLimit(pattern string, l RateLimit) Option {
+	return func(s *Server) {
+		s.rateLimitConfig().Routes[pattern] = l
+	}
+}
+
+// WithServerRateLimitConfig provides an Option to provide the rate limiting
+// configuration.
+func WithServerRateLimitConfig(c RateLimitConfig) Option {
+	return func(s *Server) {
+		s.config.RateLimit = &c
+	}
+}
+
+// WithServerRateLimitKey provides an Option to provide the function extracting
+// the key requests are rate limited by, overriding RateLimitConfig.Key.
+// Defaults to the client ip address
+func WithServerRateLimitKey(f RateLimitKeyFunc) Option {
+	return func(s *Server) {
+		s.rateLimit.keyFunc = f
+	}
+}
+
+// WithServerRateLimitStore provides an Option to provide the Store keeping the
+// rate limit state, allowing limits to be shared across instances.
+// Defaults to a ratelimit.MemoryStore per Server
+func WithServerRateLimitStore(store ratelimit.Store) Option {
+	return func(s *Server) {
+		s.rateLimit.store = store
+	}
+}
+
+// WithServerConcurrencyLimit provides an Option to enable the adaptive limit of
+// concurrent requests.
+// Defaults to no concurrency limit
+func WithServerConcurrencyLimit(c ConcurrencyConfig) Option {
+	return func(s *Server) {
+		s.config.Concurrency = &c
+	}
+}
+
+// WithServerCORS provides an Option to enable cross-origin resource sharing
+// for the allowed origins, methods, and headers of c.
+// Defaults to no CORS
+func WithServerCORS(c CORSConfig) Option {
+	return func(s *Server) {
+		s.config.CORS = &c
+	}
+}
+
+// WithServerAuthenticator provides an Option to authenticate requests with a.
+// The opts are applied to the middleware, e.g. to exempt additional paths.
+// Defaults to no authentication
+func WithServerAuthenticator(a auth.Authenticator, opts ...auth.Option) Option {
+	return func(s *Server) {
+		s.auth.authenticator = a
+		s.auth.opts = opts
+	}
+}
+
+// WithServerAuthorizer provides an Option to provide the Authorizer of every
+// route registered with Server.HandleFunc, e.g. to look up permissions by
+// route and principal.
+// Defaults to allowing every request
+func WithServerAuthorizer(a auth.Authorizer) Option {
+	return func(s *Server) {
+		s.auth.authorizer = a
+	}
+}
+
+// WithServerCompression provides an Option to enable the compression of
+// responses.
+// Defaults to no compression
+func WithServerCompression(c CompressionConfig) Option {
+	return func(s *Server) {
+		s.config.Compression = &c
+	}
+}
+
+// WithServerCompressionEncoder provides an Option to provide the Encoder of a
+// content coding, such as "br", which can then be listed in
+// CompressionConfig.Encodings.
+func WithServerCompressionEncoder(name string, e Encoder) Option {
+	return func(s *Server) {
+		if s.compressionEncoders == nil {
+			s.compressionEncoders = make(map[string]Encoder)
+		}
+		s.compressionEncoders[name] = e
+	}
+}
+
 // WithServerReadTimeout provides an Option to provide the maximum duration in
 // milliseconds for reading the entire request, including the body.
 // Defaults to 10 seconds
@@ -76,7 +381,8 @@ func WithServerWriteTimeout(t int) Option {
 }
 
 // WithShutdownDelaySeconds provides an Option to provide the duration by which
-// server shutdown is delayed after receiving an os signal.
+// server shutdown is delayed after receiving an os signal. During the delay
+// the readiness probe fails so load balancers can drain traffic.
 // Defaults to 5 seconds
 func WithShutdownDelaySeconds(d int) Option {
 	return func(s *Server) {
@@ -84,27 +390,73 @@ func WithShutdownDelaySeconds(d int) Option {
 	}
 }
 
-// WithHealthCheck provides an Option to provide additional health checks that
-// are performed on health check probe.
-func WithHealthCheck(f func(http.HandlerFunc) http.HandlerFunc) Option {
+// WithShutdownTimeoutSeconds provides an Option to provide the maximum
+// duration given to in-flight requests and shutdown hooks once the shutdown
+// delay has passed.
+// Defaults to 10 seconds
+func WithShutdownTimeoutSeconds(t int) Option {
+	return func(s *Server) {
+		s.config.ShutdownTimeoutSeconds = pointer.IntP(t)
+	}
+}
+
+// WithOnShutdown provides an Option to provide a hook which is run after the
+// server stops accepting requests. Hooks are run in reverse registration
+// order.
+func WithOnShutdown(hook func(ctx context.Context) error) Option {
+	return func(s *Server) {
+		s.OnShutdown(hook)
+	}
+}
+
+// WithHealthCheck provides an Option to provide a check that is performed on
+// health check probe. The option may be passed multiple times to register
+// multiple checks.
+func WithHealthCheck(c health.Checker, opts ...health.CheckOption) Option {
+	return func(s *Server) {
+		if s.healthCheck == nil {
+			s.healthCheck = health.NewRegistry(s.healthOptions...)
+		}
+		s.healthCheck.Register(c, opts...)
+	}
+}
+
+// WithLivenessCheck provides an Option to provide a check that is performed on
+// liveness probe. The option may be passed multiple times to register multiple
+// checks.
+func WithLivenessCheck(c health.Checker, opts ...health.CheckOption) Option {
 	return func(s *Server) {
-		s.healthCheck = f
+		if s.livenessCheck == nil {
+			s.livenessCheck = health.NewRegistry(s.healthOptions...)
+		}
+		s.livenessCheck.Register(c, opts...)
 	}
 }
 
-// WithLivenessCheck provides an Option to provide additional liveness checks
-// that are performed on liveness probe.
-func WithLivenessCheck(f func(http.HandlerFunc) http.HandlerFunc) Option {
+// WithReadinessCheck provides an Option to provide a check that is performed
+// on readiness probe. The option may be passed multiple times to register
+// multiple checks.
+func WithReadinessCheck(c health.Checker, opts ...health.CheckOption) Option {
 	return func(s *Server) {
-		s.livenessCheck = f
+		if s.readinessCheck == nil {
+			s.readinessCheck = health.NewRegistry(s.healthOptions...)
+		}
+		s.readinessCheck.Register(c, opts...)
 	}
 }
 
-// WithReadinessCheck provides an Option to provide additional readiness checks
-// that are performed on readiness probe.
-func WithReadinessCheck(f func(http.HandlerFunc) http.HandlerFunc) Option {
+// WithHealthOptions provides an Option to configure the registries of the
+// health, liveness, and readiness checks, e.g. the default timeout and cache
+// TTL of their checks. It applies to the checks registered before and after it.
+// Defaults to the health.Registry defaults
+func WithHealthOptions(opts ...health.Option) Option {
 	return func(s *Server) {
-		s.readinessCheck = f
+		s.healthOptions = append(s.healthOptions, opts...)
+		for _, r := range []*health.Registry{s.healthCheck, s.livenessCheck, s.readinessCheck} {
+			if r != nil {
+				r.Configure(opts...)
+			}
+		}
 	}
 }
 
@@ -116,6 +468,54 @@ func WithSwaggerFile(f string) Option {
 	}
 }
 
+// WithServerOpenAPI provides an Option to serve the OpenAPI document generated
+// from the routes registered with Server.HandleRoute, and the Swagger UI for
+// it, instead of the SwaggerFile.
+// Defaults to serving the SwaggerFile
+func WithServerOpenAPI(c OpenAPIConfig) Option {
+	return func(s *Server) {
+		s.config.OpenAPI = &c
+	}
+}
+
+// WithServerValidation provides an Option to validate requests, and
+// optionally responses, against the OpenAPI document of the Server, see
+// ValidationMiddleware.
+// Defaults to no validation
+func WithServerValidation(c ValidationConfig) Option {
+	return func(s *Server) {
+		s.config.Validation = &c
+	}
+}
+
+// WithSwaggerFS provides an Option to serve the spec at path in fsys, e.g. an
+// embed.FS, instead of the SwaggerFile. The spec may be JSON or YAML and is
+// served at path below the root.
+func WithSwaggerFS(fsys fs.FS, path string) Option {
+	return func(s *Server) {
+		s.swagger.fsys = fsys
+		s.swagger.name = path
+	}
+}
+
+// WithSwaggerUIFS provides an Option to serve a customised Swagger UI from the
+// root of fsys instead of the bundled one.
+// Defaults to the bundled Swagger UI
+func WithSwaggerUIFS(fsys fs.FS) Option {
+	return func(s *Server) {
+		s.swagger.ui = fsys
+	}
+}
+
+// WithSwaggerUIPath provides an Option to provide the path the Swagger UI is
+// mounted at, an empty path disabling the UI.
+// Defaults to '/swagger/'
+func WithSwaggerUIPath(p string) Option {
+	return func(s *Server) {
+		s.config.SwaggerUIPath = pointer.StringP(p)
+	}
+}
+
 // WithServerRouter provides an Option to provide hooks to use the http request
 // to mutate the request context.
 func WithServerRouter(r Handler) Option {
@@ -135,11 +535,29 @@ func WithLogger(l Logger) FactoryOption { return factoryOptionLogger{logger: l}
 // Defaults to Noop
 func WithTracer(t opentracing.Tracer) FactoryOption { return factoryOptionTracer{tracer: t} }
 
+// WithMetrics provides an Option to provide a metrics registry.
+// Defaults to metrics.Default()
+func WithMetrics(m *metrics.Registry) FactoryOption { return factoryOptionMetrics{metrics: m} }
+
+// WithMiddleware provides an Option to provide middleware installed at the
+// given stage of the handler chain of every Server.
+func WithMiddleware(stage MiddlewareStage, m ...Middleware) FactoryOption {
+	return factoryOptionMiddleware{stage: stage, middleware: m}
+}
+
+// WithMiddlewareChain provides an Option to provide the MiddlewareChain used to
+// assemble the handler chain of every Server.
+// Defaults to DefaultMiddlewareChain
+func WithMiddlewareChain(c MiddlewareChain) FactoryOption {
+	return factoryOptionMiddlewareChain{chain: c}
+}
+
 // WithConfig provides an Option to provide a server configuration.
 func WithConfig(c Config) FactoryOption { return factoryOptionConfig{c} }
 
 // WithRouter provides option to provide a function which returns which router
-// will be used. Defaults to http.ServeMux
+// will be used, e.g. router.New for method routes and path parameters.
+// Defaults to http.ServeMux
 func WithRouter(rf func() Handler) FactoryOption { return factoryOptionRouter{rf} }
 
 type factoryOptionTracer struct{ tracer opentracing.Tracer }
@@ -158,12 +576,48 @@ func (l factoryOptionLogger) apply(f *factory) {
 	}
 }
 
+type factoryOptionMetrics struct{ metrics *metrics.Registry }
+
+func (m factoryOptionMetrics) apply(f *factory) {
+	if m.metrics != nil {
+		f.metrics = m.metrics
+	}
+}
+
+type factoryOptionMiddleware struct {
+	stage      MiddlewareStage
+	middleware []Middleware
+}
+
+func (m factoryOptionMiddleware) apply(f *factory) {
+	switch m.stage {
+	case PreTracing:
+		f.preTracing = append(f.preTracing, m.middleware...)
+	case PostTracing:
+		f.postTracing = append(f.postTracing, m.middleware...)
+	}
+}
+
+type factoryOptionMiddlewareChain struct{ chain MiddlewareChain }
+
+func (c factoryOptionMiddlewareChain) apply(f *factory) {
+	if c.chain != nil {
+		f.middlewareChain = c.chain
+	}
+}
+
 type factoryOptionConfig struct{ config Config }
 
 func (c factoryOptionConfig) apply(f *factory) {
+	if c.config.Host != nil {
+		f.config.Host = c.config.Host
+	}
 	if c.config.Port != nil {
 		f.config.Port = c.config.Port
 	}
+	if c.config.UnixSocket != nil {
+		f.config.UnixSocket = c.config.UnixSocket
+	}
 	if c.config.ReadTimeoutMs != nil {
 		f.config.ReadTimeoutMs = c.config.ReadTimeoutMs
 	}
@@ -173,12 +627,48 @@ func (c factoryOptionConfig) apply(f *factory) {
 	if c.config.ShutdownDelaySeconds != nil {
 		f.config.ShutdownDelaySeconds = c.config.ShutdownDelaySeconds
 	}
+	if c.config.ShutdownTimeoutSeconds != nil {
+		f.config.ShutdownTimeoutSeconds = c.config.ShutdownTimeoutSeconds
+	}
 	if c.config.WriteTimeoutMs != nil {
 		f.config.WriteTimeoutMs = c.config.WriteTimeoutMs
 	}
 	if c.config.SwaggerFile != nil {
 		f.config.SwaggerFile = c.config.SwaggerFile
 	}
+	if c.config.SwaggerUIPath != nil {
+		f.config.SwaggerUIPath = c.config.SwaggerUIPath
+	}
+	if c.config.TLS != nil {
+		f.config.TLS = c.config.TLS
+	}
+	if c.config.Protocol != nil {
+		f.config.Protocol = c.config.Protocol
+	}
+	if c.config.AccessLog != nil {
+		f.config.AccessLog = c.config.AccessLog
+	}
+	if c.config.Timeout != nil {
+		f.config.Timeout = c.config.Timeout
+	}
+	if c.config.RateLimit != nil {
+		f.config.RateLimit = c.config.RateLimit
+	}
+	if c.config.Concurrency != nil {
+		f.config.Concurrency = c.config.Concurrency
+	}
+	if c.config.CORS != nil {
+		f.config.CORS = c.config.CORS
+	}
+	if c.config.Compression != nil {
+		f.config.Compression = c.config.Compression
+	}
+	if c.config.OpenAPI != nil {
+		f.config.OpenAPI = c.config.OpenAPI
+	}
+	if c.config.Validation != nil {
+		f.config.Validation = c.config.Validation
+	}
 }
 
 type factoryOptionRouter struct{ rf func() Handler }
@@ -1,14 +1,19 @@
 package server
 
 import (
-	"fmt"
-	"io"
+	"context"
+	"io/fs"
 	"net/http"
-	"net/http/httptest"
 	"reflect"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/health"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/ratelimit"
 	"go.adenix.dev/adderall/internal/pointer"
 )
 
@@ -16,14 +21,34 @@ type optionAssertion func(t *testing.T, s *Server)
 
 type factoryOptionAssertion func(t *testing.T, f *factory)
 
+var testRegistry = metrics.NewRegistry(metrics.WithRuntimeCollectors(false))
+
+var testSwaggerFS = fstest.MapFS{"api/swagger.yaml": {Data: []byte("swagger: \"2.0\"")}}
+
+var testMiddleware Middleware = func(next http.Handler) http.Handler { return next }
+
 func TestOption(t *testing.T) {
 	c := Config{
-		Port:                 pointer.IntP(5000),
-		ReadTimeoutMs:        pointer.IntP(1000),
-		RequestTimeoutSec:    pointer.IntP(50),
-		ShutdownDelaySeconds: pointer.IntP(10),
-		WriteTimeoutMs:       pointer.IntP(1000),
-		SwaggerFile:          pointer.StringP("foo"),
+		Host:                   pointer.StringP("127.0.0.1"),
+		Port:                   pointer.IntP(5000),
+		UnixSocket:             pointer.StringP("/tmp/foo.sock"),
+		ReadTimeoutMs:          pointer.IntP(1000),
+		RequestTimeoutSec:      pointer.IntP(50),
+		ShutdownDelaySeconds:   pointer.IntP(10),
+		ShutdownTimeoutSeconds: pointer.IntP(30),
+		WriteTimeoutMs:         pointer.IntP(1000),
+		SwaggerFile:            pointer.StringP("foo"),
+		SwaggerUIPath:          pointer.StringP("/docs/"),
+		TLS:                    &TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"},
+		Protocol:               &ProtocolConfig{Protocols: []Protocol{ProtocolH2C}},
+		AccessLog:              &AccessLogConfig{SampleEvery: 10},
+		Timeout:                &TimeoutConfig{Routes: map[string]int{"/export": 60}},
+		RateLimit:              &RateLimitConfig{Default: &RateLimit{Requests: 10, PeriodSec: 1}},
+		Concurrency:            &ConcurrencyConfig{InitialLimit: 10},
+		CORS:                   &CORSConfig{AllowedOrigins: []string{"*"}},
+		Compression:            &CompressionConfig{Level: 5},
+		OpenAPI:                &OpenAPIConfig{Title: "users"},
+		Validation:             &ValidationConfig{Responses: true},
 	}
 
 	tests := []struct {
@@ -42,11 +67,176 @@ func TestOption(t *testing.T) {
 			op:     WithServerTracer(opentracing.NoopTracer{}),
 			assert: assertOptionWithServerTracer(opentracing.NoopTracer{}),
 		},
+		{
+			name:   "WithServerMetrics",
+			op:     WithServerMetrics(testRegistry),
+			assert: assertOptionWithServerMetrics(testRegistry),
+		},
+		{
+			name:   "WithServerMetrics-Nil",
+			server: &Server{metrics: testRegistry},
+			op:     WithServerMetrics(nil),
+			assert: assertOptionWithServerMetrics(nil),
+		},
+		{
+			name:   "WithServerMiddleware",
+			op:     WithServerMiddleware(PostTracing, testMiddleware, testMiddleware),
+			assert: assertOptionWithServerMiddleware(0, 2),
+		},
+		{
+			name:   "WithServerMiddleware-PreTracing",
+			op:     WithServerMiddleware(PreTracing, testMiddleware),
+			assert: assertOptionWithServerMiddleware(1, 0),
+		},
+		{
+			name:   "WithServerMiddlewareChain",
+			op:     WithServerMiddlewareChain(DefaultMiddlewareChain),
+			assert: assertOptionWithServerMiddlewareChain(true),
+		},
+		{
+			name:   "WithServerPanicHandler",
+			op:     WithServerPanicHandler(JSONPanicHandler),
+			assert: assertOptionWithServerPanicHandler(true),
+		},
+		{
+			name:   "WithServerErrorHandler",
+			op:     WithServerErrorHandler(ProblemErrorHandler),
+			assert: assertOptionWithServerErrorHandler(true),
+		},
+		{
+			name:   "WithServerRepanicAbort",
+			op:     WithServerRepanicAbort(false),
+			assert: assertOptionWithServerRepanicAbort(false),
+		},
+		{
+			name:   "WithServerRateLimit",
+			op:     WithServerRateLimit(RateLimit{Requests: 1, PeriodSec: 1}),
+			assert: assertOptionWithServerConfig(Config{RateLimit: &RateLimitConfig{Default: &RateLimit{Requests: 1, PeriodSec: 1}, Routes: map[string]RateLimit{}}}),
+		},
+		{
+			name:   "WithServerRouteRateLimit",
+			server: &Server{config: Config{RateLimit: &RateLimitConfig{Routes: map[string]RateLimit{"/a": {Requests: 1}}}}},
+			op:     WithServerRouteRateLimit("/b", RateLimit{Requests: 2}),
+			assert: assertOptionWithServerConfig(Config{RateLimit: &RateLimitConfig{Routes: map[string]RateLimit{"/a": {Requests: 1}, "/b": {Requests: 2}}}}),
+		},
+		{
+			name:   "WithServerRateLimitConfig",
+			op:     WithServerRateLimitConfig(RateLimitConfig{Key: RateLimitKeyHeader, KeyHeader: "X-Api-Key"}),
+			assert: assertOptionWithServerConfig(Config{RateLimit: &RateLimitConfig{Key: RateLimitKeyHeader, KeyHeader: "X-Api-Key"}}),
+		},
+		{
+			name:   "WithServerRateLimitKey",
+			op:     WithServerRateLimitKey(HeaderKey("X-Api-Key", RemoteIPKey(false))),
+			assert: assertOptionWithServerRateLimitKey(true),
+		},
+		{
+			name:   "WithServerConcurrencyLimit",
+			op:     WithServerConcurrencyLimit(ConcurrencyConfig{MaxLimit: 100}),
+			assert: assertOptionWithServerConfig(Config{Concurrency: &ConcurrencyConfig{MaxLimit: 100}}),
+		},
+		{
+			name:   "WithServerCORS",
+			op:     WithServerCORS(CORSConfig{AllowedOrigins: []string{"https://example.com"}}),
+			assert: assertOptionWithServerConfig(Config{CORS: &CORSConfig{AllowedOrigins: []string{"https://example.com"}}}),
+		},
+		{
+			name:   "WithServerCompression",
+			op:     WithServerCompression(CompressionConfig{MinSizeBytes: 10}),
+			assert: assertOptionWithServerConfig(Config{Compression: &CompressionConfig{MinSizeBytes: 10}}),
+		},
+		{
+			name:   "WithServerCompressionEncoder",
+			op:     WithServerCompressionEncoder("br", GzipEncoder),
+			assert: assertOptionWithServerCompressionEncoder("br"),
+		},
+		{
+			name:   "WithServerOpenAPI",
+			op:     WithServerOpenAPI(OpenAPIConfig{Path: "/api.json"}),
+			assert: assertOptionWithServerConfig(Config{OpenAPI: &OpenAPIConfig{Path: "/api.json"}}),
+		},
+		{
+			name:   "WithServerValidation",
+			op:     WithServerValidation(ValidationConfig{Responses: true}),
+			assert: assertOptionWithServerConfig(Config{Validation: &ValidationConfig{Responses: true}}),
+		},
+		{
+			name:   "WithServerRateLimitStore",
+			op:     WithServerRateLimitStore(errStore{}),
+			assert: assertOptionWithServerRateLimitStore(errStore{}),
+		},
+		{
+			name:   "WithServerAuthenticator",
+			op:     WithServerAuthenticator(auth.Chain(), auth.WithOptional(true)),
+			assert: assertOptionWithServerAuthenticator(1),
+		},
+		{
+			name:   "WithServerAuthorizer",
+			op:     WithServerAuthorizer(auth.RequireAuthenticated()),
+			assert: assertOptionWithServerAuthorizer(true),
+		},
 		{
 			name:   "WithServerPort",
 			op:     WithServerPort(4000),
 			assert: assertOptionWithServerPort(4000),
 		},
+		{
+			name:   "WithServerHost",
+			op:     WithServerHost("localhost"),
+			assert: assertOptionWithServerConfig(Config{Host: pointer.StringP("localhost")}),
+		},
+		{
+			name:   "WithServerUnixSocket",
+			op:     WithServerUnixSocket("/tmp/bar.sock"),
+			assert: assertOptionWithServerConfig(Config{UnixSocket: pointer.StringP("/tmp/bar.sock")}),
+		},
+		{
+			name:   "WithServerTLS",
+			op:     WithServerTLS("cert.pem", "key.pem"),
+			assert: assertOptionWithServerConfig(Config{TLS: &TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}}),
+		},
+		{
+			name:   "WithServerClientCA",
+			server: &Server{config: Config{TLS: &TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}}},
+			op:     WithServerClientCA("ca.pem", ClientAuthOptional),
+			assert: assertOptionWithServerConfig(Config{TLS: &TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: "ca.pem", ClientAuth: ClientAuthOptional}}),
+		},
+		{
+			name:   "WithServerTLSConfig",
+			op:     WithServerTLSConfig(TLSConfig{MinVersion: "1.3"}),
+			assert: assertOptionWithServerConfig(Config{TLS: &TLSConfig{MinVersion: "1.3"}}),
+		},
+		{
+			name:   "WithServerProtocols",
+			server: &Server{config: Config{Protocol: &ProtocolConfig{MaxConcurrentStreams: 10}}},
+			op:     WithServerProtocols(ProtocolHTTP1, ProtocolH2C),
+			assert: assertOptionWithServerConfig(Config{Protocol: &ProtocolConfig{Protocols: []Protocol{ProtocolHTTP1, ProtocolH2C}, MaxConcurrentStreams: 10}}),
+		},
+		{
+			name:   "WithServerProtocolConfig",
+			op:     WithServerProtocolConfig(ProtocolConfig{IdleTimeoutMs: 100}),
+			assert: assertOptionWithServerConfig(Config{Protocol: &ProtocolConfig{IdleTimeoutMs: 100}}),
+		},
+		{
+			name:   "WithServerRouteTimeout",
+			server: &Server{config: Config{Timeout: &TimeoutConfig{Body: "foo", Routes: map[string]int{"/a": 1}}}},
+			op:     WithServerRouteTimeout("/b", 2),
+			assert: assertOptionWithServerConfig(Config{Timeout: &TimeoutConfig{Body: "foo", Routes: map[string]int{"/a": 1, "/b": 2}}}),
+		},
+		{
+			name:   "WithServerTimeoutResponse",
+			op:     WithServerTimeoutResponse("text/plain", "late"),
+			assert: assertOptionWithServerConfig(Config{Timeout: &TimeoutConfig{ContentType: "text/plain", Body: "late", Routes: map[string]int{}}}),
+		},
+		{
+			name:   "WithServerTimeoutConfig",
+			op:     WithServerTimeoutConfig(TimeoutConfig{Body: "late"}),
+			assert: assertOptionWithServerConfig(Config{Timeout: &TimeoutConfig{Body: "late"}}),
+		},
+		{
+			name:   "WithServerAccessLogConfig",
+			op:     WithServerAccessLogConfig(AccessLogConfig{SlowThresholdMs: 500}),
+			assert: assertOptionWithServerConfig(Config{AccessLog: &AccessLogConfig{SlowThresholdMs: 500}}),
+		},
 		{
 			name:   "WithServerReadTimeout",
 			op:     WithServerReadTimeout(2000),
@@ -62,26 +252,56 @@ func TestOption(t *testing.T) {
 			op:     WithShutdownDelaySeconds(20),
 			assert: assertOptionWithShutdownDelaySeconds(20),
 		},
+		{
+			name:   "WithShutdownTimeoutSeconds",
+			op:     WithShutdownTimeoutSeconds(40),
+			assert: assertOptionWithShutdownTimeoutSeconds(40),
+		},
+		{
+			name:   "WithOnShutdown",
+			op:     WithOnShutdown(func(context.Context) error { return nil }),
+			assert: assertOptionWithOnShutdown(1),
+		},
 		{
 			name:   "WithHealthCheck",
-			op:     WithHealthCheck(newHandlerFunc("HEALTH")),
+			op:     WithHealthCheck(newChecker("HEALTH")),
 			assert: assertOptionWithHealthCheck("HEALTH"),
 		},
 		{
 			name:   "WithLivenessCheck",
-			op:     WithLivenessCheck(newHandlerFunc("LIVE")),
+			op:     WithLivenessCheck(newChecker("LIVE")),
 			assert: assertOptionWithLivenessCheck("LIVE"),
 		},
 		{
 			name:   "WithReadinessCheck",
-			op:     WithReadinessCheck(newHandlerFunc("READY")),
+			op:     WithReadinessCheck(newChecker("READY")),
 			assert: assertOptionWithReadinessCheck("READY"),
 		},
+		{
+			name:   "WithHealthOptions",
+			op:     WithHealthOptions(health.WithTimeout(time.Minute), health.WithCacheTTL(time.Minute)),
+			assert: assertOptionWithHealthOptions(2),
+		},
 		{
 			name:   "WithSwaggerFile",
 			op:     WithSwaggerFile("bar"),
 			assert: assertOptionWithSwaggerFile("bar"),
 		},
+		{
+			name:   "WithSwaggerFS",
+			op:     WithSwaggerFS(testSwaggerFS, "api/swagger.yaml"),
+			assert: assertOptionWithSwaggerFS(testSwaggerFS, "api/swagger.yaml"),
+		},
+		{
+			name:   "WithSwaggerUIFS",
+			op:     WithSwaggerUIFS(testSwaggerFS),
+			assert: assertOptionWithSwaggerUIFS(testSwaggerFS),
+		},
+		{
+			name:   "WithSwaggerUIPath",
+			op:     WithSwaggerUIPath("/docs"),
+			assert: assertOptionWithServerConfig(Config{SwaggerUIPath: pointer.StringP("/docs")}),
+		},
 		{
 			name:   "WithServerRouter",
 			op:     WithServerRouter(&testHandler{}),
@@ -92,6 +312,16 @@ func TestOption(t *testing.T) {
 			op:     WithServerConfig(Config{}),
 			assert: assertOptionWithServerConfig(Config{}),
 		},
+		{
+			name:   "WithServerConfig-Host",
+			op:     WithServerConfig(Config{Host: c.Host}),
+			assert: assertOptionWithServerConfig(Config{Host: c.Host}),
+		},
+		{
+			name:   "WithServerConfig-UnixSocket",
+			op:     WithServerConfig(Config{UnixSocket: c.UnixSocket}),
+			assert: assertOptionWithServerConfig(Config{UnixSocket: c.UnixSocket}),
+		},
 		{
 			name:   "WithServerConfig-Port",
 			op:     WithServerConfig(Config{Port: c.Port}),
@@ -112,6 +342,11 @@ func TestOption(t *testing.T) {
 			op:     WithServerConfig(Config{ShutdownDelaySeconds: c.ShutdownDelaySeconds}),
 			assert: assertOptionWithServerConfig(Config{ShutdownDelaySeconds: c.ShutdownDelaySeconds}),
 		},
+		{
+			name:   "WithServerConfig-ShutdownTimeoutSeconds",
+			op:     WithServerConfig(Config{ShutdownTimeoutSeconds: c.ShutdownTimeoutSeconds}),
+			assert: assertOptionWithServerConfig(Config{ShutdownTimeoutSeconds: c.ShutdownTimeoutSeconds}),
+		},
 		{
 			name:   "WithServerConfig-WriteTimeoutMs",
 			op:     WithServerConfig(Config{WriteTimeoutMs: c.WriteTimeoutMs}),
@@ -122,6 +357,16 @@ func TestOption(t *testing.T) {
 			op:     WithServerConfig(Config{SwaggerFile: c.SwaggerFile}),
 			assert: assertOptionWithServerConfig(Config{SwaggerFile: c.SwaggerFile}),
 		},
+		{
+			name:   "WithServerConfig-TLS",
+			op:     WithServerConfig(Config{TLS: c.TLS}),
+			assert: assertOptionWithServerConfig(Config{TLS: c.TLS}),
+		},
+		{
+			name:   "WithServerConfig-Protocol",
+			op:     WithServerConfig(Config{Protocol: c.Protocol}),
+			assert: assertOptionWithServerConfig(Config{Protocol: c.Protocol}),
+		},
 		{
 			name:   "WithServerConfig-All",
 			op:     WithServerConfig(c),
@@ -142,12 +387,26 @@ func TestOption(t *testing.T) {
 
 func TestFactoryOption(t *testing.T) {
 	c := Config{
-		Port:                 pointer.IntP(5000),
-		ReadTimeoutMs:        pointer.IntP(1000),
-		RequestTimeoutSec:    pointer.IntP(50),
-		ShutdownDelaySeconds: pointer.IntP(10),
-		WriteTimeoutMs:       pointer.IntP(1000),
-		SwaggerFile:          pointer.StringP("foo"),
+		Host:                   pointer.StringP("127.0.0.1"),
+		Port:                   pointer.IntP(5000),
+		UnixSocket:             pointer.StringP("/tmp/foo.sock"),
+		ReadTimeoutMs:          pointer.IntP(1000),
+		RequestTimeoutSec:      pointer.IntP(50),
+		ShutdownDelaySeconds:   pointer.IntP(10),
+		ShutdownTimeoutSeconds: pointer.IntP(30),
+		WriteTimeoutMs:         pointer.IntP(1000),
+		SwaggerFile:            pointer.StringP("foo"),
+		SwaggerUIPath:          pointer.StringP("/docs/"),
+		TLS:                    &TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"},
+		Protocol:               &ProtocolConfig{Protocols: []Protocol{ProtocolH2C}},
+		AccessLog:              &AccessLogConfig{SampleEvery: 10},
+		Timeout:                &TimeoutConfig{Routes: map[string]int{"/export": 60}},
+		RateLimit:              &RateLimitConfig{Default: &RateLimit{Requests: 10, PeriodSec: 1}},
+		Concurrency:            &ConcurrencyConfig{InitialLimit: 10},
+		CORS:                   &CORSConfig{AllowedOrigins: []string{"*"}},
+		Compression:            &CompressionConfig{Level: 5},
+		OpenAPI:                &OpenAPIConfig{Title: "users"},
+		Validation:             &ValidationConfig{Responses: true},
 	}
 
 	tests := []struct {
@@ -166,6 +425,37 @@ func TestFactoryOption(t *testing.T) {
 			op:     WithTracer(opentracing.NoopTracer{}),
 			assert: assertFactoryOptionWithTracer(opentracing.NoopTracer{}),
 		},
+		{
+			name:   "WithMetrics",
+			op:     WithMetrics(testRegistry),
+			assert: assertFactoryOptionWithMetrics(testRegistry),
+		},
+		{
+			name:    "WithMetrics-Nil",
+			factory: &factory{metrics: testRegistry},
+			op:      WithMetrics(nil),
+			assert:  assertFactoryOptionWithMetrics(testRegistry),
+		},
+		{
+			name:   "WithMiddleware",
+			op:     WithMiddleware(PreTracing, testMiddleware),
+			assert: assertFactoryOptionWithMiddleware(1, 0),
+		},
+		{
+			name:   "WithMiddleware-PostTracing",
+			op:     WithMiddleware(PostTracing, testMiddleware),
+			assert: assertFactoryOptionWithMiddleware(0, 1),
+		},
+		{
+			name:   "WithMiddlewareChain",
+			op:     WithMiddlewareChain(DefaultMiddlewareChain),
+			assert: assertFactoryOptionWithMiddlewareChain(true),
+		},
+		{
+			name:   "WithMiddlewareChain-Nil",
+			op:     WithMiddlewareChain(nil),
+			assert: assertFactoryOptionWithMiddlewareChain(false),
+		},
 		{
 			name:   "WithRouter",
 			op:     WithRouter(func() Handler { return &testHandler{} }),
@@ -176,6 +466,16 @@ func TestFactoryOption(t *testing.T) {
 			op:     WithConfig(Config{}),
 			assert: assertFactoryOptionWithConfig(Config{}),
 		},
+		{
+			name:   "WithConfig-Host",
+			op:     WithConfig(Config{Host: c.Host}),
+			assert: assertFactoryOptionWithConfig(Config{Host: c.Host}),
+		},
+		{
+			name:   "WithConfig-UnixSocket",
+			op:     WithConfig(Config{UnixSocket: c.UnixSocket}),
+			assert: assertFactoryOptionWithConfig(Config{UnixSocket: c.UnixSocket}),
+		},
 		{
 			name:   "WithConfig-Port",
 			op:     WithConfig(Config{Port: c.Port}),
@@ -196,6 +496,11 @@ func TestFactoryOption(t *testing.T) {
 			op:     WithConfig(Config{ShutdownDelaySeconds: c.ShutdownDelaySeconds}),
 			assert: assertFactoryOptionWithConfig(Config{ShutdownDelaySeconds: c.ShutdownDelaySeconds}),
 		},
+		{
+			name:   "WithConfig-ShutdownTimeoutSeconds",
+			op:     WithConfig(Config{ShutdownTimeoutSeconds: c.ShutdownTimeoutSeconds}),
+			assert: assertFactoryOptionWithConfig(Config{ShutdownTimeoutSeconds: c.ShutdownTimeoutSeconds}),
+		},
 		{
 			name:   "WithConfig-WriteTimeoutMs",
 			op:     WithConfig(Config{WriteTimeoutMs: c.WriteTimeoutMs}),
@@ -206,6 +511,16 @@ func TestFactoryOption(t *testing.T) {
 			op:     WithConfig(Config{SwaggerFile: c.SwaggerFile}),
 			assert: assertFactoryOptionWithConfig(Config{SwaggerFile: c.SwaggerFile}),
 		},
+		{
+			name:   "WithConfig-TLS",
+			op:     WithConfig(Config{TLS: c.TLS}),
+			assert: assertFactoryOptionWithConfig(Config{TLS: c.TLS}),
+		},
+		{
+			name:   "WithConfig-Protocol",
+			op:     WithConfig(Config{Protocol: c.Protocol}),
+			assert: assertFactoryOptionWithConfig(Config{Protocol: c.Protocol}),
+		},
 		{
 			name:   "WithConfig-All",
 			op:     WithConfig(c),
@@ -224,12 +539,8 @@ func TestFactoryOption(t *testing.T) {
 	}
 }
 
-func newHandlerFunc(response string) func(http.HandlerFunc) http.HandlerFunc {
-	return func(h http.HandlerFunc) http.HandlerFunc {
-		return func(rw http.ResponseWriter, r *http.Request) {
-			_, _ = fmt.Fprint(rw, response)
-		}
-	}
+func newChecker(name string) health.Checker {
+	return health.NewChecker(name, func(context.Context) error { return nil })
 }
 
 func assertOptionWithServerLogger(expected Logger) optionAssertion {
@@ -252,6 +563,94 @@ func assertOptionWithServerTracer(expected opentracing.Tracer) optionAssertion {
 	}
 }
 
+func assertOptionWithServerMetrics(expected *metrics.Registry) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if s.metrics != expected {
+			t.Errorf("expected %p, got %p", expected, s.metrics)
+		}
+	}
+}
+
+func assertOptionWithServerMiddleware(pre, post int) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if len(s.preTracing) != pre || len(s.postTracing) != post {
+			t.Errorf("expected %d/%d middleware, got %d/%d", pre, post, len(s.preTracing), len(s.postTracing))
+		}
+	}
+}
+
+func assertOptionWithServerMiddlewareChain(expected bool) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if (s.middlewareChain != nil) != expected {
+			t.Errorf("expected chain set to be %t", expected)
+		}
+	}
+}
+
+func assertOptionWithServerPanicHandler(expected bool) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if (s.recovery.handler != nil) != expected {
+			t.Errorf("expected panic handler set to be %t", expected)
+		}
+	}
+}
+
+func assertOptionWithServerErrorHandler(expected bool) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if (s.errorHandler != nil) != expected {
+			t.Errorf("expected error handler set to be %t", expected)
+		}
+	}
+}
+
+func assertOptionWithServerRepanicAbort(expected bool) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if !s.recovery.disableRepanicAbort != expected {
+			t.Errorf("expected repanic abort to be %t", expected)
+		}
+	}
+}
+
+func assertOptionWithServerRateLimitKey(expected bool) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if (s.rateLimit.keyFunc != nil) != expected {
+			t.Errorf("expected rate limit key set to be %t", expected)
+		}
+	}
+}
+
+func assertOptionWithServerRateLimitStore(expected ratelimit.Store) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if s.rateLimit.store != expected {
+			t.Errorf("expected %v, got %v", expected, s.rateLimit.store)
+		}
+	}
+}
+
+func assertOptionWithServerAuthenticator(opts int) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if s.auth.authenticator == nil || len(s.auth.opts) != opts {
+			t.Errorf("expected authenticator with %d options, got %v", opts, s.auth)
+		}
+	}
+}
+
+func assertOptionWithServerAuthorizer(expected bool) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if (s.auth.authorizer != nil) != expected {
+			t.Errorf("expected authorizer set to be %t", expected)
+		}
+	}
+}
+
+func assertOptionWithServerCompressionEncoder(expected string) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if s.compressionEncoders[expected] == nil {
+			t.Errorf("expected encoder %q, got %v", expected, s.compressionEncoders)
+		}
+	}
+}
+
 func assertOptionWithServerConfig(expected Config) optionAssertion {
 	return func(t *testing.T, s *Server) {
 		if ok := reflect.DeepEqual(s.config, expected); !ok {
@@ -300,6 +699,24 @@ func assertOptionWithShutdownDelaySeconds(expected int) optionAssertion {
 	}
 }
 
+func assertOptionWithShutdownTimeoutSeconds(expected int) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if s.config.ShutdownTimeoutSeconds == nil {
+			t.Errorf("expected %d, got nil", expected)
+		} else if *s.config.ShutdownTimeoutSeconds != expected {
+			t.Errorf("expected %d, got %d", expected, *s.config.ShutdownTimeoutSeconds)
+		}
+	}
+}
+
+func assertOptionWithOnShutdown(expected int) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if len(s.shutdownHooks) != expected {
+			t.Errorf("expected %d hooks, got %d", expected, len(s.shutdownHooks))
+		}
+	}
+}
+
 func assertOptionWithHealthCheck(expected string) optionAssertion {
 	return func(t *testing.T, s *Server) {
 		opt := assertOptionHandlerFunc(s.healthCheck, expected)
@@ -321,27 +738,25 @@ func assertOptionWithReadinessCheck(expected string) optionAssertion {
 	}
 }
 
-func assertOptionHandlerFunc(handler func(http.HandlerFunc) http.HandlerFunc, expected string) optionAssertion {
+func assertOptionWithHealthOptions(expected int) optionAssertion {
 	return func(t *testing.T, s *Server) {
-		f := handler(func(rw http.ResponseWriter, r *http.Request) {})
-		ts := httptest.NewServer(f)
-		defer ts.Close()
-
-		res, err := http.Get(ts.URL)
-		if err != nil {
-			t.Errorf("unexpected error: %s", err)
+		if len(s.healthOptions) != expected {
+			t.Errorf("expected %d health options, got %d", expected, len(s.healthOptions))
 		}
-		defer func() {
-			_ = res.Body.Close()
-		}()
+	}
+}
 
-		bytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			t.Errorf("unexpected error: %q", err)
+func assertOptionHandlerFunc(r *health.Registry, expected string) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if r == nil {
+			t.Fatalf("expected registry with %q, got nil", expected)
 		}
 
-		actual := string(bytes)
-		if string(actual) != expected {
+		report := r.Run(context.Background())
+		if len(report.Checks) != 1 {
+			t.Fatalf("expected 1 check, got %d", len(report.Checks))
+		}
+		if actual := report.Checks[0].Name; actual != expected {
 			t.Errorf("expected %q, got %q", expected, actual)
 		}
 	}
@@ -357,6 +772,22 @@ func assertOptionWithSwaggerFile(expected string) optionAssertion {
 	}
 }
 
+func assertOptionWithSwaggerFS(expected fs.FS, name string) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if !reflect.DeepEqual(s.swagger.fsys, expected) || s.swagger.name != name {
+			t.Errorf("expected %v %s, got %v %s", expected, name, s.swagger.fsys, s.swagger.name)
+		}
+	}
+}
+
+func assertOptionWithSwaggerUIFS(expected fs.FS) optionAssertion {
+	return func(t *testing.T, s *Server) {
+		if !reflect.DeepEqual(s.swagger.ui, expected) {
+			t.Errorf("expected %v, got %v", expected, s.swagger.ui)
+		}
+	}
+}
+
 func assertOptionWithServerRouter(expected Handler) optionAssertion {
 	return func(t *testing.T, s *Server) {
 		if s.Router == nil {
@@ -387,6 +818,30 @@ func assertFactoryOptionWithTracer(expected opentracing.Tracer) factoryOptionAss
 	}
 }
 
+func assertFactoryOptionWithMetrics(expected *metrics.Registry) factoryOptionAssertion {
+	return func(t *testing.T, f *factory) {
+		if f.metrics != expected {
+			t.Errorf("expected %p, got %p", expected, f.metrics)
+		}
+	}
+}
+
+func assertFactoryOptionWithMiddleware(pre, post int) factoryOptionAssertion {
+	return func(t *testing.T, f *factory) {
+		if len(f.preTracing) != pre || len(f.postTracing) != post {
+			t.Errorf("expected %d/%d middleware, got %d/%d", pre, post, len(f.preTracing), len(f.postTracing))
+		}
+	}
+}
+
+func assertFactoryOptionWithMiddlewareChain(expected bool) factoryOptionAssertion {
+	return func(t *testing.T, f *factory) {
+		if (f.middlewareChain != nil) != expected {
+			t.Errorf("expected chain set to be %t", expected)
+		}
+	}
+}
+
 func assertFactoryOptionWithConfig(expected Config) factoryOptionAssertion {
 	return func(t *testing.T, f *factory) {
 		if ok := reflect.DeepEqual(f.config, expected); !ok {
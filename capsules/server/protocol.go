@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Protocol identifies an application protocol served by a Server
+type Protocol string
+
+const (
+	// ProtocolHTTP1 serves HTTP/1.1
+	ProtocolHTTP1 Protocol = "http/1.1"
+	// ProtocolHTTP2 serves HTTP/2 over TLS, negotiated with ALPN
+	ProtocolHTTP2 Protocol = "h2"
+	// ProtocolH2C serves HTTP/2 over cleartext TCP, both through the HTTP/1.1
+	// Upgrade mechanism and with prior knowledge
+	ProtocolH2C Protocol = "h2c"
+)
+
+// ProtocolConfig contains options for the protocols served by a Server
+type ProtocolConfig struct {
+	Protocols            []Protocol
+	MaxConcurrentStreams uint32
+	IdleTimeoutMs        int
+}
+
+// defaultProtocols are served when no ProtocolConfig is provided
+var defaultProtocols = []Protocol{ProtocolHTTP1, ProtocolHTTP2}
+
+// protocolConfig provides the ProtocolConfig of the Server, creating it when
+// protocols have not been configured yet.
+func (s *Server) protocolConfig() *ProtocolConfig {
+	c := ProtocolConfig{}
+	if s.config.Protocol != nil {
+		c = *s.config.Protocol
+	}
+	s.config.Protocol = &c
+	return s.config.Protocol
+}
+
+// configureProtocols applies c to srvr, wrapping its handler when h2c is
+// enabled. It provides the ALPN protocols to offer for TLS connections.
+func configureProtocols(srvr *http.Server, c *ProtocolConfig) ([]string, error) {
+	protocols := defaultProtocols
+	h2s := &http2.Server{}
+	if c != nil {
+		if len(c.Protocols) > 0 {
+			protocols = c.Protocols
+		}
+		h2s.MaxConcurrentStreams = c.MaxConcurrentStreams
+		if c.IdleTimeoutMs > 0 {
+			srvr.IdleTimeout = time.Duration(c.IdleTimeoutMs) * time.Millisecond
+			h2s.IdleTimeout = srvr.IdleTimeout
+		}
+	}
+
+	var http1, http2TLS, cleartext bool
+	for _, p := range protocols {
+		switch p {
+		case ProtocolHTTP1:
+			http1 = true
+		case ProtocolHTTP2:
+			http2TLS = true
+		case ProtocolH2C:
+			cleartext = true
+		default:
+			return nil, fmt.Errorf("unsupported protocol %q", p)
+		}
+	}
+
+	nextProtos := make([]string, 0, 2)
+	if http2TLS {
+		if err := http2.ConfigureServer(srvr, h2s); err != nil {
+			return nil, err
+		}
+		nextProtos = append(nextProtos, string(ProtocolHTTP2))
+	} else {
+		srvr.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	if http1 || cleartext {
+		nextProtos = append(nextProtos, string(ProtocolHTTP1))
+	}
+
+	if cleartext {
+		srvr.Handler = h2c.NewHandler(srvr.Handler, h2s)
+	}
+
+	return nextProtos, nil
+}
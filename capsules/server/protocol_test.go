@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestServeProtocols(t *testing.T) {
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		client   *http.Client
+		err      bool
+		expected string
+	}{
+		{
+			name:     "Default-HTTP1",
+			client:   http.DefaultClient,
+			expected: "HTTP/1.1",
+		},
+		{
+			name:   "Default-H2C",
+			client: h2cClient,
+			err:    true,
+		},
+		{
+			name:     "H2C-HTTP1",
+			opts:     []Option{WithServerProtocols(ProtocolHTTP1, ProtocolH2C)},
+			client:   http.DefaultClient,
+			expected: "HTTP/1.1",
+		},
+		{
+			name:     "H2C-PriorKnowledge",
+			opts:     []Option{WithServerProtocolConfig(ProtocolConfig{Protocols: []Protocol{ProtocolHTTP1, ProtocolH2C}, MaxConcurrentStreams: 10, IdleTimeoutMs: 1000})},
+			client:   h2cClient,
+			expected: "HTTP/2.0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, stop := serveProtocolTest(t, test.opts...)
+			defer stop()
+
+			res, err := test.client.Get(url + "/proto")
+			if test.err {
+				if err == nil {
+					_ = res.Body.Close()
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			_ = res.Body.Close()
+
+			if actual := res.Header.Get("X-Proto"); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestServeH2CUpgrade(t *testing.T) {
+	url, stop := serveProtocolTest(t, WithServerProtocols(ProtocolHTTP1, ProtocolH2C))
+	defer stop()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	_, _ = fmt.Fprint(conn, "GET /proto HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\n"+
+		"Upgrade: h2c\r\n"+
+		"HTTP2-Settings: AAMAAABkAARAAAAAAAIAAAAA\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101 Switching Protocols, got %d", res.StatusCode)
+	}
+
+	// the upgraded request is answered on stream 1 once the client preface
+	// was sent
+	if _, err := fmt.Fprint(conn, http2.ClientPreface); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	framer := http2.NewFramer(conn, br)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		headers, ok := frame.(*http2.MetaHeadersFrame)
+		if !ok {
+			continue
+		}
+		if headers.StreamID != 1 {
+			t.Fatalf("expected the response on stream 1, got stream %d", headers.StreamID)
+		}
+		if status := headers.PseudoValue("status"); status != "200" {
+			t.Errorf("expected status 200, got %q", status)
+		}
+		return
+	}
+}
+
+func TestServeTLSProtocols(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "Default",
+			expected: "HTTP/2.0",
+		},
+		{
+			name:     "HTTP1Only",
+			opts:     []Option{WithServerProtocols(ProtocolHTTP1)},
+			expected: "HTTP/1.1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, stop := serveProtocolTest(t, append(test.opts, WithServerTLS(certFile, keyFile))...)
+			defer stop()
+
+			transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pki.pool}, ForceAttemptHTTP2: true}
+			res, err := (&http.Client{Transport: transport}).Get(strings.Replace(url, "http://", "https://", 1) + "/proto")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			_ = res.Body.Close()
+
+			if actual := res.Header.Get("X-Proto"); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestConfigureProtocolsError(t *testing.T) {
+	if _, err := configureProtocols(&http.Server{}, &ProtocolConfig{Protocols: []Protocol{"h3"}}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// serveProtocolTest serves a handler echoing the request protocol and provides
+// its url and a function to stop it
+func serveProtocolTest(t *testing.T, opts ...Option) (string, func()) {
+	t.Helper()
+
+	mux := &http.ServeMux{}
+	mux.HandleFunc("/proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	})
+	opts = append([]Option{WithShutdownDelaySeconds(0)}, opts...)
+	s := NewFactory(WithRouter(func() Handler { return mux })).Create(opts...)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.ServeListener(ctx, l)
+	}()
+
+	return "http://" + l.Addr().String(), func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/ratelimit"
+)
+
+// RateLimitKey identifies what requests are rate limited by
+type RateLimitKey string
+
+const (
+	// RateLimitKeyIP limits requests by client ip address
+	RateLimitKeyIP RateLimitKey = "ip"
+	// RateLimitKeyHeader limits requests by the value of a header, falling back
+	// to the client ip address when the header is missing
+	RateLimitKeyHeader RateLimitKey = "header"
+	// RateLimitKeyPrincipal limits requests by the subject of the
+	// authenticated auth.Principal, falling back to the client ip address for
+	// anonymous requests
+	RateLimitKeyPrincipal RateLimitKey = "principal"
+)
+
+// RateLimit is the number of requests allowed per period for a key. Burst
+// requests can be made at once, defaulting to Requests.
+type RateLimit struct {
+	Requests  int
+	PeriodSec int
+	Burst     int
+}
+
+// RateLimitConfig contains options for rate limiting requests
+type RateLimitConfig struct {
+	// Default is the limit of every route without a limit in Routes. Requests
+	// are not limited by default.
+	Default *RateLimit
+	// Routes are the limits of route patterns. Every route has its own limit
+	// for a key.
+	Routes map[string]RateLimit
+	// Key is what requests are limited by.
+	// Defaults to RateLimitKeyIP
+	Key RateLimitKey
+	// KeyHeader is the header limited by with RateLimitKeyHeader
+	KeyHeader string
+	// TrustForwardedFor uses the first address of the X-Forwarded-For header
+	// as the client ip address. Only enable it behind a proxy which sets the
+	// header.
+	TrustForwardedFor bool
+	// ExcludePaths are additional paths which are not rate limited. Health
+	// probes are never rate limited.
+	ExcludePaths []string
+}
+
+// RateLimitKeyFunc provides the key a request is rate limited by
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RemoteIPKey provides a RateLimitKeyFunc limiting requests by client ip
+// address, optionally taken from the X-Forwarded-For header.
+func RemoteIPKey(trustForwardedFor bool) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if trustForwardedFor {
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				return strings.TrimSpace(strings.Split(xff, ",")[0])
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// HeaderKey provides a RateLimitKeyFunc limiting requests by the value of the
+// header name, falling back to fallback when the header is missing.
+func HeaderKey(name string, fallback RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return fallback(r)
+	}
+}
+
+// PrincipalKey provides a RateLimitKeyFunc limiting requests by the subject of
+// their auth.Principal, falling back to fallback for anonymous requests.
+func PrincipalKey(fallback RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if p, ok := auth.FromContext(r.Context()); ok && p.Subject != "" {
+			return "principal:" + p.Subject
+		}
+		return fallback(r)
+	}
+}
+
+// rateLimitConfig provides the RateLimitConfig of the Server, creating it
+// when rate limiting has not been configured yet.
+func (s *Server) rateLimitConfig() *RateLimitConfig {
+	c := RateLimitConfig{}
+	if s.config.RateLimit != nil {
+		c = *s.config.RateLimit
+	}
+	routes := make(map[string]RateLimit, len(c.Routes))
+	for pattern, l := range c.Routes {
+		routes[pattern] = l
+	}
+	c.Routes = routes
+	s.config.RateLimit = &c
+	return s.config.RateLimit
+}
+
+// rateLimitKeyFunc provides the RateLimitKeyFunc configured for the Server
+func (s *Server) rateLimitKeyFunc(c RateLimitConfig) RateLimitKeyFunc {
+	if s.rateLimit.keyFunc != nil {
+		return s.rateLimit.keyFunc
+	}
+	ip := RemoteIPKey(c.TrustForwardedFor)
+	switch {
+	case c.Key == RateLimitKeyHeader && c.KeyHeader != "":
+		return HeaderKey(c.KeyHeader, ip)
+	case c.Key == RateLimitKeyPrincipal:
+		return PrincipalKey(ip)
+	}
+	return ip
+}
+
+// RateLimitMiddleware rejects requests exceeding the rate limit of their route
+// and key with a 429. Every response carries the RateLimit-Limit,
+// RateLimit-Remaining, and RateLimit-Reset headers, rejected requests also
+// carry Retry-After. Requests are allowed when the store fails. It is a noop
+// when no rate limits are configured.
+func (s *Server) RateLimitMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		c := RateLimitConfig{}
+		if s.config.RateLimit != nil {
+			c = *s.config.RateLimit
+		}
+		if c.Default == nil && len(c.Routes) == 0 {
+			return next
+		}
+
+		excluded := make(map[string]bool)
+		for _, p := range probePaths {
+			excluded[p] = true
+		}
+		for _, p := range c.ExcludePaths {
+			excluded[p] = true
+		}
+		store := s.rateLimitStore()
+		keyFunc := s.rateLimitKeyFunc(c)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if excluded[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := s.routePattern(r)
+			limit, key := c.Default, keyFunc(r)
+			if l, ok := c.Routes[route]; ok {
+				limit, key = &l, route+" "+key
+			}
+			if limit == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := store.Allow(r.Context(), key, ratelimit.Limit{
+				Requests: limit.Requests,
+				Period:   time.Duration(limit.PeriodSec) * time.Second,
+				Burst:    limit.Burst,
+			})
+			if err != nil {
+				s.logger.WarnCtx(r.Context(), "rate limit store failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if res.Limit == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				httperr.Write(w, r, httperr.Problem{Status: http.StatusTooManyRequests})
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, as used by rate limit headers
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// rateLimitStore provides the Store of the Server, creating a MemoryStore when
+// none has been provided.
+func (s *Server) rateLimitStore() ratelimit.Store {
+	s.rateLimit.once.Do(func() {
+		if s.rateLimit.store == nil {
+			s.rateLimit.store = ratelimit.NewMemoryStore()
+		}
+	})
+	return s.rateLimit.store
+}
+
+// rateLimitOptions contains the rate limiting options which cannot be
+// provided through Config
+type rateLimitOptions struct {
+	once    sync.Once
+	store   ratelimit.Store
+	keyFunc RateLimitKeyFunc
+}
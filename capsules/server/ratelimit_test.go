@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/ratelimit"
+)
+
+type errStore struct{}
+
+func (errStore) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("unavailable")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	type request struct {
+		path       string
+		remoteAddr string
+		header     http.Header
+		status     int
+	}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		requests []request
+	}{
+		{
+			name: "Unlimited",
+			requests: []request{
+				{path: "/a", status: http.StatusOK},
+				{path: "/a", status: http.StatusOK},
+			},
+		},
+		{
+			name: "Default",
+			opts: []Option{WithServerRateLimit(RateLimit{Requests: 2, PeriodSec: 60})},
+			requests: []request{
+				{path: "/a", status: http.StatusOK},
+				{path: "/b", status: http.StatusOK},
+				{path: "/a", status: http.StatusTooManyRequests},
+				{path: "/live", status: http.StatusNoContent},
+				{path: "/a", remoteAddr: "10.0.0.2:1234", status: http.StatusOK},
+			},
+		},
+		{
+			name: "Route",
+			opts: []Option{WithServerRouteRateLimit("/a", RateLimit{Requests: 1, PeriodSec: 60})},
+			requests: []request{
+				{path: "/a", status: http.StatusOK},
+				{path: "/a", status: http.StatusTooManyRequests},
+				{path: "/b", status: http.StatusOK},
+				{path: "/b", status: http.StatusOK},
+			},
+		},
+		{
+			name: "RouteAndDefault",
+			opts: []Option{
+				WithServerRateLimit(RateLimit{Requests: 1, PeriodSec: 60}),
+				WithServerRouteRateLimit("/a", RateLimit{Requests: 1, PeriodSec: 60}),
+			},
+			requests: []request{
+				{path: "/a", status: http.StatusOK},
+				{path: "/b", status: http.StatusOK},
+				{path: "/b", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "Header",
+			opts: []Option{WithServerRateLimitConfig(RateLimitConfig{
+				Default:   &RateLimit{Requests: 1, PeriodSec: 60},
+				Key:       RateLimitKeyHeader,
+				KeyHeader: "X-Api-Key",
+			})},
+			requests: []request{
+				{path: "/a", header: http.Header{"X-Api-Key": {"foo"}}, status: http.StatusOK},
+				{path: "/a", header: http.Header{"X-Api-Key": {"foo"}}, status: http.StatusTooManyRequests},
+				{path: "/a", header: http.Header{"X-Api-Key": {"bar"}}, status: http.StatusOK},
+				{path: "/a", status: http.StatusOK},
+			},
+		},
+		{
+			name: "Principal",
+			opts: []Option{
+				WithServerRateLimitConfig(RateLimitConfig{
+					Default: &RateLimit{Requests: 1, PeriodSec: 60},
+					Key:     RateLimitKeyPrincipal,
+				}),
+				WithServerAuthenticator(auth.NewAPIKeyAuthenticator(auth.APIKeyConfig{Keys: []auth.APIKey{
+					{Key: "foo", Subject: "foo"},
+					{Key: "bar", Subject: "bar"},
+				}}), auth.WithOptional(true)),
+			},
+			requests: []request{
+				{path: "/a", header: http.Header{"X-Api-Key": {"foo"}}, status: http.StatusOK},
+				{path: "/a", header: http.Header{"X-Api-Key": {"foo"}}, remoteAddr: "10.0.0.2:1234", status: http.StatusTooManyRequests},
+				{path: "/a", header: http.Header{"X-Api-Key": {"bar"}}, status: http.StatusOK},
+				{path: "/a", status: http.StatusOK},
+				{path: "/a", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "ForwardedFor",
+			opts: []Option{WithServerRateLimitConfig(RateLimitConfig{
+				Default:           &RateLimit{Requests: 1, PeriodSec: 60},
+				TrustForwardedFor: true,
+			})},
+			requests: []request{
+				{path: "/a", header: http.Header{"X-Forwarded-For": {"1.1.1.1, 10.0.0.1"}}, status: http.StatusOK},
+				{path: "/a", header: http.Header{"X-Forwarded-For": {"1.1.1.2, 10.0.0.1"}}, status: http.StatusOK},
+				{path: "/a", header: http.Header{"X-Forwarded-For": {"1.1.1.1"}}, status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "KeyFunc",
+			opts: []Option{
+				WithServerRateLimit(RateLimit{Requests: 1, PeriodSec: 60}),
+				WithServerRateLimitKey(func(r *http.Request) string { return "shared" }),
+			},
+			requests: []request{
+				{path: "/a", status: http.StatusOK},
+				{path: "/a", remoteAddr: "10.0.0.2:1234", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "StoreError",
+			opts: []Option{
+				WithServerRateLimit(RateLimit{Requests: 1, PeriodSec: 60}),
+				WithServerRateLimitStore(errStore{}),
+			},
+			requests: []request{
+				{path: "/a", status: http.StatusOK},
+				{path: "/a", status: http.StatusOK},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {})
+			mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {})
+			s := NewFactory().Create(append([]Option{WithServerRouter(mux)}, test.opts...)...)
+
+			for i, req := range test.requests {
+				r := httptest.NewRequest(http.MethodGet, req.path, nil)
+				if req.remoteAddr != "" {
+					r.RemoteAddr = req.remoteAddr
+				}
+				for k, v := range req.header {
+					r.Header[k] = v
+				}
+				rr := httptest.NewRecorder()
+				s.ServeHTTP(rr, r)
+
+				if rr.Code != req.status {
+					t.Errorf("request %d: expected status %d, got %d", i, req.status, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {})
+	s := NewFactory().Create(WithServerRouter(mux), WithServerRateLimit(RateLimit{Requests: 2, PeriodSec: 60}))
+
+	expected := []map[string]string{
+		{"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "30", "Retry-After": ""},
+		{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", "Retry-After": ""},
+		{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", "Retry-After": "30", "Content-Type": "application/problem+json"},
+	}
+
+	for i, headers := range expected {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+		for k, v := range headers {
+			if actual := rr.Header().Get(k); actual != v {
+				t.Errorf("request %d: expected %s %q, got %q", i, k, v, actual)
+			}
+		}
+	}
+}
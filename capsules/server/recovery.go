@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// PanicHandler writes the response for a request whose handler panicked with
+// the recovered value
+type PanicHandler func(w http.ResponseWriter, r *http.Request, recovered interface{})
+
+// ProblemPanicHandler writes a 500 application/problem+json response. It is
+// the default PanicHandler.
+func ProblemPanicHandler(w http.ResponseWriter, r *http.Request, recovered interface{}) {
+	httperr.Write(w, r, httperr.Problem{Status: http.StatusInternalServerError})
+}
+
+// JSONPanicHandler writes a 500 application/json response.
+func JSONPanicHandler(w http.ResponseWriter, r *http.Request, recovered interface{}) {
+	writePanicResponse(w, "application/json", map[string]interface{}{
+		"error": http.StatusText(http.StatusInternalServerError),
+	})
+}
+
+func writePanicResponse(w http.ResponseWriter, contentType string, body interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// RecoveryMiddleware recovers from panics in the handler chain it wraps. The
+// panic and its stack are logged and recorded on the request span before the
+// PanicHandler writes a 500 response. Panics with http.ErrAbortHandler are
+// re-panicked unless disabled with WithServerRepanicAbort.
+func (s *Server) RecoveryMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseWriter(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler && !s.recovery.disableRepanicAbort {
+					panic(recovered)
+				}
+
+				stack := string(debug.Stack())
+				if p, ok := recovered.(handlerPanic); ok {
+					recovered, stack = p.value, string(p.stack)
+				}
+				s.logger.ErrorCtx(r.Context(), "panic recovered",
+					"panic", fmt.Sprint(recovered),
+					"stack", stack,
+					"path", r.URL.EscapedPath(),
+					"method", r.Method,
+				)
+
+				if span := opentracing.SpanFromContext(r.Context()); span != nil {
+					ext.Error.Set(span, true)
+					span.LogFields(
+						log.String("event", "panic"),
+						log.String("message", fmt.Sprint(recovered)),
+						log.String("stack", stack),
+					)
+				}
+
+				if rw.status != 0 {
+					// the response has started, the client will see a
+					// truncated body
+					return
+				}
+
+				handler := s.recovery.handler
+				if handler == nil {
+					handler = ProblemPanicHandler
+				}
+				handler(rw, r, recovered)
+			}()
+			next.ServeHTTP(rw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// recoveryConfig contains options for the RecoveryMiddleware
+type recoveryConfig struct {
+	handler             PanicHandler
+	disableRepanicAbort bool
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		handler     http.HandlerFunc
+		status      int
+		contentType string
+		logged      bool
+	}{
+		{
+			name:        "Problem",
+			handler:     func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			status:      http.StatusInternalServerError,
+			contentType: "application/problem+json",
+			logged:      true,
+		},
+		{
+			name:        "JSON",
+			opts:        []Option{WithServerPanicHandler(JSONPanicHandler)},
+			handler:     func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			status:      http.StatusInternalServerError,
+			contentType: "application/json",
+			logged:      true,
+		},
+		{
+			name: "Buffered",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			status:      http.StatusInternalServerError,
+			contentType: "application/problem+json",
+			logged:      true,
+		},
+		{
+			name: "Started",
+			opts: []Option{WithServerMiddlewareChain(func(s *Server, _, _ []Middleware) []Middleware {
+				return []Middleware{s.TracingMiddleware(), s.RecoveryMiddleware()}
+			})},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			status: http.StatusAccepted,
+			logged: true,
+		},
+		{
+			name:        "Abort",
+			opts:        []Option{WithServerRepanicAbort(false)},
+			handler:     func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) },
+			status:      http.StatusInternalServerError,
+			contentType: "application/problem+json",
+			logged:      true,
+		},
+		{
+			name:    "NoPanic",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			status:  http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			tracer := mocktracer.New()
+			mux := http.NewServeMux()
+			mux.HandleFunc("/test", test.handler)
+
+			opts := append([]Option{WithServerRouter(mux)}, test.opts...)
+			s := NewFactory(WithLogger(logger), WithTracer(tracer)).Create(opts...)
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); test.contentType != "" && ct != test.contentType {
+				t.Errorf("expected content type %q, got %q", test.contentType, ct)
+			}
+			if test.contentType != "" && !json.Valid(rr.Body.Bytes()) {
+				t.Errorf("expected a json body, got %q", rr.Body.String())
+			}
+
+			if logged := len(logger.entries("error")) > 0; logged != test.logged {
+				t.Fatalf("expected logged to be %t, got %v", test.logged, logger.logs)
+			}
+			if test.logged && !strings.Contains(fmt.Sprint(logger.entries("error")[0].field("stack")), "TestRecoveryMiddleware.func") {
+				t.Errorf("expected the stack to be logged, got %v", logger.logs)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 finished span, got %d", len(spans))
+			}
+			if tagged := spans[0].Tag("error") == true; tagged != test.logged {
+				t.Errorf("expected error tag to be %t, got %v", test.logged, spans[0].Tag("error"))
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddlewareRepanicAbort(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+	logger := &recordingLogger{}
+	s := NewFactory(WithLogger(logger)).Create(WithServerRouter(mux))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked, got %v", v)
+		}
+		if len(logger.entries("error")) != 0 {
+			t.Errorf("expected nothing to be logged, got %v", logger.logs)
+		}
+	}()
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+
+	"go.adenix.dev/adderall/capsules/requestid"
+)
+
+// RequestIDMiddleware assigns every request an id, accepting a valid
+// X-Request-ID header from the client or generating one. The id is stored in
+// the request context, retrievable with requestid.FromContext, and echoed in
+// the response header.
+func (s *Server) RequestIDMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestid.Header)
+			if !requestid.Valid(id) {
+				id = requestid.New()
+			}
+			w.Header().Set(requestid.Header, id)
+			next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
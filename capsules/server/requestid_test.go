@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/requestid"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		generated bool
+	}{
+		{
+			name:      "Generated",
+			generated: true,
+		},
+		{
+			name:   "Accepted",
+			header: "abc-123",
+		},
+		{
+			name:      "Invalid",
+			header:    "foo bar",
+			generated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var seen string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = requestid.FromContext(r.Context())
+			})
+			logger := &recordingLogger{}
+			s := NewFactory(WithLogger(logger)).Create(WithServerRouter(mux))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if test.header != "" {
+				req.Header.Set(requestid.Header, test.header)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			id := rr.Header().Get(requestid.Header)
+			if !requestid.Valid(id) {
+				t.Fatalf("expected a valid request id in the response, got %q", id)
+			}
+			if !test.generated && id != test.header {
+				t.Errorf("expected request id %q, got %q", test.header, id)
+			}
+			if test.generated && id == test.header {
+				t.Errorf("expected request id to be generated, got %q", id)
+			}
+			if seen != id {
+				t.Errorf("expected handler to see request id %q, got %q", id, seen)
+			}
+			if logs := accessLogs(logger); len(logs) != 1 || logs[0].field("request_id") != id {
+				t.Errorf("expected request id %q to be logged, got %v", id, logs)
+			}
+		})
+	}
+}
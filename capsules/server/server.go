@@ -2,194 +2,369 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"go.adenix.dev/adderall/capsules/health"
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/lifecycle"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/openapi"
 	"go.adenix.dev/adderall/internal/pointer"
 )
 
 // Server represents a HTTP server. Server is instrumented with OpenTracing,
-// logging, monitoring endpoints, and optionally an OpenAPI v2 endpoint.
+// logging, metrics, monitoring endpoints, and optionally serves an OpenAPI
+// document generated from its routes or read from a file.
 type Server struct {
 	Router         Handler
 	tracer         opentracing.Tracer
 	logger         Logger
+	metrics        *metrics.Registry
 	config         Config
-	livenessCheck  func(http.HandlerFunc) http.HandlerFunc
-	readinessCheck func(http.HandlerFunc) http.HandlerFunc
-	healthCheck    func(http.HandlerFunc) http.HandlerFunc
+	livenessCheck  *health.Registry
+	readinessCheck *health.Registry
+	healthCheck    *health.Registry
+	healthOptions  []health.Option
+
+	started       int32
+	draining      int32
+	mu            sync.Mutex
+	srvr          *http.Server
+	shutdownHooks []func(ctx context.Context) error
+
+	preTracing      []Middleware
+	postTracing     []Middleware
+	middlewareChain MiddlewareChain
+	handlerOnce     sync.Once
+	handler         http.Handler
+	recovery        recoveryConfig
+	errorHandler    ErrorHandler
+	auth            authOptions
+	rateLimit       rateLimitOptions
+	concurrency     concurrencyState
+	openapi         *openapi.Spec
+	validation      validationState
+	swagger         swaggerOptions
+
+	compressionEncoders map[string]Encoder
+
+	// accessLogRequests counts the requests considered for sampling by the
+	// AccessLogMiddleware
+	accessLogRequests uint32
 }
 
-// Serve sets up a http server and begins listening
+var _ lifecycle.Component = (*Server)(nil)
+
+// Serve sets up a http server and begins listening. Serve blocks until ctx is
+// cancelled or the process receives SIGINT or SIGTERM and the server has shut
+// down. Use Start and Stop when the Server is run by a lifecycle.App.
 func (s *Server) Serve(ctx context.Context) error {
-	handler := s.getHandler(ctx)
-	port := s.config.Port
-	if port == nil || *port < 1 {
-		port = pointer.IntP(8080)
-	}
+	return s.serve(ctx, s.Start)
+}
 
-	srvr := http.Server{
-		Addr:         fmt.Sprintf(":%d", *port),
-		Handler:      handler,
-		ReadTimeout:  time.Duration(*s.config.ReadTimeoutMs) * time.Millisecond,
-		WriteTimeout: time.Duration(*s.config.WriteTimeoutMs) * time.Millisecond,
-	}
+// ServeListener behaves like Serve but accepts connections on l instead of
+// the address configured for the Server, e.g. for socket activation or tests.
+func (s *Server) ServeListener(ctx context.Context, l net.Listener) error {
+	return s.serve(ctx, func(ctx context.Context) error {
+		return s.startListener(ctx, l)
+	})
+}
 
-	errs := make(chan error)
+func (s *Server) serve(ctx context.Context, start func(ctx context.Context) error) error {
+	errs := make(chan error, 1)
 	go func() {
-		if err := srvr.ListenAndServe(); err != http.ErrServerClosed {
-			s.logger.ErrorCtx(ctx, "server failed to start up", "error", err)
-			errs <- err
-		} else {
-			errs <- nil
-		}
+		errs <- start(ctx)
 	}()
 
-	s.logger.InfoCtx(ctx, "server started successfully", "port", port)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
 
-	go func() {
-		errs <- s.gracefulShutdown(ctx, &srvr)
-	}()
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-quit:
+		s.logger.InfoCtx(ctx, "signal received", "signal", sig)
+	case <-ctx.Done():
+		s.logger.InfoCtx(ctx, "context done", "error", ctx.Err())
+		// ctx is done, it must not cut the drain short
+		return s.Stop(context.Background())
+	}
 
-	return <-errs
+	return s.Stop(ctx)
 }
 
-// addSwagger configures and adds handers for an OpenAPI file and the Swagger UI
-func (s *Server) addSwagger(r Handler) {
-	swaggerFileLocation := "/swagger.json"
-	if s.config.SwaggerFile != nil && len(*s.config.SwaggerFile) > 0 {
-		swaggerFileLocation = *s.config.SwaggerFile
+// Start begins listening and blocks until the server fails or is stopped.
+// Start returns nil once Stop has been called.
+func (s *Server) Start(ctx context.Context) error {
+	l, err := s.listen()
+	if err != nil {
+		s.logger.ErrorCtx(ctx, "server failed to start up", "error", err)
+		return err
 	}
+	return s.startListener(ctx, l)
+}
 
-	if _, err := os.Stat(swaggerFileLocation); err != nil {
-		s.logger.InfoCtx(context.Background(), "swagger not added", "location", swaggerFileLocation, "error", err)
-		return
+func (s *Server) startListener(ctx context.Context, l net.Listener) error {
+	srvr := s.httpServer(ctx)
+
+	nextProtos, err := configureProtocols(srvr, s.config.Protocol)
+	if err != nil {
+		s.logger.ErrorCtx(ctx, "server failed to start up", "error", err)
+		_ = l.Close()
+		return err
 	}
 
-	r.HandleFunc(swaggerFileLocation, func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, swaggerFileLocation)
-	})
+	if s.config.TLS != nil {
+		reloader, err := newCertReloader(*s.config.TLS, nextProtos, s.logger)
+		if err != nil {
+			s.logger.ErrorCtx(ctx, "server failed to start up", "error", err)
+			_ = l.Close()
+			return err
+		}
+		srvr.TLSConfig = reloader.TLSConfig()
 
-	swaggerUIHandler := httpSwagger.Handler(
-		httpSwagger.URL(swaggerFileLocation),
-	)
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go reloader.watch(watchCtx)
+	}
 
-	r.HandleFunc("/swagger", func(rw http.ResponseWriter, r *http.Request) {
-		http.Redirect(rw, r, "/swagger/", http.StatusMovedPermanently)
-	})
-	r.HandleFunc("/swagger/", swaggerUIHandler)
-	r.HandleFunc("/swagger/*", swaggerUIHandler)
+	s.logger.InfoCtx(ctx, "server started successfully", "address", l.Addr().String(), "tls", s.config.TLS != nil)
+	atomic.StoreInt32(&s.started, 1)
+
+	if s.config.TLS != nil {
+		err = srvr.ServeTLS(l, "", "")
+	} else {
+		err = srvr.Serve(l)
+	}
+	if err != http.ErrServerClosed {
+		s.logger.ErrorCtx(ctx, "server failed to start up", "error", err)
+		return err
+	}
+	return nil
 }
 
-// ServeHTTP is used to satisfy http.Handler interface, primarily to pass to test recorder.
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.getHandler(context.Background()).ServeHTTP(w, r)
-}
-
-// ProfilingMiddleware ...
-func (s *Server) profilingMiddleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			s.logger.DebugCtx(r.Context(), "http path response time",
-				"path", r.URL.EscapedPath(),
-				"method", r.Method,
-				"time", time.Since(start),
-			)
+// listen provides a listener for the configured unix socket, or the configured
+// host and port.
+func (s *Server) listen() (net.Listener, error) {
+	if s.config.UnixSocket != nil && len(*s.config.UnixSocket) > 0 {
+		path := *s.config.UnixSocket
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(path)
 		}
-		return http.HandlerFunc(fn)
+		return net.Listen("unix", path)
 	}
-}
 
-// TracingMiddleware ...
-func (s *Server) tracingMiddleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return nethttp.Middleware(s.tracer, next)
+	port := s.config.Port
+	if port == nil || *port < 1 {
+		port = pointer.IntP(8080)
+	}
+	host := ""
+	if s.config.Host != nil {
+		host = *s.config.Host
 	}
+	return net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(*port)))
 }
 
-// TimeoutMiddleware ...
-func (s *Server) timeoutMiddleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.TimeoutHandler(next, time.Duration(*s.config.RequestTimeoutSec)*time.Second, "timeout")
+// Stop gracefully shuts the server down, see WithShutdownDelaySeconds and
+// WithShutdownTimeoutSeconds. Cancelling ctx cuts the shutdown short, closing
+// the remaining connections. Stop may be called before Start, in which case
+// Start returns immediately.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.shutdown(ctx, s.httpServer(ctx))
+}
+
+// httpServer provides the http.Server used by Start and Stop, creating it on
+// first use.
+func (s *Server) httpServer(ctx context.Context) *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.srvr != nil {
+		return s.srvr
 	}
+
+	s.srvr = &http.Server{
+		Handler:      s.getHandler(),
+		ReadTimeout:  time.Duration(*s.config.ReadTimeoutMs) * time.Millisecond,
+		WriteTimeout: time.Duration(*s.config.WriteTimeoutMs) * time.Millisecond,
+	}
+	return s.srvr
 }
 
-func (s *Server) getHandler(ctx context.Context) http.Handler {
-	var h http.Handler = s.Router
-	h = s.timeoutMiddleware()(h)
-	h = s.tracingMiddleware()(h)
-	h = s.profilingMiddleware()(h)
-	return h
+// OnShutdown registers a hook which is run once the server has stopped
+// accepting requests, e.g. to flush a logger or close clients. Hooks are run in
+// reverse registration order.
+func (s *Server) OnShutdown(hook func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
 }
 
-func (s *Server) gracefulShutdown(ctx context.Context, server *http.Server) error {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+// ServeHTTP is used to satisfy http.Handler interface, primarily to pass to test recorder.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.getHandler().ServeHTTP(w, r)
+}
 
-	sig := <-quit
-	s.logger.InfoCtx(ctx, "signal received", "signal", sig)
+type routePatternKey struct{}
 
-	shutdownDelaySeconds := s.config.ShutdownDelaySeconds
-	if shutdownDelaySeconds == nil || *shutdownDelaySeconds < 1 {
-		shutdownDelaySeconds = pointer.IntP(5)
+// withRoutePattern provides a copy of r carrying the pattern the Router matches
+// for it, so the middleware reporting it does not look it up again
+func (s *Server) withRoutePattern(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routePatternKey{}, s.matchRoutePattern(r)))
+}
+
+// routePattern provides the pattern the Router matched for a request, keeping
+// the cardinality of labels and log fields bounded. Routers which cannot report
+// the matched pattern are labelled "unmatched".
+func (s *Server) routePattern(r *http.Request) string {
+	if pattern, ok := r.Context().Value(routePatternKey{}).(string); ok {
+		return pattern
 	}
-	timeout := time.Duration(*shutdownDelaySeconds) * time.Second
+	return s.matchRoutePattern(r)
+}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+// matchRoutePattern looks up the pattern the Router matches for r
+func (s *Server) matchRoutePattern(r *http.Request) string {
+	if m, ok := s.Router.(interface {
+		Handler(r *http.Request) (http.Handler, string)
+	}); ok {
+		if _, pattern := m.Handler(r); pattern != "" {
+			return pattern
+		}
+	}
+	return "unmatched"
+}
+
+// shutdown gracefully stops server. Readiness is reported as failing for the
+// shutdown delay so load balancers stop routing traffic before the listener is
+// closed, in-flight requests are then given until the shutdown timeout to
+// complete before the shutdown hooks are run. The delay is skipped when the
+// server never started, and both are cut short when ctx is done, the error of
+// ctx being returned.
+func (s *Server) shutdown(ctx context.Context, server *http.Server) error {
+	atomic.StoreInt32(&s.draining, 1)
+
+	var err error
+	if atomic.LoadInt32(&s.started) == 1 {
+		delay := s.shutdownDelay()
+		s.logger.InfoCtx(ctx, "draining server", "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			s.logger.WarnCtx(ctx, "draining interrupted", "error", err)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, s.shutdownTimeout())
 	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
+
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
 		s.logger.ErrorCtx(
 			ctx,
 			"error while gracefully shutting down server, forcing shutdown because of error",
-			"err", err)
-		return err
+			"err", shutdownErr)
+		_ = server.Close()
+		if err == nil {
+			err = shutdownErr
+		}
 	}
-	s.logger.InfoCtx(ctx, "server exited successfully")
-	return nil
+
+	if hookErr := s.runShutdownHooks(shutdownCtx); err == nil {
+		err = hookErr
+	}
+
+	if err == nil {
+		s.logger.InfoCtx(ctx, "server exited successfully")
+	}
+	return err
 }
 
-func (s *Server) getLivenessHandler() http.HandlerFunc {
-	dflt := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNoContent)
-	})
+// runShutdownHooks runs every registered hook in reverse registration order
+// and provides the first error encountered.
+func (s *Server) runShutdownHooks(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := make([]func(ctx context.Context) error, len(s.shutdownHooks))
+	copy(hooks, s.shutdownHooks)
+	s.mu.Unlock()
+
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			s.logger.ErrorCtx(ctx, "shutdown hook failed", "error", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
 
-	if s.livenessCheck != nil {
-		return s.livenessCheck(dflt)
+func (s *Server) shutdownDelay() time.Duration {
+	delay := s.config.ShutdownDelaySeconds
+	if delay == nil || *delay < 0 {
+		delay = pointer.IntP(5)
 	}
-	return dflt
+	return time.Duration(*delay) * time.Second
 }
 
-func (s *Server) getReadinessHandler() http.HandlerFunc {
-	defult := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNoContent)
-	})
+func (s *Server) shutdownTimeout() time.Duration {
+	timeout := s.config.ShutdownTimeoutSeconds
+	if timeout == nil || *timeout < 1 {
+		timeout = pointer.IntP(10)
+	}
+	return time.Duration(*timeout) * time.Second
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+func (s *Server) getLivenessHandler() http.HandlerFunc {
+	return probeHandler(s.livenessCheck)
+}
 
-	if s.readinessCheck != nil {
-		return s.readinessCheck(defult)
+func (s *Server) getReadinessHandler() http.HandlerFunc {
+	probe := probeHandler(s.readinessCheck)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.isDraining() {
+			httperr.Write(w, r, httperr.Problem{Status: http.StatusServiceUnavailable, Detail: "server shutting down"})
+			return
+		}
+		probe(w, r)
 	}
-	return defult
 }
 
 func (s *Server) getHealthCheckHandler() http.HandlerFunc {
+	r := s.healthCheck
+	if r == nil {
+		r = health.NewRegistry()
+	}
+	return r.Handler().ServeHTTP
+}
 
-	defult := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("OK!"))
-	})
-
-	if s.healthCheck != nil {
-		return s.healthCheck(defult)
+// probeHandler provides a handler reporting the checks in r. Probes without
+// checks answer with an empty 204 to keep them as cheap as possible.
+func probeHandler(r *health.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r == nil || r.Len() == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		r.Handler().ServeHTTP(w, req)
 	}
-	return defult
 }
 
 // Handler is the interface to handle HTTP requests
@@ -203,22 +378,38 @@ type Router func() Handler
 
 // Config contains options for a Server
 type Config struct {
-	Port                 *int
-	ReadTimeoutMs        *int
-	WriteTimeoutMs       *int
-	RequestTimeoutSec    *int
-	ShutdownDelaySeconds *int
-	SwaggerFile          *string
+	Host                   *string
+	Port                   *int
+	UnixSocket             *string
+	ReadTimeoutMs          *int
+	WriteTimeoutMs         *int
+	RequestTimeoutSec      *int
+	ShutdownDelaySeconds   *int
+	ShutdownTimeoutSeconds *int
+	SwaggerFile            *string
+	SwaggerUIPath          *string
+	TLS                    *TLSConfig
+	Protocol               *ProtocolConfig
+	AccessLog              *AccessLogConfig
+	Timeout                *TimeoutConfig
+	RateLimit              *RateLimitConfig
+	Concurrency            *ConcurrencyConfig
+	CORS                   *CORSConfig
+	Compression            *CompressionConfig
+	OpenAPI                *OpenAPIConfig
+	Validation             *ValidationConfig
 }
 
 // defaultConfig provides a Config initialized with default values
 func defaultConfig() Config {
 	return Config{
-		Port:                 pointer.IntP(8080),
-		ReadTimeoutMs:        pointer.IntP(10000),
-		WriteTimeoutMs:       pointer.IntP(10000),
-		RequestTimeoutSec:    pointer.IntP(10),
-		ShutdownDelaySeconds: pointer.IntP(5),
-		SwaggerFile:          pointer.StringP("/swagger.json"),
+		Port:                   pointer.IntP(8080),
+		ReadTimeoutMs:          pointer.IntP(10000),
+		WriteTimeoutMs:         pointer.IntP(10000),
+		RequestTimeoutSec:      pointer.IntP(10),
+		ShutdownDelaySeconds:   pointer.IntP(5),
+		ShutdownTimeoutSeconds: pointer.IntP(10),
+		SwaggerFile:            pointer.StringP("/swagger.json"),
+		SwaggerUIPath:          pointer.StringP(defaultSwaggerUIPath),
 	}
 }
@@ -1,6 +1,467 @@
 package server
 
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.adenix.dev/adderall/capsules/auth"
+	"go.adenix.dev/adderall/capsules/health"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/router"
+)
+
 // testHandler is used in tests that use reflection to check the type
 type testHandler struct {
 	Handler
 }
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  *metrics.Registry
+		path     string
+		status   int
+		expected []string
+	}{
+		{
+			name:    "Matched",
+			metrics: metrics.NewRegistry(metrics.WithRuntimeCollectors(false)),
+			path:    "/users/42",
+			status:  http.StatusTeapot,
+			expected: []string{
+				`http_server_requests_total{method="GET",route="/users/",status="4xx"} 1`,
+			},
+		},
+		{
+			name:    "Unmatched",
+			metrics: metrics.NewRegistry(metrics.WithRuntimeCollectors(false)),
+			path:    "/unknown",
+			status:  http.StatusNotFound,
+			expected: []string{
+				`http_server_requests_total{method="GET",route="unmatched",status="4xx"} 1`,
+			},
+		},
+		{
+			name:   "Disabled",
+			path:   "/metrics",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := &http.ServeMux{}
+			mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			s := NewFactory(WithRouter(func() Handler { return mux })).Create(WithServerMetrics(test.metrics))
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.status {
+				t.Errorf("expected %d, got %d", test.status, w.Code)
+			}
+
+			if test.metrics == nil {
+				return
+			}
+
+			w = httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			body, _ := io.ReadAll(w.Body)
+			for _, expected := range test.expected {
+				if !strings.Contains(string(body), expected) {
+					t.Errorf("expected %q in %q", expected, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterPattern(t *testing.T) {
+	logger := &recordingLogger{}
+	tracer := mocktracer.New()
+	registry := metrics.NewRegistry(metrics.WithRuntimeCollectors(false))
+	s := NewFactory(
+		WithLogger(logger),
+		WithTracer(tracer),
+		WithMetrics(registry),
+		WithRouter(func() Handler { return router.New() }),
+	).Create()
+
+	var route string
+	s.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(router.Param(r, "id")))
+	}, auth.AuthorizerFunc(func(r *http.Request, pattern string, p *auth.Principal) error {
+		route = pattern
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "42" {
+		t.Errorf("expected 42, got %d %q", w.Code, w.Body.String())
+	}
+	if route != "/users/{id}" {
+		t.Errorf("expected the authorizer to get the route pattern, got %q", route)
+	}
+
+	if spans := tracer.FinishedSpans(); len(spans) != 1 || spans[0].OperationName != "HTTP GET /users/{id}" || spans[0].Tag("http.route") != "/users/{id}" {
+		t.Errorf("expected a span named after the route, got %v", spans)
+	}
+	if logs := accessLogs(logger); len(logs) != 1 || logs[0].field("route") != "/users/{id}" {
+		t.Errorf("expected the route to be logged, got %v", logs)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if expected := `http_server_requests_total{method="GET",route="/users/{id}",status="2xx"} 1`; !strings.Contains(w.Body.String(), expected) {
+		t.Errorf("expected %q in %q", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/42", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// countingRouter counts the route pattern lookups of a ServeMux
+type countingRouter struct {
+	*http.ServeMux
+	lookups int
+}
+
+func (c *countingRouter) Handler(r *http.Request) (http.Handler, string) {
+	c.lookups++
+	return c.ServeMux.Handler(r)
+}
+
+func TestRoutePatternResolvedOnce(t *testing.T) {
+	mux := &countingRouter{ServeMux: http.NewServeMux()}
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {})
+	s := NewFactory(
+		WithLogger(&recordingLogger{}),
+		WithTracer(mocktracer.New()),
+		WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+		WithRouter(func() Handler { return mux }),
+	).Create(WithServerRouteTimeout("/users/", 5))
+
+	mux.lookups = 0
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if mux.lookups != 1 {
+		t.Errorf("expected 1 lookup, got %d", mux.lookups)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	failing := health.NewChecker("db", func(context.Context) error { return errors.New("down") })
+	slow := health.NewChecker("cache", func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		path     string
+		status   int
+		expected string
+	}{
+		{name: "Live", path: "/live", status: http.StatusNoContent},
+		{name: "Ready", path: "/ready", status: http.StatusNoContent},
+		{name: "Health", path: "/health", status: http.StatusOK, expected: `"status":"ok"`},
+		{
+			name:     "ReadyFailing",
+			opts:     []Option{WithReadinessCheck(failing)},
+			path:     "/ready",
+			status:   http.StatusServiceUnavailable,
+			expected: `"error":"down"`,
+		},
+		{
+			name:     "HealthDegraded",
+			opts:     []Option{WithHealthCheck(failing, health.WithCritical(false))},
+			path:     "/health",
+			status:   http.StatusOK,
+			expected: `"status":"degraded"`,
+		},
+		{
+			name:     "LiveFailing",
+			opts:     []Option{WithLivenessCheck(failing)},
+			path:     "/live",
+			status:   http.StatusServiceUnavailable,
+			expected: `"status":"fail"`,
+		},
+		{
+			name:     "HealthOptions",
+			opts:     []Option{WithReadinessCheck(slow), WithHealthOptions(health.WithTimeout(10 * time.Millisecond))},
+			path:     "/ready",
+			status:   http.StatusServiceUnavailable,
+			expected: `"error":"health check timed out"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewFactory().Create(test.opts...)
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.status {
+				t.Errorf("expected %d, got %d", test.status, w.Code)
+			}
+			if body := w.Body.String(); !strings.Contains(body, test.expected) {
+				t.Errorf("expected %q in %q", test.expected, body)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		hooks    []error
+		expected error
+	}{
+		{
+			name: "NoHooks",
+		},
+		{
+			name:  "Hooks",
+			hooks: []error{nil, nil, nil},
+		},
+		{
+			name:     "HookError",
+			hooks:    []error{errors.New("first"), nil, errors.New("last")},
+			expected: errors.New("last"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var order []int
+			opts := []Option{WithShutdownDelaySeconds(0)}
+			for i, err := range test.hooks {
+				i, err := i, err
+				opts = append(opts, WithOnShutdown(func(context.Context) error {
+					order = append(order, i)
+					return err
+				}))
+			}
+			s := NewFactory().Create(opts...)
+
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+
+			if status := get(t, ts.URL+"/ready"); status != http.StatusNoContent {
+				t.Errorf("expected %d, got %d", http.StatusNoContent, status)
+			}
+
+			err := s.shutdown(context.Background(), ts.Config)
+			if (err == nil) != (test.expected == nil) || (err != nil && err.Error() != test.expected.Error()) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+
+			for i := range order {
+				if expected := len(test.hooks) - 1 - i; order[i] != expected {
+					t.Errorf("expected hook %d at position %d, got %d", expected, i, order[i])
+				}
+			}
+			if len(order) != len(test.hooks) {
+				t.Errorf("expected %d hooks run, got %d", len(test.hooks), len(order))
+			}
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+			}
+		})
+	}
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	mux := &http.ServeMux{}
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	s := NewFactory(WithRouter(func() Handler { return mux })).Create(WithShutdownDelaySeconds(0))
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	status := make(chan int, 1)
+	go func() {
+		status <- get(t, ts.URL+"/slow")
+	}()
+	<-started
+
+	if err := s.shutdown(context.Background(), ts.Config); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if actual := <-status; actual != http.StatusAccepted {
+		t.Errorf("expected %d, got %d", http.StatusAccepted, actual)
+	}
+}
+
+func TestShutdownCanceled(t *testing.T) {
+	s := NewFactory().Create(WithShutdownDelaySeconds(60))
+	atomic.StoreInt32(&s.started, 1)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := s.shutdown(ctx, ts.Config); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the drain to be interrupted, took %s", elapsed)
+	}
+}
+
+func TestStopAfterFailedStart(t *testing.T) {
+	s := NewFactory().Create(
+		WithShutdownDelaySeconds(60),
+		WithServerUnixSocket(filepath.Join(t.TempDir(), "missing", "server.sock")),
+	)
+
+	if err := s.Start(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+
+	start := time.Now()
+	if err := s.Stop(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected no drain delay, took %s", elapsed)
+	}
+}
+
+func get(t *testing.T, url string) int {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return 0
+	}
+	_ = res.Body.Close()
+	return res.StatusCode
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	s := NewFactory().Create(WithShutdownDelaySeconds(0))
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(context.Background())
+	}()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected Start to return once stopped")
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	s := NewFactory().Create(WithShutdownDelaySeconds(0))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ServeListener(ctx, l)
+	}()
+
+	if status := get(t, "http://"+l.Addr().String()+"/live"); status != http.StatusNoContent {
+		t.Errorf("expected %d, got %d", http.StatusNoContent, status)
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Serve to return once the context is cancelled")
+	}
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	s := NewFactory().Create(WithShutdownDelaySeconds(0), WithServerUnixSocket(path))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Serve(ctx)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	var res *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if res, err = client.Get("http://unix/ready"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected %d, got %d", http.StatusNoContent, res.StatusCode)
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "server.sock")
+	s := NewFactory().Create(WithServerUnixSocket(path))
+
+	if err := s.Serve(context.Background()); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	httpSwagger "github.com/swaggo/http-swagger"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+const defaultSwaggerUIPath = "/swagger/"
+
+// swaggerOptions are the file system the spec, and optionally the UI, of a
+// Server are served from
+type swaggerOptions struct {
+	fsys fs.FS
+	name string
+	ui   fs.FS
+}
+
+// addSwagger configures and adds handers for an OpenAPI document and the
+// Swagger UI. The document generated from the routes of the Server is served
+// when configured, the spec of WithSwaggerFS or the SwaggerFile otherwise.
+func (s *Server) addSwagger(r Handler) {
+	if path, ok := s.openAPIPath(); ok {
+		r.HandleFunc(path, s.OpenAPI().Handler().ServeHTTP)
+		s.addSwaggerUI(r, path)
+		return
+	}
+
+	fsys, name, url := s.swaggerSource()
+	if _, err := fs.Stat(fsys, name); err != nil {
+		s.logger.InfoCtx(context.Background(), "swagger not added", "location", url, "error", err)
+		return
+	}
+
+	r.HandleFunc(url, specHandler(fsys, name))
+	s.addSwaggerUI(r, url)
+}
+
+// swaggerSource provides the file system and name of the spec of the Server,
+// and the path it is served at
+func (s *Server) swaggerSource() (fs.FS, string, string) {
+	if s.swagger.fsys != nil {
+		name := strings.TrimPrefix(path.Clean("/"+s.swagger.name), "/")
+		return s.swagger.fsys, name, "/" + name
+	}
+	location := s.swaggerFile()
+	return os.DirFS(filepath.Dir(location)), filepath.Base(location), location
+}
+
+// swaggerFile provides the location of the SwaggerFile
+func (s *Server) swaggerFile() string {
+	if s.config.SwaggerFile != nil && len(*s.config.SwaggerFile) > 0 {
+		return *s.config.SwaggerFile
+	}
+	return "/swagger.json"
+}
+
+// swaggerUIPath provides the path the Swagger UI is mounted at, ending with a
+// slash, and whether the UI is served at all
+func (s *Server) swaggerUIPath() (string, bool) {
+	if s.config.SwaggerUIPath == nil {
+		return defaultSwaggerUIPath, true
+	}
+	if *s.config.SwaggerUIPath == "" {
+		return "", false
+	}
+	return strings.TrimSuffix(*s.config.SwaggerUIPath, "/") + "/", true
+}
+
+// swaggerPaths provides the paths of the OpenAPI document and the Swagger UI
+func (s *Server) swaggerPaths() []string {
+	var paths []string
+	if path, ok := s.openAPIPath(); ok {
+		paths = append(paths, path)
+	} else {
+		_, _, url := s.swaggerSource()
+		paths = append(paths, url)
+	}
+	if ui, ok := s.swaggerUIPath(); ok {
+		paths = append(paths, ui)
+		if ui != "/" {
+			paths = append(paths, strings.TrimSuffix(ui, "/"))
+		}
+	}
+	return paths
+}
+
+// addSwaggerUI adds handlers for the Swagger UI of the document at url, the
+// UI of WithSwaggerUIFS replacing the bundled one
+func (s *Server) addSwaggerUI(r Handler, url string) {
+	ui, ok := s.swaggerUIPath()
+	if !ok {
+		return
+	}
+
+	var swaggerUIHandler http.HandlerFunc
+	if s.swagger.ui != nil {
+		swaggerUIHandler = http.StripPrefix(ui, http.FileServer(http.FS(s.swagger.ui))).ServeHTTP
+	} else {
+		swaggerUIHandler = httpSwagger.Handler(
+			httpSwagger.URL(url),
+		)
+	}
+
+	if ui != "/" {
+		r.HandleFunc(strings.TrimSuffix(ui, "/"), func(rw http.ResponseWriter, r *http.Request) {
+			http.Redirect(rw, r, ui, http.StatusMovedPermanently)
+		})
+	}
+	r.HandleFunc(ui, swaggerUIHandler)
+	r.HandleFunc(ui+"*", swaggerUIHandler)
+}
+
+// specHandler serves the spec name of fsys. Responses carry an ETag of the
+// content, and a Last-Modified time when fsys provides one, so clients can
+// revalidate their cached copy. YAML specs are served as application/yaml.
+func specHandler(fsys fs.FS, name string) http.HandlerFunc {
+	contentType := "application/json"
+	if ext := path.Ext(name); ext == ".yaml" || ext == ".yml" {
+		contentType = "application/yaml"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			httperr.WriteError(w, r, httperr.NotFound("spec not found"))
+			return
+		}
+		var modTime time.Time
+		if info, err := fs.Stat(fsys, name); err == nil {
+			modTime = info.ModTime()
+		}
+
+		sum := sha256.Sum256(b)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, name, modTime, bytes.NewReader(b))
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.adenix.dev/adderall/capsules/metrics"
+)
+
+const testSwaggerYAML = `swagger: "2.0"
+info:
+  title: users
+  version: 1.0.0
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: integer
+      responses:
+        "200":
+          description: OK
+`
+
+func TestSwagger(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swagger.json")
+	if err := os.WriteFile(file, []byte(`{"swagger":"2.0"}`), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fsys := fstest.MapFS{
+		"api/openapi.yaml": {Data: []byte(testSwaggerYAML)},
+		"swagger.json":     {Data: []byte(`{"swagger":"2.0"}`)},
+	}
+	ui := fstest.MapFS{"index.html": {Data: []byte("custom ui")}}
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		target      string
+		header      http.Header
+		status      int
+		contentType string
+		body        string
+		location    string
+	}{
+		{
+			name:        "FS",
+			opts:        []Option{WithSwaggerFS(fsys, "swagger.json")},
+			target:      "/swagger.json",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"swagger":"2.0"}`,
+		},
+		{
+			name:        "FSYAML",
+			opts:        []Option{WithSwaggerFS(fsys, "/api/openapi.yaml")},
+			target:      "/api/openapi.yaml",
+			status:      http.StatusOK,
+			contentType: "application/yaml",
+			body:        testSwaggerYAML,
+		},
+		{
+			name:   "FSMissing",
+			opts:   []Option{WithSwaggerFS(fsys, "missing.json")},
+			target: "/swagger/index.html",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "ETag",
+			opts:   []Option{WithSwaggerFS(fsys, "swagger.json")},
+			target: "/swagger.json",
+			header: http.Header{"If-None-Match": []string{etag(t, fsys, "swagger.json")}},
+			status: http.StatusNotModified,
+		},
+		{
+			name:   "LastModified",
+			opts:   []Option{WithSwaggerFile(file)},
+			target: file,
+			header: http.Header{"If-Modified-Since": []string{lastModified(t, file)}},
+			status: http.StatusNotModified,
+		},
+		{
+			name:   "UI",
+			opts:   []Option{WithSwaggerFile(file)},
+			target: "/swagger/index.html",
+			status: http.StatusOK,
+		},
+		{
+			name:     "UIPathRedirect",
+			opts:     []Option{WithSwaggerFile(file), WithSwaggerUIPath("/docs")},
+			target:   "/docs",
+			status:   http.StatusMovedPermanently,
+			location: "/docs/",
+		},
+		{
+			name:   "UIPath",
+			opts:   []Option{WithSwaggerFile(file), WithSwaggerUIPath("/docs")},
+			target: "/docs/index.html",
+			status: http.StatusOK,
+		},
+		{
+			name:   "UIPathMoved",
+			opts:   []Option{WithSwaggerFile(file), WithSwaggerUIPath("/docs/")},
+			target: "/swagger/index.html",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "UIDisabled",
+			opts:   []Option{WithSwaggerFile(file), WithSwaggerUIPath("")},
+			target: "/swagger/index.html",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "UIFS",
+			opts:   []Option{WithSwaggerFS(fsys, "swagger.json"), WithSwaggerUIFS(ui)},
+			target: "/swagger/",
+			status: http.StatusOK,
+			body:   "custom ui",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewFactory(
+				WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+			).Create(test.opts...)
+
+			r := httptest.NewRequest(http.MethodGet, test.target, nil)
+			for key, values := range test.header {
+				r.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %d, got %d", test.status, w.Code)
+			}
+			if test.contentType != "" && w.Header().Get("Content-Type") != test.contentType {
+				t.Errorf("expected content type %q, got %q", test.contentType, w.Header().Get("Content-Type"))
+			}
+			if test.body != "" && w.Body.String() != test.body {
+				t.Errorf("expected body %q, got %q", test.body, w.Body.String())
+			}
+			if test.location != "" && w.Header().Get("Location") != test.location {
+				t.Errorf("expected location %q, got %q", test.location, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestSwaggerFSValidation(t *testing.T) {
+	fsys := fstest.MapFS{"openapi.yaml": {Data: []byte(testSwaggerYAML)}}
+	s := NewFactory(
+		WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+	).Create(WithSwaggerFS(fsys, "openapi.yaml"), WithServerValidation(ValidationConfig{}))
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/alice", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"name":"id"`) {
+		t.Errorf("expected the id to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// etag provides the ETag served for the file name of fsys
+func etag(t *testing.T, fsys fstest.MapFS, name string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	specHandler(fsys, name)(w, httptest.NewRequest(http.MethodGet, "/"+name, nil))
+	if w.Header().Get("ETag") == "" {
+		t.Fatal("expected an ETag")
+	}
+	return w.Header().Get("ETag")
+}
+
+// lastModified provides the Last-Modified time served for the file at path
+func lastModified(t *testing.T, path string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	specHandler(os.DirFS(filepath.Dir(path)), filepath.Base(path))(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Header().Get("Last-Modified") == "" {
+		t.Fatal("expected a Last-Modified time")
+	}
+	return w.Header().Get("Last-Modified")
+}
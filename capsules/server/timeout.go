@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"go.adenix.dev/adderall/capsules/httperr"
+)
+
+// TimeoutConfig contains options for request timeouts
+type TimeoutConfig struct {
+	// Routes overrides RequestTimeoutSec for route patterns. A timeout of zero
+	// or less disables the timeout of the route.
+	Routes map[string]int
+	// ContentType is the content type of the timeout response
+	ContentType string
+	// Body is the body of the timeout response
+	Body string
+}
+
+// timeoutConfig provides the TimeoutConfig of the Server, creating it when
+// timeouts have not been configured yet.
+func (s *Server) timeoutConfig() *TimeoutConfig {
+	c := TimeoutConfig{}
+	if s.config.Timeout != nil {
+		c = *s.config.Timeout
+	}
+	routes := make(map[string]int, len(c.Routes))
+	for pattern, sec := range c.Routes {
+		routes[pattern] = sec
+	}
+	c.Routes = routes
+	s.config.Timeout = &c
+	return s.config.Timeout
+}
+
+// requestTimeout provides the timeout of the route matched by r
+func (s *Server) requestTimeout(r *http.Request) time.Duration {
+	sec := 0
+	if s.config.RequestTimeoutSec != nil {
+		sec = *s.config.RequestTimeoutSec
+	}
+	if s.config.Timeout != nil && len(s.config.Timeout.Routes) > 0 {
+		if routeSec, ok := s.config.Timeout.Routes[s.routePattern(r)]; ok {
+			sec = routeSec
+		}
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// TimeoutMiddleware bounds every request by the request timeout of its route.
+// The request context is cancelled when the timeout elapses and the timeout
+// response, a 503 problem by default, is written in place of anything the
+// handler wrote. Responses are buffered until the handler flushes them, after
+// which they are streamed and a timeout cuts them short instead. Timed out
+// requests are marked on the request span and in the access log.
+func (s *Server) TimeoutMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		c := TimeoutConfig{}
+		if s.config.Timeout != nil {
+			c = *s.config.Timeout
+		}
+		return &timeoutHandler{s: s, next: next, contentType: c.ContentType, body: c.Body}
+	}
+}
+
+// timeoutHandler is a http.TimeoutHandler with a per route timeout, which
+// reports timeouts and preserves the stack of handler panics.
+type timeoutHandler struct {
+	s           *Server
+	next        http.Handler
+	contentType string
+	body        string
+}
+
+func (h *timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := h.s.requestTimeout(r)
+	if timeout <= 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	parent := r.Context()
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	timedOut := make(chan struct{})
+	r = r.WithContext(&timeoutContext{Context: ctx, deadline: time.Now().Add(timeout), timedOut: timedOut})
+
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	// the handler sees and can amend headers set by outer middleware, such as
+	// Vary
+	tw := &timeoutWriter{w: w, h: w.Header().Clone()}
+
+	// writes are failed before the handler sees its context cancelled, so a
+	// flushed response is reliably cut short
+	timer := time.AfterFunc(timeout, func() {
+		tw.mu.Lock()
+		if tw.err == nil {
+			tw.err = http.ErrHandlerTimeout
+		}
+		tw.mu.Unlock()
+		close(timedOut)
+		cancel()
+	})
+	defer timer.Stop()
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				if p != http.ErrAbortHandler {
+					p = handlerPanic{value: p, stack: debug.Stack()}
+				}
+				panicChan <- p
+			}
+		}()
+		h.next.ServeHTTP(tw, r)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		if !tw.committed {
+			tw.commitLocked()
+		}
+	case <-parent.Done():
+		// the client went away
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.err = parent.Err()
+		if !tw.committed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	case <-timedOut:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		markTimedOut(r.Context())
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			ext.Error.Set(span, true)
+			span.SetTag("timeout", true)
+			span.LogFields(log.String("event", "timeout"), log.String("timeout", timeout.String()))
+		}
+		if tw.committed {
+			// the response was flushed, it can only be cut short
+			return
+		}
+
+		if h.contentType == "" && h.body == "" {
+			httperr.Write(w, r, httperr.Problem{Status: http.StatusServiceUnavailable, Detail: "request timed out"})
+			return
+		}
+		if h.contentType != "" {
+			w.Header().Set("Content-Type", h.contentType)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(h.body))
+	}
+}
+
+// timeoutWriter buffers the response of a handler until it completes or is
+// flushed, failing writes once the request has timed out.
+type timeoutWriter struct {
+	w   http.ResponseWriter
+	h   http.Header
+	buf bytes.Buffer
+
+	mu          sync.Mutex
+	err         error
+	wroteHeader bool
+	code        int
+	// committed is set once the header and buffered body were sent, later
+	// writes going straight to w
+	committed bool
+}
+
+var _ http.Flusher = (*timeoutWriter)(nil)
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.err != nil {
+		return 0, tw.err
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	if tw.committed {
+		return tw.w.Write(p)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.err != nil || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
+
+// Flush sends the header and the buffered body to the client, streaming the
+// rest of the response.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.err != nil {
+		return
+	}
+	if !tw.committed {
+		tw.commitLocked()
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// commitLocked sends the header and the buffered body to the client
+func (tw *timeoutWriter) commitLocked() {
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.h[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, vv := range tw.h {
+		dst[k] = vv
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	tw.committed = true
+	tw.w.WriteHeader(tw.code)
+	_, _ = tw.w.Write(tw.buf.Bytes())
+	tw.buf.Reset()
+}
+
+// timeoutContext is the context of a request bounded by the
+// TimeoutMiddleware, which is cancelled only once writes are failed and then
+// reports its deadline as exceeded, as a context.WithTimeout would.
+type timeoutContext struct {
+	context.Context
+	deadline time.Time
+	timedOut <-chan struct{}
+}
+
+func (c *timeoutContext) Deadline() (time.Time, bool) {
+	if deadline, ok := c.Context.Deadline(); ok && deadline.Before(c.deadline) {
+		return deadline, true
+	}
+	return c.deadline, true
+}
+
+func (c *timeoutContext) Err() error {
+	select {
+	case <-c.timedOut:
+		return context.DeadlineExceeded
+	default:
+		return c.Context.Err()
+	}
+}
+
+// handlerPanic carries a panic raised by a handler running under the timeout,
+// along with the stack of the handler goroutine, to the request goroutine.
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p handlerPanic) String() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+type requestInfoKey struct{}
+
+// requestInfo records what happened to a request for the AccessLogMiddleware
+type requestInfo struct {
+	timedOut bool
+	subject  string
+}
+
+// withRequestInfo provides a copy of r carrying a requestInfo
+func withRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	info := &requestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+func markTimedOut(ctx context.Context) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.timedOut = true
+	}
+}
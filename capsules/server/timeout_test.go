@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/internal/pointer"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		status      int
+		contentType string
+		body        string
+		timedOut    bool
+	}{
+		{
+			name:        "Default",
+			status:      http.StatusServiceUnavailable,
+			contentType: httperr.ContentType,
+			body:        `"detail":"request timed out"`,
+			timedOut:    true,
+		},
+		{
+			name:        "Response",
+			opts:        []Option{WithServerTimeoutResponse("text/plain", "late")},
+			status:      http.StatusServiceUnavailable,
+			contentType: "text/plain",
+			body:        "late",
+			timedOut:    true,
+		},
+		{
+			name:   "RouteDisabled",
+			opts:   []Option{WithServerRouteTimeout("/slow", 0)},
+			status: http.StatusOK,
+			body:   "done",
+		},
+		{
+			name:   "RouteOverride",
+			opts:   []Option{WithServerRouteTimeout("/slow", 5)},
+			status: http.StatusOK,
+			body:   "done",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cancelled := make(chan error, 1)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					cancelled <- r.Context().Err()
+				case <-time.After(1100 * time.Millisecond):
+					_, _ = w.Write([]byte("done"))
+				}
+			})
+
+			logger := &recordingLogger{}
+			tracer := mocktracer.New()
+			opts := append([]Option{
+				WithServerRouter(mux),
+				WithServerConfig(Config{RequestTimeoutSec: pointer.IntP(1)}),
+			}, test.opts...)
+			s := NewFactory(WithLogger(logger), WithTracer(tracer)).Create(opts...)
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+			if rr.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); test.contentType != "" && ct != test.contentType {
+				t.Errorf("expected content type %q, got %q", test.contentType, ct)
+			}
+			if !strings.Contains(rr.Body.String(), test.body) {
+				t.Errorf("expected body %q in %q", test.body, rr.Body.String())
+			}
+
+			if test.timedOut {
+				select {
+				case err := <-cancelled:
+					if err != context.DeadlineExceeded {
+						t.Errorf("expected request context to exceed its deadline, got %v", err)
+					}
+				case <-time.After(time.Second):
+					t.Error("expected request context to be cancelled")
+				}
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 finished span, got %d", len(spans))
+			}
+			if timedOut := spans[0].Tag("timeout") == true; timedOut != test.timedOut {
+				t.Errorf("expected span timeout tag to be %t, got %v", test.timedOut, spans[0].Tag("timeout"))
+			}
+
+			logs := accessLogs(logger)
+			if len(logs) != 1 {
+				t.Fatalf("expected 1 access log, got %v", logger.logs)
+			}
+			if timedOut := logs[0].field("timeout") == true; timedOut != test.timedOut {
+				t.Errorf("expected access log timeout to be %t, got %v", test.timedOut, logs[0].fields)
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddlewareHeaders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "foo")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+	s := NewFactory().Create(WithServerRouter(mux))
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if rr.Code != http.StatusCreated || rr.Body.String() != "created" || rr.Header().Get("X-Test") != "foo" {
+		t.Errorf("expected the buffered response to be written, got %d %q %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+}
+
+func TestTimeoutMiddlewareFlush(t *testing.T) {
+	proceed := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-proceed
+		_, _ = w.Write([]byte("second\n"))
+	})
+	mux.HandleFunc("/stalled", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		_, _ = w.Write([]byte("late\n"))
+	})
+	s := NewFactory().Create(WithServerRouter(mux), WithServerConfig(Config{RequestTimeoutSec: pointer.IntP(1)}))
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// flushed data reaches the client before the handler completes
+	res, err := http.Get(ts.URL + "/stream")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	close(proceed)
+	if err != nil || line != "first\n" {
+		t.Errorf("expected the first line to be streamed, got %q, %v", line, err)
+	}
+	_ = res.Body.Close()
+
+	// a timeout after a flush cuts the response short
+	res, err = http.Get(ts.URL + "/stalled")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != "partial\n" {
+		t.Errorf("expected the flushed response to be cut short, got %d %q", res.StatusCode, body)
+	}
+}
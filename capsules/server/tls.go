@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientAuth is the policy for verifying client certificates
+type ClientAuth string
+
+const (
+	// ClientAuthNone does not request client certificates
+	ClientAuthNone ClientAuth = "none"
+	// ClientAuthOptional verifies client certificates when they are sent
+	ClientAuthOptional ClientAuth = "optional"
+	// ClientAuthRequired rejects connections without a verified client
+	// certificate
+	ClientAuthRequired ClientAuth = "required"
+)
+
+// TLSConfig contains options for serving TLS and mutual TLS
+type TLSConfig struct {
+	CertFile          string
+	KeyFile           string
+	ClientCAFile      string
+	ClientAuth        ClientAuth
+	MinVersion        string
+	CipherSuites      []string
+	ReloadIntervalSec int
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig provides the TLSConfig of the Server, creating it when TLS has not
+// been configured yet.
+func (s *Server) tlsConfig() *TLSConfig {
+	if s.config.TLS == nil {
+		s.config.TLS = &TLSConfig{}
+	} else {
+		c := *s.config.TLS
+		s.config.TLS = &c
+	}
+	return s.config.TLS
+}
+
+type clientCertificateKey struct{}
+
+// ClientCertificate provides the verified client certificate of a mutual TLS
+// request.
+func ClientCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(clientCertificateKey{}).(*x509.Certificate)
+	return cert, ok
+}
+
+// ClientCertificateMiddleware exposes the verified client certificate of mutual
+// TLS requests through ClientCertificate.
+func (s *Server) ClientCertificateMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+				ctx := context.WithValue(r.Context(), clientCertificateKey{}, r.TLS.VerifiedChains[0][0])
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// certReloader holds the current tls.Config and rebuilds it when the
+// certificate, key, or client CA files change.
+type certReloader struct {
+	config     TLSConfig
+	nextProtos []string
+	logger     Logger
+
+	mu      sync.RWMutex
+	current *tls.Config
+	modTime time.Time
+}
+
+func newCertReloader(c TLSConfig, nextProtos []string, l Logger) (*certReloader, error) {
+	r := &certReloader{config: c, nextProtos: nextProtos, logger: l}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig provides the tls.Config handed to the http.Server. Every handshake
+// is served with the most recently loaded configuration.
+func (r *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.get().MinVersion,
+		NextProtos: r.nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.get().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.get(), nil
+		},
+	}
+}
+
+func (r *certReloader) get() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+func (r *certReloader) reload() error {
+	modTime := r.latestModTime()
+
+	c, err := buildTLSConfig(r.config)
+	if err != nil {
+		return err
+	}
+	c.NextProtos = r.nextProtos
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = c
+	r.modTime = modTime
+	return nil
+}
+
+// watch polls the configured files and reloads them when they change until
+// ctx is cancelled.
+func (r *certReloader) watch(ctx context.Context) {
+	interval := time.Duration(r.config.ReloadIntervalSec) * time.Second
+	if r.config.ReloadIntervalSec == 0 {
+		interval = 30 * time.Second
+	}
+	if interval < 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reloadIfChanged(ctx)
+		}
+	}
+}
+
+func (r *certReloader) reloadIfChanged(ctx context.Context) {
+	r.mu.RLock()
+	modTime := r.modTime
+	r.mu.RUnlock()
+
+	if !r.latestModTime().After(modTime) {
+		return
+	}
+
+	if err := r.reload(); err != nil {
+		r.logger.ErrorCtx(ctx, "failed to reload tls certificates", "error", err)
+		return
+	}
+	r.logger.InfoCtx(ctx, "tls certificates reloaded", "cert", r.config.CertFile)
+}
+
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// buildTLSConfig loads the files referenced by c into a tls.Config
+func buildTLSConfig(c TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tls requires both a certificate and key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading tls key pair: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version %q", c.MinVersion)
+		}
+		config.MinVersion = v
+	}
+
+	if len(c.CipherSuites) > 0 {
+		ids, err := cipherSuites(c.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = ids
+	}
+
+	switch c.ClientAuth {
+	case ClientAuthNone:
+		config.ClientAuth = tls.NoClientCert
+	case ClientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequired:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case "":
+		if c.ClientCAFile != "" {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	default:
+		return nil, fmt.Errorf("unknown client auth %q", c.ClientAuth)
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	} else if config.ClientAuth != tls.NoClientCert {
+		return nil, errors.New("client certificate verification requires a client ca file")
+	}
+
+	return config, nil
+}
+
+// cipherSuites maps cipher suite names to their ids. Only suites considered
+// secure by crypto/tls are accepted.
+func cipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
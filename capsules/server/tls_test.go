@@ -0,0 +1,300 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI is a throwaway certificate authority with server and client
+// certificates written to a temporary directory
+type testPKI struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+	pool   *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+
+	p := &testPKI{dir: t.TempDir(), ca: ca, caKey: key, pool: x509.NewCertPool()}
+	p.pool.AddCert(ca)
+	p.caFile = p.write(t, "ca.pem", "CERTIFICATE", der)
+	return p
+}
+
+// issue writes a certificate and key signed by the CA and provides their paths
+func (p *testPKI) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return p.write(t, name+".pem", "CERTIFICATE", der), p.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func (p *testPKI) write(t *testing.T, name, block string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: block, Bytes: der}), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return path
+}
+
+func TestServeTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		clientCert bool
+		err        bool
+		expected   string
+	}{
+		{
+			name:     "TLS",
+			opts:     []Option{WithServerTLS(certFile, keyFile)},
+			expected: "anonymous",
+		},
+		{
+			name:       "MutualTLS",
+			opts:       []Option{WithServerTLS(certFile, keyFile), WithServerClientCA(pki.caFile, ClientAuthRequired)},
+			clientCert: true,
+			expected:   "client",
+		},
+		{
+			name: "MutualTLS-MissingClientCert",
+			opts: []Option{WithServerTLS(certFile, keyFile), WithServerClientCA(pki.caFile, ClientAuthRequired)},
+			err:  true,
+		},
+		{
+			name:     "MutualTLS-Optional",
+			opts:     []Option{WithServerTLS(certFile, keyFile), WithServerClientCA(pki.caFile, ClientAuthOptional)},
+			expected: "anonymous",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := &http.ServeMux{}
+			mux.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+				name := "anonymous"
+				if cert, ok := ClientCertificate(r.Context()); ok {
+					name = cert.Subject.CommonName
+				}
+				_, _ = fmt.Fprint(w, name)
+			})
+			opts := append([]Option{WithShutdownDelaySeconds(0)}, test.opts...)
+			s := NewFactory(WithRouter(func() Handler { return mux })).Create(opts...)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				_ = s.ServeListener(ctx, l)
+			}()
+
+			config := &tls.Config{RootCAs: pki.pool}
+			if test.clientCert {
+				cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				config.Certificates = []tls.Certificate{cert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+			res, err := client.Get("https://" + l.Addr().String() + "/whoami")
+			if test.err {
+				if err == nil {
+					_ = res.Body.Close()
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer func() {
+				_ = res.Body.Close()
+			}()
+
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, body)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+
+	r, err := newCertReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile}, nil, NoopLogger{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	before := r.get().Certificates[0].Certificate[0]
+
+	r.reloadIfChanged(context.Background())
+	if string(r.get().Certificates[0].Certificate[0]) != string(before) {
+		t.Error("expected certificate not to be reloaded when files are unchanged")
+	}
+
+	pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	r.reloadIfChanged(context.Background())
+	if string(r.get().Certificates[0].Certificate[0]) == string(before) {
+		t.Error("expected rotated certificate to be reloaded")
+	}
+
+	cert, err := r.TLSConfig().GetCertificate(nil)
+	if err != nil || string(cert.Certificate[0]) == string(before) {
+		t.Errorf("expected GetCertificate to serve the rotated certificate, got %v", err)
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+
+	tests := []struct {
+		name   string
+		config TLSConfig
+		err    bool
+		assert func(t *testing.T, c *tls.Config)
+	}{
+		{name: "MissingFiles", config: TLSConfig{}, err: true},
+		{name: "BadKeyPair", config: TLSConfig{CertFile: certFile, KeyFile: certFile}, err: true},
+		{name: "UnknownVersion", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "2.0"}, err: true},
+		{name: "UnknownCipher", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, err: true},
+		{name: "UnknownClientAuth", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"}, err: true},
+		{name: "ClientAuthWithoutCA", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequired}, err: true},
+		{name: "BadCA", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, err: true},
+		{
+			name:   "Defaults",
+			config: TLSConfig{CertFile: certFile, KeyFile: keyFile},
+			assert: func(t *testing.T, c *tls.Config) {
+				if c.MinVersion != tls.VersionTLS12 || c.ClientAuth != tls.NoClientCert {
+					t.Errorf("unexpected defaults: %x %v", c.MinVersion, c.ClientAuth)
+				}
+			},
+		},
+		{
+			name: "Policy",
+			config: TLSConfig{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				ClientCAFile: pki.caFile,
+				MinVersion:   "1.3",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			},
+			assert: func(t *testing.T, c *tls.Config) {
+				if c.MinVersion != tls.VersionTLS13 {
+					t.Errorf("expected %x, got %x", tls.VersionTLS13, c.MinVersion)
+				}
+				if len(c.CipherSuites) != 1 || c.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+					t.Errorf("unexpected cipher suites %v", c.CipherSuites)
+				}
+				if c.ClientAuth != tls.RequireAndVerifyClientCert {
+					t.Errorf("expected %v, got %v", tls.RequireAndVerifyClientCert, c.ClientAuth)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := buildTLSConfig(test.config)
+			if test.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			test.assert(t, c)
+		})
+	}
+}
+
+func TestServeTLSError(t *testing.T) {
+	s := NewFactory().Create(WithServerTLS("missing.pem", "missing-key.pem"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := s.ServeListener(context.Background(), l); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"go.adenix.dev/adderall/capsules/codec"
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/openapi"
+)
+
+// ValidationConfig contains options for validating requests, and optionally
+// responses, against the OpenAPI document of the Server. The document
+// generated from the routes of the Server is used when configured, see
+// WithServerOpenAPI, the spec of WithSwaggerFS or the SwaggerFile otherwise.
+type ValidationConfig struct {
+	// Responses enables validating responses and logging contract violations.
+	// Response bodies are buffered to be validated, so it is meant for
+	// non-production environments.
+	// Defaults to false
+	Responses bool
+}
+
+// validationState holds the openapi.Validator of the spec of a Server
+type validationState struct {
+	once      sync.Once
+	validator *openapi.Validator
+}
+
+// validator provides the openapi.Validator of the document of the Server, nil
+// when the spec cannot be loaded
+func (s *Server) validator() *openapi.Validator {
+	if _, ok := s.openAPIPath(); ok {
+		return s.OpenAPI().Validator()
+	}
+
+	s.validation.once.Do(func() {
+		fsys, name, _ := s.swaggerSource()
+		doc, err := openapi.LoadFS(fsys, name)
+		if err != nil {
+			s.logger.ErrorCtx(context.Background(), "openapi validation disabled", "error", err)
+			return
+		}
+		s.validation.validator = openapi.NewValidator(doc)
+	})
+	return s.validation.validator
+}
+
+// ValidationMiddleware validates requests against the OpenAPI document of the
+// Server, rejecting invalid requests with a 400 listing the invalid
+// parameters and body fields. Responses are validated too when configured,
+// violations being logged at warn level. It is a noop unless configured, see
+// WithServerValidation.
+func (s *Server) ValidationMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.config.Validation == nil {
+			return next
+		}
+		responses := s.config.Validation.Responses
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			v := s.validator()
+			if v == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := v.ValidateRequest(r); err != nil {
+				s.logger.DebugCtx(r.Context(), "request failed validation",
+					"error", err.Error(),
+					"method", r.Method,
+					"path", r.URL.EscapedPath(),
+					"invalid_params", invalidParams(err),
+				)
+				httperr.WriteError(w, r, err)
+				return
+			}
+
+			if !responses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			vw := &validationWriter{ResponseWriter: w}
+			next.ServeHTTP(vw, r)
+			if vw.truncated {
+				return
+			}
+			if err := v.ValidateResponse(r, vw.Status(), w.Header(), vw.body.Bytes()); err != nil {
+				s.logger.WarnCtx(r.Context(), "response violates the api specification",
+					"method", r.Method,
+					"path", r.URL.EscapedPath(),
+					"route", s.routePattern(r),
+					"status", vw.Status(),
+					"invalid_params", invalidParams(err),
+				)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func invalidParams(err error) []codec.InvalidParam {
+	var e *httperr.Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+	params, _ := e.Extensions["invalid_params"].([]codec.InvalidParam)
+	return params
+}
+
+// validationWriter wraps a http.ResponseWriter to record the status code and
+// body of a response for validation. Bodies larger than codec.DefaultMaxBytes
+// are not recorded.
+type validationWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+var _ http.Flusher = (*validationWriter)(nil)
+
+// Status provides the status code written, defaulting to 200.
+func (w *validationWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// WriteHeader records the status code and sends it to the wrapped writer.
+func (w *validationWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body and sends it to the wrapped writer.
+func (w *validationWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.truncated {
+		if int64(w.body.Len()+len(b)) > codec.DefaultMaxBytes {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client if the wrapped writer supports
+// it.
+func (w *validationWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap provides the wrapped http.ResponseWriter.
+func (w *validationWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
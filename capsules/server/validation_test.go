@@ -0,0 +1,184 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.adenix.dev/adderall/capsules/codec"
+	"go.adenix.dev/adderall/capsules/httperr"
+	"go.adenix.dev/adderall/capsules/metrics"
+	"go.adenix.dev/adderall/capsules/openapi"
+	"go.adenix.dev/adderall/capsules/router"
+)
+
+const testSwaggerFile = `{
+  "swagger": "2.0",
+  "info": {"title": "users", "version": "1.0.0"},
+  "paths": {
+    "/users/{id}": {
+      "get": {
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "type": "integer"},
+          {"name": "fields", "in": "query", "type": "array", "items": {"type": "string", "enum": ["name", "email"]}}
+        ],
+        "responses": {
+          "200": {"description": "OK", "schema": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}}
+        }
+      }
+    }
+  }
+}`
+
+func TestValidationMiddleware(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swagger.json")
+	if err := os.WriteFile(file, []byte(testSwaggerFile), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		target     string
+		body       string
+		status     int
+		params     string
+		violations []codec.InvalidParam
+		errors     int
+	}{
+		{
+			name:   "Valid",
+			opts:   []Option{WithServerValidation(ValidationConfig{})},
+			target: "/users/42?fields=name,email",
+			body:   `{"name":"alice"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "InvalidRequest",
+			opts:   []Option{WithServerValidation(ValidationConfig{})},
+			target: "/users/alice?fields=age",
+			status: http.StatusBadRequest,
+			params: `"invalid_params":[{"name":"id","reason":"must be an integer"},{"name":"fields[0]","reason":"must be one of name, email"}]`,
+		},
+		{
+			name:   "Undocumented",
+			opts:   []Option{WithServerValidation(ValidationConfig{})},
+			target: "/live",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "ResponsesNotValidated",
+			opts:   []Option{WithServerValidation(ValidationConfig{})},
+			target: "/users/42",
+			body:   `{}`,
+			status: http.StatusOK,
+		},
+		{
+			name:       "InvalidResponse",
+			opts:       []Option{WithServerValidation(ValidationConfig{Responses: true})},
+			target:     "/users/42",
+			body:       `{}`,
+			status:     http.StatusOK,
+			violations: []codec.InvalidParam{{Name: "name", Reason: "is required"}},
+		},
+		{
+			name:   "Disabled",
+			target: "/users/alice",
+			status: http.StatusOK,
+		},
+		{
+			name:   "MissingFile",
+			opts:   []Option{WithServerValidation(ValidationConfig{}), WithSwaggerFile(filepath.Join(t.TempDir(), "missing.json"))},
+			target: "/users/alice",
+			status: http.StatusOK,
+			errors: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			s := NewFactory(
+				WithLogger(logger),
+				WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+				WithRouter(func() Handler { return router.New() }),
+			).Create(append([]Option{WithSwaggerFile(file)}, test.opts...)...)
+			s.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(test.body))
+			})
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+				if w.Code != test.status {
+					t.Fatalf("expected %d, got %d", test.status, w.Code)
+				}
+				if test.params != "" && !strings.Contains(w.Body.String(), test.params) {
+					t.Errorf("expected %s in %s", test.params, w.Body.String())
+				}
+			}
+
+			warnings := logger.entries("warn")
+			if test.violations == nil && len(warnings) > 0 {
+				t.Errorf("unexpected warnings %v", warnings)
+			}
+			if test.violations != nil {
+				if len(warnings) != 2 || !reflect.DeepEqual(warnings[0].field("invalid_params"), test.violations) {
+					t.Errorf("expected violations %v, got %v", test.violations, warnings)
+				}
+			}
+			if errors := logger.entries("error"); len(errors) != test.errors {
+				t.Errorf("expected %d errors, got %v", test.errors, errors)
+			}
+		})
+	}
+}
+
+func TestValidationMiddlewareOpenAPI(t *testing.T) {
+	s := NewFactory(
+		WithMetrics(metrics.NewRegistry(metrics.WithRuntimeCollectors(false))),
+		WithRouter(func() Handler { return router.New() }),
+	).Create(WithServerOpenAPI(OpenAPIConfig{}), WithServerValidation(ValidationConfig{}))
+
+	s.HandleRoute(openapi.Route{
+		Method:    http.MethodPost,
+		Pattern:   "/users",
+		Request:   testUser{},
+		Responses: map[int]interface{}{http.StatusCreated: testUser{}},
+	}, func(w http.ResponseWriter, r *http.Request) error {
+		u, err := codec.Bind[testUser](r)
+		if err != nil {
+			return err
+		}
+		return codec.Respond(w, r, http.StatusCreated, u)
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "Valid", body: `{"name":"alice"}`, status: http.StatusCreated},
+		{name: "Invalid", body: `{"name":1}`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Errorf("expected %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+			if test.status == http.StatusBadRequest && w.Header().Get("Content-Type") != httperr.ContentType {
+				t.Errorf("expected a problem response, got %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+)
+
+// responseWriter wraps a http.ResponseWriter to record the status code and
+// number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+var _ http.Flusher = (*responseWriter)(nil)
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+// Status provides the status code written, defaulting to 200 when the handler
+// wrote a body without calling WriteHeader.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// BytesWritten provides the number of body bytes written.
+func (w *responseWriter) BytesWritten() int {
+	return w.bytes
+}
+
+// WriteHeader records the status code and sends it to the wrapped writer.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes and sends them to the wrapped writer.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client if the wrapped writer supports
+// it.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap provides the wrapped http.ResponseWriter.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
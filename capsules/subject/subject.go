@@ -0,0 +1,21 @@
+// Package subject carries the subject of the authenticated caller of a
+// request in its context, so packages such as logger can read it without
+// depending on how it was authenticated.
+package subject
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+// NewContext provides a copy of ctx carrying the subject
+func NewContext(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, contextKey{}, subject)
+}
+
+// FromContext provides the subject carried by ctx
+func FromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(contextKey{}).(string)
+	return subject, ok && subject != ""
+}
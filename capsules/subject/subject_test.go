@@ -0,0 +1,22 @@
+package subject
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if subject, ok := FromContext(context.Background()); ok {
+		t.Errorf("expected no subject, got %q", subject)
+	}
+
+	ctx := NewContext(context.Background(), "user-1")
+	if subject, ok := FromContext(ctx); !ok || subject != "user-1" {
+		t.Errorf("expected subject %q, got %q", "user-1", subject)
+	}
+
+	ctx = NewContext(context.Background(), "")
+	if subject, ok := FromContext(ctx); ok {
+		t.Errorf("expected empty subject to be ignored, got %q", subject)
+	}
+}
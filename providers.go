@@ -2,6 +2,8 @@ package adderall
 
 import (
 	"go.adenix.dev/adderall/capsules/client"
+	"go.adenix.dev/adderall/capsules/lifecycle"
+	"go.adenix.dev/adderall/capsules/metrics"
 	"go.adenix.dev/adderall/capsules/server"
 )
 
@@ -20,3 +22,21 @@ func NewServerFactory(options []server.FactoryOption) server.Factory {
 func NewClientFactory(options []client.FactoryOption) client.Factory {
 	return client.NewFactory(options...)
 }
+
+// NewMetricsRegistry provides a metrics.Registry given a slice of
+// metrics.Option.
+//
+// This function is intended to be used with github.com/google/wire
+func NewMetricsRegistry(options []metrics.Option) *metrics.Registry {
+	return metrics.NewRegistry(options...)
+}
+
+// NewApp provides a lifecycle.App running the given slice of
+// lifecycle.Component configured with a slice of lifecycle.Option.
+//
+// This function is intended to be used with github.com/google/wire
+func NewApp(components []lifecycle.Component, options []lifecycle.Option) *lifecycle.App {
+	app := lifecycle.NewApp(options...)
+	app.Add(components...)
+	return app
+}